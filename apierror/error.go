@@ -0,0 +1,99 @@
+package apierror
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/apex/log"
+	"github.com/gin-gonic/gin"
+	"github.com/globalsign/mgo"
+
+	"github.com/marcospsbrito/dic/database"
+)
+
+// Error is an error with a stable code and the HTTP status it is answered
+// with. It is also the body of every error response.
+type Error struct {
+	Status    int    `json:"status" example:"404"`
+	Code      string `json:"code" example:"company.not_found"`
+	Message   string `json:"message" example:"Company not found"`
+	RequestID string `json:"requestId,omitempty" example:"4f6c2a1be0d94c3e"`
+}
+
+// StatusClientClosedRequest is the non standard status of the requests whose
+// client left before they were answered
+const StatusClientClosedRequest = 499
+
+// Errors answered when no more specific one applies
+var (
+	ErrNotFound    = New(http.StatusNotFound, "not_found", "Not found")
+	ErrConflict    = New(http.StatusConflict, "conflict", "Record already exists")
+	ErrCanceled    = New(StatusClientClosedRequest, "request.canceled", "Request canceled by the client")
+	ErrTimeout     = New(http.StatusGatewayTimeout, "request.timeout", "Request did not complete in time")
+	ErrUnavailable = New(http.StatusServiceUnavailable, "database.unavailable", "Database unavailable, retry later")
+	ErrInternal    = New(http.StatusInternalServerError, "internal", "Internal server error")
+)
+
+// New returns an Error
+func New(status int, code string, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Validation returns a 400 Error with the message of err, for invalid
+// parameters and bodies
+func Validation(err error) *Error {
+	return New(http.StatusBadRequest, "validation.failed", err.Error())
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Malformed returns a 400 Error with the message of err, for bodies and
+// files that cannot be parsed
+func Malformed(err error) *Error {
+	return New(http.StatusBadRequest, "request.malformed", err.Error())
+}
+
+// From returns the Error answered for err: err itself when it is an Error,
+// 400 when a csv or JSON body cannot be parsed, 404 when a record is not
+// found, 409 when it duplicates a unique key, 499 when the client left, 504
+// when the deadline of the operation passed, 503 when the database cannot be
+// reached and 500 otherwise
+func From(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	var parseErr *csv.ParseError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &parseErr), errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return Malformed(err)
+	case err == mgo.ErrNotFound:
+		return ErrNotFound
+	case mgo.IsDup(err):
+		return ErrConflict
+	case err == context.Canceled:
+		return ErrCanceled
+	case err == context.DeadlineExceeded:
+		return ErrTimeout
+	case database.IsUnavailable(err):
+		return ErrUnavailable
+	}
+	return ErrInternal
+}
+
+// Abort answers the request with the Error of err and stops its handlers.
+// Server errors are logged, since their response hides the cause.
+func Abort(ctx *gin.Context, err error) {
+	e := *From(err)
+	e.RequestID = RequestIDFrom(ctx)
+	if e.Status >= http.StatusInternalServerError {
+		log.WithError(err).WithField("requestId", e.RequestID).Error("Request failed")
+	}
+	ctx.AbortWithStatusJSON(e.Status, e)
+}
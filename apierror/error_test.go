@@ -0,0 +1,76 @@
+package apierror
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/globalsign/mgo"
+)
+
+func TestFrom(t *testing.T) {
+	conflict := New(http.StatusConflict, "upload.in_progress", "Upload already in progress")
+	tests := []struct {
+		name string
+		err  error
+		want *Error
+	}{
+		{"Project error", conflict, conflict},
+		{"Record not found", mgo.ErrNotFound, ErrNotFound},
+		{"Duplicate key", &mgo.LastError{Code: 11000, Err: "E11000 duplicate key error"}, ErrConflict},
+		{"Client left", context.Canceled, ErrCanceled},
+		{"Deadline passed", context.DeadlineExceeded, ErrTimeout},
+		{"Database unreachable", errors.New("no reachable servers"), ErrUnavailable},
+		{"Connection lost", io.EOF, ErrUnavailable},
+		{"Other error", errors.New("mock error"), ErrInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := From(tt.err); got != tt.want {
+				t.Errorf("From() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAbort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name string
+		err  error
+		want Error
+	}{
+		{"Validation error", Validation(errors.New("Missing name")), Error{http.StatusBadRequest, "validation.failed", "Missing name", "req-1"}},
+		{"Not found", mgo.ErrNotFound, Error{http.StatusNotFound, "not_found", "Not found", "req-1"}},
+		{"Malformed csv", &csv.ParseError{StartLine: 3, Line: 3, Column: 5, Err: csv.ErrQuote}, Error{http.StatusBadRequest, "request.malformed", "parse error on line 3, column 5: extraneous or missing \" in quoted-field", "req-1"}},
+		{"Malformed JSON", json.Unmarshal([]byte("[1,"), new(interface{})), Error{http.StatusBadRequest, "request.malformed", "unexpected end of JSON input", "req-1"}},
+		{"Internal error hides its cause", errors.New("mock error"), Error{http.StatusInternalServerError, "internal", "Internal server error", "req-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(RequestID())
+			r.GET("/", func(ctx *gin.Context) { Abort(ctx, tt.err) })
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(RequestIDHeader, "req-1")
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want.Status {
+				t.Errorf("Abort() status = %v, want %v", w.Code, tt.want.Status)
+			}
+			var got Error
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("Abort() body = %v, error = %v", w.Body.String(), err)
+			}
+			if got != tt.want {
+				t.Errorf("Abort() body = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package apierror
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the id of a request, sent by the caller or
+// generated, and is echoed in the response
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the gin context key of the request id
+const requestIDKey = "apierror.requestId"
+
+// maxRequestIDLen bounds the ids accepted from callers
+const maxRequestIDLen = 128
+
+// RequestID middleware assigns an id to every request, keeping the one sent
+// by the caller when it is printable and short enough. The id is recorded on
+// the span of the request, if any.
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		ctx.Set(requestIDKey, id)
+		ctx.Header(RequestIDHeader, id)
+		trace.SpanFromContext(ctx.Request.Context()).SetAttributes(attribute.String("request.id", id))
+		ctx.Next()
+	}
+}
+
+// RequestIDFrom returns the id assigned to the request by RequestID
+func RequestIDFrom(ctx *gin.Context) string {
+	return ctx.GetString(requestIDKey)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
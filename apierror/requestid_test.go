@@ -0,0 +1,42 @@
+package apierror
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name     string
+		header   string
+		wantSame bool
+	}{
+		{"Keep the id of the caller", "4f6c2a1be0d94c3e", true},
+		{"Generate a missing id", "", false},
+		{"Replace an id with spaces", "bad id", false},
+		{"Replace a long id", strings.Repeat("a", maxRequestIDLen+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var id string
+			r := gin.New()
+			r.Use(RequestID())
+			r.GET("/", func(ctx *gin.Context) { id = RequestIDFrom(ctx) })
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(RequestIDHeader, tt.header)
+			r.ServeHTTP(w, req)
+			if got := w.Header().Get(RequestIDHeader); got != id || id == "" {
+				t.Errorf("RequestID() header = %q, context id = %q", got, id)
+			}
+			if (id == tt.header) != tt.wantSame {
+				t.Errorf("RequestID() = %q, kept %q = %v", id, tt.header, !tt.wantSame)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/apex/log"
+	"github.com/gin-gonic/gin"
+	"github.com/globalsign/mgo"
+
+	"github.com/marcospsbrito/dic/apierror"
+)
+
+// Controller defines methods to a Controller
+type Controller interface {
+	CreateKey(ctx *gin.Context)
+	ListKeys(ctx *gin.Context)
+	RevokeKey(ctx *gin.Context)
+	CreateTenant(ctx *gin.Context)
+	ListTenants(ctx *gin.Context)
+}
+
+// ErrKeyNotFound is answered when a revoked key does not exist
+var ErrKeyNotFound = apierror.New(http.StatusNotFound, "key.not_found", "API key not found")
+
+type keyController struct {
+	service Service
+}
+
+// NewController return a new keyController
+func NewController(service Service) Controller {
+	return keyController{service}
+}
+
+// CreateKeyRequest is the body to create an API key
+type CreateKeyRequest struct {
+	Name string `json:"name" binding:"required" example:"pipeline"`
+	Role Role   `json:"role" binding:"required" example:"importer"`
+}
+
+// CreatedKey is a new API key with its secret, which is not shown again
+type CreatedKey struct {
+	Key
+	Secret string `json:"secret" example:"dic_Xb3kVtq2yH0S4Yz8T9cI3m5o7n1p6r2u8w0e4a6d8f0"`
+}
+
+// CreateTenantRequest is the body to create a tenant
+type CreateTenantRequest struct {
+	ID   string `json:"id" binding:"required" example:"sales"`
+	Name string `json:"name" example:"Sales"`
+}
+
+// CreatedTenant is a new tenant with its first admin key
+type CreatedTenant struct {
+	Tenant
+	Key CreatedKey `json:"key"`
+}
+
+// CreateKey godoc
+// @Summary Create an API key
+// @Description create an API key of the tenant of the caller with a role. The secret is only returned in this response.
+// @ID post-keys
+// @Accept json
+// @Produce json
+// @Param key body auth.CreateKeyRequest true "Key"
+// @Success 201 {object} auth.CreatedKey
+// @Failure 400 {object} apierror.Error
+// @Failure 401 {object} apierror.Error
+// @Failure 403 {object} apierror.Error
+// @Failure 429 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Failure 503 {object} apierror.Error
+// @Security ApiKeyAuth
+// @Security OAuth2Application[admin]
+// @Router /keys [post]
+func (c keyController) CreateKey(ctx *gin.Context) {
+	var req CreateKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(ctx, apierror.Validation(err))
+		return
+	}
+	k, secret, err := c.service.createKey(ctx.Request.Context(), TenantFrom(ctx), req.Name, req.Role)
+	if err != nil {
+		apierror.Abort(ctx, err)
+		return
+	}
+	if p, ok := PrincipalFrom(ctx); ok {
+		log.WithFields(log.Fields{"subject": p.Subject, "key": k.ID.Hex(), "role": k.Role}).Info("API key created")
+	}
+	ctx.JSON(http.StatusCreated, CreatedKey{k, secret})
+}
+
+// ListKeys godoc
+// @Summary List API keys
+// @Description get every API key of the tenant of the caller, without their secrets
+// @ID get-keys
+// @Produce json
+// @Success 200 {array} auth.Key
+// @Failure 401 {object} apierror.Error
+// @Failure 403 {object} apierror.Error
+// @Failure 429 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Failure 503 {object} apierror.Error
+// @Security ApiKeyAuth
+// @Security OAuth2Application[admin]
+// @Router /keys [get]
+func (c keyController) ListKeys(ctx *gin.Context) {
+	keys, err := c.service.listKeys(ctx.Request.Context(), TenantFrom(ctx))
+	if err != nil {
+		apierror.Abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, keys)
+}
+
+// RevokeKey godoc
+// @Summary Revoke an API key
+// @Description revoke an API key of the tenant of the caller, which is rejected from then on
+// @ID delete-key
+// @Param id path string true "Key ID"
+// @Success 204
+// @Failure 401 {object} apierror.Error
+// @Failure 403 {object} apierror.Error
+// @Failure 404 {object} apierror.Error
+// @Failure 429 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Failure 503 {object} apierror.Error
+// @Security ApiKeyAuth
+// @Security OAuth2Application[admin]
+// @Router /keys/{id} [delete]
+func (c keyController) RevokeKey(ctx *gin.Context) {
+	err := c.service.revokeKey(ctx.Request.Context(), TenantFrom(ctx), ctx.Param("id"))
+	if err == mgo.ErrNotFound {
+		err = ErrKeyNotFound
+	}
+	if err != nil {
+		apierror.Abort(ctx, err)
+		return
+	}
+	if p, ok := PrincipalFrom(ctx); ok {
+		log.WithFields(log.Fields{"subject": p.Subject, "key": ctx.Param("id")}).Info("API key revoked")
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// CreateTenant godoc
+// @Summary Create a tenant
+// @Description create a tenant and its first admin key, whose secret is only returned in this response. Only admins of the default tenant manage tenants.
+// @ID post-tenants
+// @Accept json
+// @Produce json
+// @Param tenant body auth.CreateTenantRequest true "Tenant"
+// @Success 201 {object} auth.CreatedTenant
+// @Failure 400 {object} apierror.Error
+// @Failure 401 {object} apierror.Error
+// @Failure 403 {object} apierror.Error
+// @Failure 409 {object} apierror.Error
+// @Failure 429 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Failure 503 {object} apierror.Error
+// @Security ApiKeyAuth
+// @Security OAuth2Application[admin]
+// @Router /tenants [post]
+func (c keyController) CreateTenant(ctx *gin.Context) {
+	var req CreateTenantRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(ctx, apierror.Validation(err))
+		return
+	}
+	t, k, secret, err := c.service.createTenant(ctx.Request.Context(), req.ID, req.Name)
+	if err != nil {
+		apierror.Abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, CreatedTenant{t, CreatedKey{k, secret}})
+}
+
+// ListTenants godoc
+// @Summary List tenants
+// @Description get every tenant. Only admins of the default tenant manage tenants.
+// @ID get-tenants
+// @Produce json
+// @Success 200 {array} auth.Tenant
+// @Failure 401 {object} apierror.Error
+// @Failure 403 {object} apierror.Error
+// @Failure 429 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Failure 503 {object} apierror.Error
+// @Security ApiKeyAuth
+// @Security OAuth2Application[admin]
+// @Router /tenants [get]
+func (c keyController) ListTenants(ctx *gin.Context) {
+	tenants, err := c.service.listTenants(ctx.Request.Context())
+	if err != nil {
+		apierror.Abort(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, tenants)
+}
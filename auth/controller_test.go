@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/globalsign/mgo/bson"
+)
+
+func newTestKeyRouter(s Service) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	c := NewController(s)
+	r := gin.New()
+	r.POST("/keys", c.CreateKey)
+	r.GET("/keys", c.ListKeys)
+	r.DELETE("/keys/:id", c.RevokeKey)
+	r.POST("/tenants", c.CreateTenant)
+	return r
+}
+
+func Test_keyController_CreateKey(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"Create key", `{"name":"pipeline","role":"importer"}`, http.StatusCreated},
+		{"Unknown role", `{"name":"pipeline","role":"owner"}`, http.StatusBadRequest},
+		{"Missing name", `{"role":"reader"}`, http.StatusBadRequest},
+		{"Invalid body", `{`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(NewMemoryRepository())
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/keys", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			newTestKeyRouter(s).ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("keyController.CreateKey() status = %v, want %v", w.Code, tt.want)
+				return
+			}
+			if w.Code != http.StatusCreated {
+				return
+			}
+			var got CreatedKey
+			json.Unmarshal(w.Body.Bytes(), &got)
+			if _, err := s.Authenticate(context.Background(), got.Secret); err != nil {
+				t.Errorf("keyController.CreateKey() secret does not authenticate: %v", err)
+			}
+			if strings.Contains(w.Body.String(), got.Hash) && got.Hash != "" {
+				t.Errorf("keyController.CreateKey() exposed the hash")
+			}
+		})
+	}
+}
+
+func Test_keyController_RevokeKey(t *testing.T) {
+	s := NewService(NewMemoryRepository())
+	k, _, _ := s.createKey(context.Background(), DefaultTenant, "pipeline", RoleReader)
+	tests := []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"Revoke key", k.ID.Hex(), http.StatusNoContent},
+		{"Unknown key", bson.NewObjectId().Hex(), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newTestKeyRouter(s).ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/keys/"+tt.id, nil))
+			if w.Code != tt.want {
+				t.Errorf("keyController.RevokeKey() status = %v, want %v", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func Test_keyController_ListKeys(t *testing.T) {
+	s := NewService(NewMemoryRepository())
+	s.createKey(context.Background(), DefaultTenant, "pipeline", RoleReader)
+	w := httptest.NewRecorder()
+	newTestKeyRouter(s).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/keys", nil))
+	var got []Key
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if w.Code != http.StatusOK || len(got) != 1 || strings.Contains(w.Body.String(), "hash") {
+		t.Errorf("keyController.ListKeys() = %v %v", w.Code, w.Body.String())
+	}
+}
+
+func Test_keyController_CreateTenant(t *testing.T) {
+	s := NewService(NewMemoryRepository())
+	s.EnsureTenant(context.Background(), DefaultTenant, "Default")
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"Create tenant", `{"id":"sales","name":"Sales"}`, http.StatusCreated},
+		{"Existing tenant", `{"id":"default"}`, http.StatusConflict},
+		{"Invalid id", `{"id":"Sales Team"}`, http.StatusBadRequest},
+		{"Missing id", `{"name":"Sales"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/tenants", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			newTestKeyRouter(s).ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("keyController.CreateTenant() status = %v, want %v", w.Code, tt.want)
+				return
+			}
+			if w.Code != http.StatusCreated {
+				return
+			}
+			var got CreatedTenant
+			json.Unmarshal(w.Body.Bytes(), &got)
+			if k, err := s.Authenticate(context.Background(), got.Key.Secret); err != nil || k.Tenant != got.ID {
+				t.Errorf("keyController.CreateTenant() key = %v, %v", k, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/marcospsbrito/dic/database"
+)
+
+// instrumentedRepository traces the operations of a Repository and records
+// their latency
+type instrumentedRepository struct {
+	Repository
+}
+
+// NewInstrumentedRepository returns a Repository tracing the operations of r
+// and recording their latency
+func NewInstrumentedRepository(r Repository) Repository {
+	return instrumentedRepository{r}
+}
+
+func (r instrumentedRepository) FindAll(ctx context.Context, tenant string) ([]Key, error) {
+	done := database.Instrument(ctx, "auth", "FindAll")
+	keys, err := r.Repository.FindAll(ctx, tenant)
+	done(err)
+	return keys, err
+}
+
+func (r instrumentedRepository) FindByHash(ctx context.Context, hash string) (Key, error) {
+	done := database.Instrument(ctx, "auth", "FindByHash")
+	k, err := r.Repository.FindByHash(ctx, hash)
+	done(err)
+	return k, err
+}
+
+func (r instrumentedRepository) Add(ctx context.Context, k Key) error {
+	done := database.Instrument(ctx, "auth", "Add")
+	err := r.Repository.Add(ctx, k)
+	done(err)
+	return err
+}
+
+func (r instrumentedRepository) Revoke(ctx context.Context, tenant string, id bson.ObjectId) error {
+	done := database.Instrument(ctx, "auth", "Revoke")
+	err := r.Repository.Revoke(ctx, tenant, id)
+	done(err)
+	return err
+}
+
+func (r instrumentedRepository) FindTenants(ctx context.Context) ([]Tenant, error) {
+	done := database.Instrument(ctx, "auth", "FindTenants")
+	tenants, err := r.Repository.FindTenants(ctx)
+	done(err)
+	return tenants, err
+}
+
+func (r instrumentedRepository) FindTenant(ctx context.Context, id string) (Tenant, error) {
+	done := database.Instrument(ctx, "auth", "FindTenant")
+	t, err := r.Repository.FindTenant(ctx, id)
+	done(err)
+	return t, err
+}
+
+func (r instrumentedRepository) AddTenant(ctx context.Context, t Tenant) error {
+	done := database.Instrument(ctx, "auth", "AddTenant")
+	err := r.Repository.AddTenant(ctx, t)
+	done(err)
+	return err
+}
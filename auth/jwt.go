@@ -0,0 +1,326 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/golang-jwt/jwt"
+
+	"github.com/marcospsbrito/dic/apierror"
+)
+
+// OAuth2 scopes of the security definitions, each one granting a role
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+var scopeRoles = map[string]Role{
+	ScopeRead:  RoleReader,
+	ScopeWrite: RoleImporter,
+	ScopeAdmin: RoleAdmin,
+}
+
+// defaultTenantClaim is the claim holding the tenant of the caller
+const defaultTenantClaim = "tenant"
+
+// defaultRefreshInterval limits how often an unknown key id reloads the JWKS
+const defaultRefreshInterval = time.Minute
+
+// ErrInvalidToken is returned when a bearer token cannot be trusted
+var ErrInvalidToken = apierror.New(http.StatusUnauthorized, "auth.invalid_token", "Invalid bearer token")
+
+// TokenVerifier validates bearer tokens and returns their caller
+type TokenVerifier interface {
+	Verify(token string) (Principal, error)
+}
+
+// JWTConfig describes the tokens issued by the identity provider
+type JWTConfig struct {
+	// JWKS is the path or http(s) URL of the JSON Web Key Set of the issuer
+	JWKS string
+	// Issuer and Audience are checked against the iss and aud claims when set
+	Issuer   string
+	Audience string
+	// TenantClaim holds the tenant of the caller, tenant when empty. Tokens
+	// without it are rejected.
+	TenantClaim string
+	// RefreshInterval limits how often a JWKS URL is reloaded to find
+	// rotated keys, one minute when zero
+	RefreshInterval time.Duration
+}
+
+type jwtVerifier struct {
+	config   JWTConfig
+	client   *http.Client
+	mu       sync.RWMutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+}
+
+// NewTokenVerifier returns a TokenVerifier of JWTs signed by the keys of the
+// configured JWKS
+func NewTokenVerifier(cfg JWTConfig) (TokenVerifier, error) {
+	if cfg.TenantClaim == "" {
+		cfg.TenantClaim = defaultTenantClaim
+	}
+	if cfg.RefreshInterval == 0 {
+		cfg.RefreshInterval = defaultRefreshInterval
+	}
+	v := &jwtVerifier{config: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+	if err := v.load(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Verify checks the signature, expiry, issuer and audience of token and maps
+// its scopes to a role. Tokens without an expiry, a subject or a tenant are
+// rejected.
+func (v *jwtVerifier) Verify(token string) (Principal, error) {
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, v.key)
+	if err != nil || !parsed.Valid {
+		log.WithError(err).Debug("Rejected bearer token")
+		return Principal{}, ErrInvalidToken
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		log.Debug("Rejected bearer token without expiry")
+		return Principal{}, ErrInvalidToken
+	}
+	if v.config.Issuer != "" && !claims.VerifyIssuer(v.config.Issuer, true) {
+		log.Debug("Rejected bearer token of another issuer")
+		return Principal{}, ErrInvalidToken
+	}
+	if v.config.Audience != "" && !claims.VerifyAudience(v.config.Audience, true) {
+		log.Debug("Rejected bearer token of another audience")
+		return Principal{}, ErrInvalidToken
+	}
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return Principal{}, ErrInvalidToken
+	}
+	tenant, _ := claims[v.config.TenantClaim].(string)
+	if tenant == "" {
+		log.WithField("claim", v.config.TenantClaim).Debug("Rejected bearer token without tenant")
+		return Principal{}, ErrInvalidToken
+	}
+	p := Principal{Subject: subject, Tenant: tenant, Name: subject, Method: MethodJWT, Scopes: tokenScopes(claims)}
+	for _, claim := range []string{"preferred_username", "email", "name"} {
+		if name, ok := claims[claim].(string); ok && name != "" {
+			p.Name = name
+			break
+		}
+	}
+	for _, scope := range p.Scopes {
+		if role := scopeRoles[scope]; role.Allows(p.Role) {
+			p.Role = role
+		}
+	}
+	return p, nil
+}
+
+// key returns the public key that signed t, reloading the JWKS once per
+// refresh interval when the key id is unknown
+func (v *jwtVerifier) key(t *jwt.Token) (interface{}, error) {
+	switch t.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+	default:
+		return nil, fmt.Errorf("Unexpected signing method %v", t.Header["alg"])
+	}
+	kid, _ := t.Header["kid"].(string)
+	if k, ok := v.lookup(kid); ok {
+		return k, nil
+	}
+	v.mu.RLock()
+	stale := time.Since(v.loadedAt) >= v.config.RefreshInterval
+	v.mu.RUnlock()
+	if stale && isURL(v.config.JWKS) {
+		if err := v.load(); err != nil {
+			return nil, err
+		}
+		if k, ok := v.lookup(kid); ok {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown key id %q", kid)
+}
+
+// lookup returns the key of kid, or the only key when the token has no kid
+func (v *jwtVerifier) lookup(kid string) (crypto.PublicKey, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k, true
+		}
+	}
+	k, ok := v.keys[kid]
+	return k, ok
+}
+
+func (v *jwtVerifier) load() error {
+	b, err := v.read()
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(b)
+	if err != nil {
+		return err
+	}
+	v.mu.Lock()
+	v.keys, v.loadedAt = keys, time.Now()
+	v.mu.Unlock()
+	log.WithFields(log.Fields{"jwks": v.config.JWKS, "keys": len(keys)}).Info("Loaded JSON Web Key Set")
+	return nil
+}
+
+func (v *jwtVerifier) read() ([]byte, error) {
+	if !isURL(v.config.JWKS) {
+		return ioutil.ReadFile(v.config.JWKS)
+	}
+	resp, err := v.client.Get(v.config.JWKS)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Cannot fetch JWKS: %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS returns the RSA and EC signature keys of a JWKS by key id
+func parseJWKS(b []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch k.Kty {
+		case "RSA":
+			key, err = k.rsa()
+		case "EC":
+			key, err = k.ecdsa()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid key %q: %v", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS has no signature keys")
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) rsa() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeBigInt(k.E)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, errors.New("exponent too large")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (k jsonWebKey) ecdsa() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	x, err := decodeBigInt(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeBigInt(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on the curve")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// tokenScopes returns the space separated scope claim or the scp claim
+func tokenScopes(claims jwt.MapClaims) []string {
+	switch scp := claims["scp"].(type) {
+	case []interface{}:
+		var scopes []string
+		for _, s := range scp {
+			if s, ok := s.(string); ok {
+				scopes = append(scopes, s)
+			}
+		}
+		return scopes
+	case string:
+		return strings.Fields(scp)
+	}
+	if scope, ok := claims["scope"].(string); ok && scope != "" {
+		return strings.Fields(scope)
+	}
+	return nil
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
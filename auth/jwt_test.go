@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// testIssuer signs tokens with its keys and publishes them as a JWKS, as a
+// local stand-in of the identity provider
+type testIssuer struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) testIssuer {
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testIssuer{rk, ek}
+}
+
+func (i testIssuer) jwks() []byte {
+	enc := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+	b, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": enc(i.rsa.N), "e": enc(big.NewInt(int64(i.rsa.E)))},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": enc(i.ec.X), "y": enc(i.ec.Y)},
+		{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+	}})
+	return b
+}
+
+func (i testIssuer) writeJWKS(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	file := filepath.Join(dir, "jwks.json")
+	if err := ioutil.WriteFile(file, i.jwks(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func (i testIssuer) sign(method jwt.SigningMethod, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	var key interface{} = i.rsa
+	switch method.(type) {
+	case *jwt.SigningMethodECDSA:
+		key = i.ec
+	case *jwt.SigningMethodHMAC:
+		key = []byte("secret")
+	}
+	s, _ := token.SignedString(key)
+	return s
+}
+
+func testClaims(extra jwt.MapClaims) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"iss":    "https://idp.example.com",
+		"aud":    []string{"dic", "other"},
+		"sub":    "248289761001",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"tenant": DefaultTenant,
+	}
+	for k, v := range extra {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+	return claims
+}
+
+func Test_jwtVerifier_Verify(t *testing.T) {
+	issuer := newTestIssuer(t)
+	v, err := NewTokenVerifier(JWTConfig{JWKS: issuer.writeJWKS(t), Issuer: "https://idp.example.com", Audience: "dic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		token   string
+		want    Principal
+		wantErr error
+	}{
+		{"RSA token with scope", issuer.sign(jwt.SigningMethodRS256, "rsa-1", testClaims(jwt.MapClaims{"scope": "openid read write", "email": "jane@example.com"})),
+			Principal{Subject: "248289761001", Tenant: DefaultTenant, Name: "jane@example.com", Role: RoleImporter, Method: MethodJWT, Scopes: []string{"openid", "read", "write"}}, nil},
+		{"EC token with scp and tenant", issuer.sign(jwt.SigningMethodES256, "ec-1", testClaims(jwt.MapClaims{"scp": []string{"admin", "read"}, "tenant": "sales"})),
+			Principal{Subject: "248289761001", Tenant: "sales", Name: "248289761001", Role: RoleAdmin, Method: MethodJWT, Scopes: []string{"admin", "read"}}, nil},
+		{"Token without scopes", issuer.sign(jwt.SigningMethodRS256, "rsa-1", testClaims(nil)),
+			Principal{Subject: "248289761001", Tenant: DefaultTenant, Name: "248289761001", Method: MethodJWT}, nil},
+		{"Expired token", issuer.sign(jwt.SigningMethodRS256, "rsa-1", testClaims(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})), Principal{}, ErrInvalidToken},
+		{"Other issuer", issuer.sign(jwt.SigningMethodRS256, "rsa-1", testClaims(jwt.MapClaims{"iss": "https://evil.example.com"})), Principal{}, ErrInvalidToken},
+		{"Other audience", issuer.sign(jwt.SigningMethodRS256, "rsa-1", testClaims(jwt.MapClaims{"aud": "other"})), Principal{}, ErrInvalidToken},
+		{"Unknown key id", issuer.sign(jwt.SigningMethodRS256, "rsa-2", testClaims(nil)), Principal{}, ErrInvalidToken},
+		{"Key of another type", issuer.sign(jwt.SigningMethodRS256, "ec-1", testClaims(nil)), Principal{}, ErrInvalidToken},
+		{"HMAC token", issuer.sign(jwt.SigningMethodHS256, "hmac", testClaims(nil)), Principal{}, ErrInvalidToken},
+		{"Missing subject", issuer.sign(jwt.SigningMethodRS256, "rsa-1", testClaims(jwt.MapClaims{"sub": ""})), Principal{}, ErrInvalidToken},
+		{"Missing tenant", issuer.sign(jwt.SigningMethodRS256, "rsa-1", testClaims(jwt.MapClaims{"tenant": nil})), Principal{}, ErrInvalidToken},
+		{"Empty tenant", issuer.sign(jwt.SigningMethodRS256, "rsa-1", testClaims(jwt.MapClaims{"tenant": ""})), Principal{}, ErrInvalidToken},
+		{"Missing expiry", issuer.sign(jwt.SigningMethodRS256, "rsa-1", testClaims(jwt.MapClaims{"exp": nil})), Principal{}, ErrInvalidToken},
+		{"Garbage", "not.a.token", Principal{}, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.Verify(tt.token)
+			if err != tt.wantErr {
+				t.Errorf("jwtVerifier.Verify() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("jwtVerifier.Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_jwtVerifier_rotation(t *testing.T) {
+	old, current := newTestIssuer(t), newTestIssuer(t)
+	jwks := old.jwks()
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Write(jwks)
+	}))
+	defer srv.Close()
+	v, err := NewTokenVerifier(JWTConfig{JWKS: srv.URL, RefreshInterval: time.Nanosecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks = current.jwks()
+	claims := testClaims(jwt.MapClaims{"scope": "read"})
+	if _, err := v.Verify(current.sign(jwt.SigningMethodRS256, "rsa-1", claims)); err != ErrInvalidToken {
+		t.Errorf("jwtVerifier.Verify() with the same key id accepted a token of a rotated key")
+	}
+	claims["sub"] = "rotated"
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "rsa-new"
+	jwks = []byte(`{"keys":[` + string(jwksKey(current.rsa, "rsa-new")) + `]}`)
+	signed, _ := token.SignedString(current.rsa)
+	if got, err := v.Verify(signed); err != nil || got.Subject != "rotated" {
+		t.Errorf("jwtVerifier.Verify() = %v, %v after key rotation", got, err)
+	}
+	if fetches < 2 {
+		t.Errorf("jwtVerifier fetched the JWKS %v times, want a reload", fetches)
+	}
+}
+
+func jwksKey(k *rsa.PrivateKey, kid string) []byte {
+	enc := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+	b, _ := json.Marshal(map[string]string{"kty": "RSA", "kid": kid, "n": enc(k.N), "e": enc(big.NewInt(int64(k.E)))})
+	return b
+}
+
+func TestNewTokenVerifier(t *testing.T) {
+	tests := []struct {
+		name string
+		jwks string
+	}{
+		{"Missing file", "/nonexistent/jwks.json"},
+		{"No signature keys", `{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`},
+		{"Invalid key", `{"keys":[{"kty":"EC","kid":"ec","crv":"P-256","x":"AQ","y":"AQ"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := tt.jwks
+			if source[0] == '{' {
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte(tt.jwks))
+				}))
+				defer srv.Close()
+				source = srv.URL
+			}
+			if _, err := NewTokenVerifier(JWTConfig{JWKS: source}); err == nil {
+				t.Errorf("NewTokenVerifier() error = nil, want an error")
+			}
+		})
+	}
+}
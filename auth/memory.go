@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// memoryRepository is an embedded Repository for runs without a database.
+// Operations fail with the error of their context when it is already done.
+type memoryRepository struct {
+	mu      sync.RWMutex
+	keys    []Key
+	tenants map[string]Tenant
+}
+
+// NewMemoryRepository returns an embedded Repository impl
+func NewMemoryRepository() Repository {
+	return &memoryRepository{tenants: make(map[string]Tenant)}
+}
+
+func (r *memoryRepository) FindAll(ctx context.Context, tenant string) ([]Key, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var results []Key
+	for _, k := range r.keys {
+		if k.Tenant == tenant {
+			results = append(results, k)
+		}
+	}
+	return results, nil
+}
+
+func (r *memoryRepository) FindByHash(ctx context.Context, hash string) (Key, error) {
+	if err := ctx.Err(); err != nil {
+		return Key{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, k := range r.keys {
+		if k.Hash == hash {
+			return k, nil
+		}
+	}
+	return Key{}, mgo.ErrNotFound
+}
+
+func (r *memoryRepository) Add(ctx context.Context, k Key) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.keys {
+		if e.Hash == k.Hash {
+			return &mgo.LastError{Code: 11000, Err: "duplicate key"}
+		}
+	}
+	r.keys = append(r.keys, k)
+	return nil
+}
+
+func (r *memoryRepository) Revoke(ctx context.Context, tenant string, id bson.ObjectId) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.keys {
+		if r.keys[i].ID == id && r.keys[i].Tenant == tenant {
+			r.keys[i].Revoked = true
+			return nil
+		}
+	}
+	return mgo.ErrNotFound
+}
+
+func (r *memoryRepository) FindTenants(ctx context.Context) ([]Tenant, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	results := make([]Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		results = append(results, t)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+	return results, nil
+}
+
+func (r *memoryRepository) FindTenant(ctx context.Context, id string) (Tenant, error) {
+	if err := ctx.Err(); err != nil {
+		return Tenant{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tenants[id]
+	if !ok {
+		return Tenant{}, mgo.ErrNotFound
+	}
+	return t, nil
+}
+
+func (r *memoryRepository) AddTenant(ctx context.Context, t Tenant) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tenants[t.ID]; ok {
+		return ErrTenantExists
+	}
+	r.tenants[t.ID] = t
+	return nil
+}
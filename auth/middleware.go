@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/gin-gonic/gin"
+	"github.com/globalsign/mgo"
+
+	"github.com/marcospsbrito/dic/apierror"
+)
+
+// principalKey is the gin context key of the authenticated Principal
+const principalKey = "auth.principal"
+
+// Authentication methods of a Principal
+const (
+	MethodAPIKey = "apikey"
+	MethodJWT    = "jwt"
+)
+
+var (
+	// ErrMissingCredentials is returned when a request has no API key
+	ErrMissingCredentials = apierror.New(http.StatusUnauthorized, "auth.missing_credentials", "Missing API key or bearer token")
+	// ErrForbidden is returned when the caller role does not grant access
+	ErrForbidden = apierror.New(http.StatusForbidden, "auth.forbidden", "Role does not grant access to this resource")
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject string `json:"subject"`
+	Tenant  string `json:"tenant"`
+	Name    string `json:"name"`
+	Role    Role   `json:"role"`
+	Method  string `json:"method"`
+	// Scopes are the OAuth2 scopes of bearer tokens
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// PrincipalFrom returns the caller authenticated by the Authenticate middleware
+func PrincipalFrom(ctx *gin.Context) (Principal, bool) {
+	v, ok := ctx.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+	p, ok := v.(Principal)
+	return p, ok
+}
+
+// TenantFrom returns the tenant of the caller, or the default tenant when
+// authentication is disabled. Callers never fall back to the default tenant,
+// whose admins manage every tenant.
+func TenantFrom(ctx *gin.Context) string {
+	if p, ok := PrincipalFrom(ctx); ok {
+		return p.Tenant
+	}
+	return DefaultTenant
+}
+
+// Authenticate middleware stores the caller of requests with a valid API key
+// or bearer token and rejects the others with 401. The key is sent in the
+// Authorization header, either bare or as "ApiKey <key>", or in the X-API-Key
+// header. Bearer tokens are only accepted when v is not nil.
+func Authenticate(s Service, v TokenVerifier) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		scheme, secret := credentials(ctx.Request)
+		if secret == "" {
+			unauthorized(ctx, v, ErrMissingCredentials)
+			return
+		}
+		if scheme == "bearer" {
+			authenticateToken(ctx, v, secret)
+			return
+		}
+		k, err := s.Authenticate(ctx.Request.Context(), secret)
+		if err == ErrInvalidKey {
+			unauthorized(ctx, v, err)
+			return
+		}
+		if err != nil {
+			apierror.Abort(ctx, err)
+			return
+		}
+		ctx.Set(principalKey, Principal{Subject: k.ID.Hex(), Tenant: k.Tenant, Name: k.Name, Role: k.Role, Method: MethodAPIKey})
+		ctx.Next()
+	}
+}
+
+func authenticateToken(ctx *gin.Context, v TokenVerifier, token string) {
+	if v == nil {
+		unauthorized(ctx, v, ErrInvalidToken)
+		return
+	}
+	p, err := v.Verify(token)
+	if err != nil {
+		unauthorized(ctx, v, err)
+		return
+	}
+	ctx.Set(principalKey, p)
+	ctx.Next()
+}
+
+// Require middleware rejects callers whose role does not allow role with 403
+func Require(role Role) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		p, ok := PrincipalFrom(ctx)
+		if !ok {
+			unauthorized(ctx, nil, ErrMissingCredentials)
+			return
+		}
+		if !p.Role.Allows(role) {
+			log.WithFields(log.Fields{"subject": p.Subject, "role": p.Role, "required": role}).Info("Access denied")
+			apierror.Abort(ctx, ErrForbidden)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// RequireTenant middleware rejects callers of other tenants than tenant
+// with 403
+func RequireTenant(tenant string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		p, ok := PrincipalFrom(ctx)
+		if !ok {
+			unauthorized(ctx, nil, ErrMissingCredentials)
+			return
+		}
+		if p.Tenant != tenant {
+			log.WithFields(log.Fields{"subject": p.Subject, "tenant": p.Tenant, "required": tenant}).Info("Access denied")
+			apierror.Abort(ctx, ErrForbidden)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// TenantExists middleware rejects requests naming an unknown tenant in the
+// id path parameter or the from query parameter with 404
+func TenantExists(s Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		for _, id := range []string{ctx.Param("id"), ctx.Query("from")} {
+			if id == "" {
+				continue
+			}
+			_, err := s.findTenant(ctx.Request.Context(), id)
+			if err == mgo.ErrNotFound {
+				err = ErrTenantNotFound
+			}
+			if err != nil {
+				apierror.Abort(ctx, err)
+				return
+			}
+		}
+		ctx.Next()
+	}
+}
+
+// credentials returns the lower cased scheme and the value of the
+// credentials of r. Bare keys and the X-API-Key header have the apikey scheme.
+func credentials(r *http.Request) (string, string) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return "apikey", key
+	}
+	value := strings.TrimSpace(r.Header.Get("Authorization"))
+	i := strings.IndexByte(value, ' ')
+	if i < 0 {
+		return "apikey", value
+	}
+	scheme := strings.ToLower(value[:i])
+	if scheme != "apikey" && scheme != "bearer" {
+		return scheme, ""
+	}
+	return scheme, strings.TrimSpace(value[i+1:])
+}
+
+func unauthorized(ctx *gin.Context, v TokenVerifier, err error) {
+	ctx.Writer.Header().Add("WWW-Authenticate", `ApiKey realm="dic"`)
+	if v != nil {
+		ctx.Writer.Header().Add("WWW-Authenticate", `Bearer realm="dic"`)
+	}
+	apierror.Abort(ctx, err)
+}
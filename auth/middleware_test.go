@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRouter(s Service, v TokenVerifier, role Role) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", Authenticate(s, v), Require(role), func(ctx *gin.Context) {
+		p, _ := PrincipalFrom(ctx)
+		ctx.String(http.StatusOK, string(p.Role))
+	})
+	return r
+}
+
+func TestAuthenticate(t *testing.T) {
+	s := NewService(NewMemoryRepository())
+	_, reader, _ := s.createKey(context.Background(), DefaultTenant, "dashboard", RoleReader)
+	_, admin, _ := s.createKey(context.Background(), DefaultTenant, "ops", RoleAdmin)
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		required Role
+		want     int
+	}{
+		{"Missing key", "", "", RoleReader, http.StatusUnauthorized},
+		{"Unknown key", "Authorization", "ApiKey dic_unknown", RoleReader, http.StatusUnauthorized},
+		{"Other scheme", "Authorization", "Basic " + reader, RoleReader, http.StatusUnauthorized},
+		{"ApiKey scheme", "Authorization", "ApiKey " + reader, RoleReader, http.StatusOK},
+		{"Bare key", "Authorization", reader, RoleReader, http.StatusOK},
+		{"X-API-Key header", "X-API-Key", reader, RoleReader, http.StatusOK},
+		{"Insufficient role", "Authorization", reader, RoleImporter, http.StatusForbidden},
+		{"Higher role", "Authorization", admin, RoleImporter, http.StatusOK},
+		{"Bearer without verifier", "Authorization", "Bearer " + reader, RoleReader, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			newTestRouter(s, nil, tt.required).ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("Authenticate() status = %v, want %v", w.Code, tt.want)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("Authenticate() missing WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+type verifierMock struct {
+	verifyFn func(string) (Principal, error)
+}
+
+func (v verifierMock) Verify(token string) (Principal, error) {
+	return v.verifyFn(token)
+}
+
+func TestAuthenticate_bearer(t *testing.T) {
+	s := NewService(NewMemoryRepository())
+	v := verifierMock{verifyFn: func(token string) (Principal, error) {
+		if token == "writer" {
+			return Principal{Subject: "248289761001", Role: RoleImporter, Method: MethodJWT}, nil
+		}
+		return Principal{}, ErrInvalidToken
+	}}
+	tests := []struct {
+		name     string
+		value    string
+		required Role
+		want     int
+	}{
+		{"Valid token", "Bearer writer", RoleImporter, http.StatusOK},
+		{"Lower case scheme", "bearer writer", RoleReader, http.StatusOK},
+		{"Insufficient scope", "Bearer writer", RoleAdmin, http.StatusForbidden},
+		{"Invalid token", "Bearer forged", RoleReader, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", tt.value)
+			newTestRouter(s, v, tt.required).ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("Authenticate() status = %v, want %v", w.Code, tt.want)
+			}
+			if w.Code == http.StatusUnauthorized && len(w.Header()["Www-Authenticate"]) != 2 {
+				t.Errorf("Authenticate() WWW-Authenticate = %v, want ApiKey and Bearer", w.Header()["Www-Authenticate"])
+			}
+		})
+	}
+}
+
+func TestRequireTenant(t *testing.T) {
+	s := NewService(NewMemoryRepository())
+	s.EnsureTenant(context.Background(), DefaultTenant, "Default")
+	_, operator, _ := s.createKey(context.Background(), DefaultTenant, "ops", RoleAdmin)
+	_, _, sales, _ := s.createTenant(context.Background(), "sales", "Sales")
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/tenants/:id/catalog", Authenticate(s, nil), RequireTenant(DefaultTenant), TenantExists(s), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	tests := []struct {
+		name string
+		key  string
+		path string
+		want int
+	}{
+		{"Operator", operator, "/tenants/sales/catalog?from=default", http.StatusOK},
+		{"Admin of another tenant", sales, "/tenants/sales/catalog?from=default", http.StatusForbidden},
+		{"Unknown target", operator, "/tenants/hr/catalog?from=default", http.StatusNotFound},
+		{"Unknown source", operator, "/tenants/sales/catalog?from=hr", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			req.Header.Set("Authorization", tt.key)
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("RequireTenant() status = %v, want %v", w.Code, tt.want)
+			}
+		})
+	}
+}
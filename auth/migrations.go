@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/marcospsbrito/dic/database"
+)
+
+// Migrations of the API key collections. Their versions are shared with the
+// migrations of the other packages.
+var Migrations = []database.Migration{
+	{
+		Version:     4,
+		Description: "Assign API keys created before tenancy to the default tenant",
+		Up: func(db *mgo.Database) error {
+			_, err := db.C("Key").UpdateAll(bson.M{"tenant": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"tenant": DefaultTenant}})
+			return err
+		},
+		// like migration 1, only the keys of the default tenant can go back
+		// to having no tenant
+		Down: func(db *mgo.Database) error {
+			n, err := db.C("Key").Find(bson.M{"tenant": bson.M{"$exists": true, "$ne": DefaultTenant}}).Count()
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				return fmt.Errorf("%d API keys belong to other tenants than %s: %v", n, DefaultTenant, database.ErrIrreversible)
+			}
+			_, err = db.C("Key").UpdateAll(bson.M{"tenant": DefaultTenant}, bson.M{"$unset": bson.M{"tenant": ""}})
+			return err
+		},
+	},
+	{
+		Version:     5,
+		Description: "Index the API keys by tenant",
+		// the unique hash index predates the migrations, so it is kept
+		Up: func(db *mgo.Database) error {
+			if err := db.C("Key").EnsureIndex(mgo.Index{Key: []string{"hash"}, Unique: true}); err != nil {
+				return err
+			}
+			return db.C("Key").EnsureIndexKey("tenant")
+		},
+		Down: func(db *mgo.Database) error {
+			return database.DropIndex(db.C("Key"), "tenant")
+		},
+	},
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/marcospsbrito/dic/database"
+)
+
+// Key entity is an API key. Only the hash of its secret is stored.
+type Key struct {
+	ID        bson.ObjectId `bson:"_id,omitempty" json:"id,omitempty" example:"5c8a1d5b0190b214360dc031"`
+	Tenant    string        `json:"tenant" example:"sales"`
+	Name      string        `json:"name" example:"pipeline"`
+	Hash      string        `json:"-"`
+	Prefix    string        `json:"prefix" example:"dic_Xb3k"`
+	Role      Role          `json:"role" example:"importer"`
+	Revoked   bool          `json:"revoked" example:"false"`
+	CreatedAt time.Time     `bson:"createdAt" json:"createdAt"`
+}
+
+// Repository interface defines the storage of API keys and tenants. Keys
+// are found by hash across tenants; every other key query is scoped to one.
+type Repository interface {
+	FindAll(ctx context.Context, tenant string) ([]Key, error)
+	FindByHash(ctx context.Context, hash string) (Key, error)
+	Add(ctx context.Context, k Key) error
+	Revoke(ctx context.Context, tenant string, id bson.ObjectId) error
+	FindTenants(ctx context.Context) ([]Tenant, error)
+	FindTenant(ctx context.Context, id string) (Tenant, error)
+	AddTenant(ctx context.Context, t Tenant) error
+}
+
+type keyRepository struct {
+	keys    *mgo.Collection
+	tenants *mgo.Collection
+}
+
+// NewRepository function returns a Repository impl. The collections are set
+// up by Migrations.
+func NewRepository(db *mgo.Database) Repository {
+	if db == nil {
+		return nil
+	}
+	return keyRepository{db.C("Key"), db.C("Tenant")}
+}
+
+// run runs op on the collections of r bound to a session that honours the
+// deadline of ctx
+func (r keyRepository) run(ctx context.Context, op func(r keyRepository) error) error {
+	return database.Run(ctx, r.keys.Database.Session, func(s *mgo.Session) error {
+		return op(r.with(s))
+	})
+}
+
+// read runs op like run, retrying it while the database is unavailable. op
+// must only read.
+func (r keyRepository) read(ctx context.Context, op func(r keyRepository) error) error {
+	return database.Retry(ctx, r.keys.Database.Session, func(s *mgo.Session) error {
+		return op(r.with(s))
+	})
+}
+
+// with returns r with its collections bound to s
+func (r keyRepository) with(s *mgo.Session) keyRepository {
+	r.keys, r.tenants = r.keys.With(s), r.tenants.With(s)
+	return r
+}
+
+func (r keyRepository) FindAll(ctx context.Context, tenant string) ([]Key, error) {
+	var results []Key
+	err := r.read(ctx, func(r keyRepository) error {
+		return r.keys.Find(bson.M{"tenant": tenant}).All(&results)
+	})
+	return results, err
+}
+
+func (r keyRepository) FindByHash(ctx context.Context, hash string) (Key, error) {
+	var result Key
+	err := r.read(ctx, func(r keyRepository) error {
+		return r.keys.Find(bson.M{"hash": hash}).One(&result)
+	})
+	return result, err
+}
+
+func (r keyRepository) Add(ctx context.Context, k Key) error {
+	return r.run(ctx, func(r keyRepository) error {
+		return r.keys.Insert(k)
+	})
+}
+
+func (r keyRepository) Revoke(ctx context.Context, tenant string, id bson.ObjectId) error {
+	return r.run(ctx, func(r keyRepository) error {
+		return r.keys.Update(bson.M{"_id": id, "tenant": tenant}, bson.M{"$set": bson.M{"revoked": true}})
+	})
+}
+
+func (r keyRepository) FindTenants(ctx context.Context) ([]Tenant, error) {
+	var results []Tenant
+	err := r.read(ctx, func(r keyRepository) error {
+		return r.tenants.Find(nil).Sort("_id").All(&results)
+	})
+	return results, err
+}
+
+func (r keyRepository) FindTenant(ctx context.Context, id string) (Tenant, error) {
+	var result Tenant
+	err := r.read(ctx, func(r keyRepository) error {
+		return r.tenants.FindId(id).One(&result)
+	})
+	return result, err
+}
+
+// AddTenant inserts a tenant, failing with ErrTenantExists when its id is taken
+func (r keyRepository) AddTenant(ctx context.Context, t Tenant) error {
+	err := r.run(ctx, func(r keyRepository) error {
+		return r.tenants.Insert(t)
+	})
+	if mgo.IsDup(err) {
+		return ErrTenantExists
+	}
+	return err
+}
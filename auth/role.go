@@ -0,0 +1,27 @@
+package auth
+
+// Role grants access to a group of routes
+type Role string
+
+// Roles, each one granting the access of the previous ones
+const (
+	RoleReader   Role = "reader"
+	RoleImporter Role = "importer"
+	RoleAdmin    Role = "admin"
+)
+
+var roleLevels = map[Role]int{
+	RoleReader:   1,
+	RoleImporter: 2,
+	RoleAdmin:    3,
+}
+
+func (r Role) valid() bool {
+	_, ok := roleLevels[r]
+	return ok
+}
+
+// Allows reports whether r grants the access of required
+func (r Role) Allows(required Role) bool {
+	return r.valid() && roleLevels[r] >= roleLevels[required]
+}
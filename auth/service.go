@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/marcospsbrito/dic/apierror"
+)
+
+// keyPrefix marks the secrets of API keys, so they are easy to spot in logs
+const keyPrefix = "dic_"
+
+var (
+	// ErrInvalidKey is returned when an API key is unknown or revoked
+	ErrInvalidKey = apierror.New(http.StatusUnauthorized, "auth.invalid_api_key", "Invalid API key")
+	// ErrInvalidRole is returned when a key is created with an unknown role
+	ErrInvalidRole = apierror.New(http.StatusBadRequest, "key.invalid_role", "Invalid role, use reader, importer or admin")
+)
+
+// Service interface define methods of service
+type Service interface {
+	Authenticate(ctx context.Context, secret string) (Key, error)
+	EnsureTenant(ctx context.Context, id string, name string) error
+	EnsureKey(ctx context.Context, name string, secret string, role Role) error
+	createKey(ctx context.Context, tenant string, name string, role Role) (Key, string, error)
+	listKeys(ctx context.Context, tenant string) ([]Key, error)
+	revokeKey(ctx context.Context, tenant string, id string) error
+	createTenant(ctx context.Context, id string, name string) (Tenant, Key, string, error)
+	listTenants(ctx context.Context) ([]Tenant, error)
+	findTenant(ctx context.Context, id string) (Tenant, error)
+}
+
+type keyService struct {
+	repository Repository
+}
+
+// NewService returns new Service
+func NewService(r Repository) Service {
+	return keyService{r}
+}
+
+// Authenticate returns the key of secret unless it is unknown or revoked
+func (s keyService) Authenticate(ctx context.Context, secret string) (Key, error) {
+	k, err := s.repository.FindByHash(ctx, hashSecret(secret))
+	if err == mgo.ErrNotFound || (err == nil && k.Revoked) {
+		return Key{}, ErrInvalidKey
+	}
+	return k, err
+}
+
+// EnsureTenant stores a tenant, like the default one, unless it already exists
+func (s keyService) EnsureTenant(ctx context.Context, id string, name string) error {
+	err := s.repository.AddTenant(ctx, Tenant{ID: id, Name: name, CreatedAt: time.Now()})
+	if err == ErrTenantExists {
+		return nil
+	}
+	return err
+}
+
+// EnsureKey stores a key of the default tenant with a known secret, like the
+// bootstrap admin key, unless it already exists
+func (s keyService) EnsureKey(ctx context.Context, name string, secret string, role Role) error {
+	if _, err := s.repository.FindByHash(ctx, hashSecret(secret)); err != mgo.ErrNotFound {
+		return err
+	}
+	log.WithField("name", name).Info("Creating API key")
+	return s.repository.Add(ctx, newKey(DefaultTenant, name, secret, role))
+}
+
+// createKey stores a key of tenant with a random secret. The secret is only
+// returned here; afterwards only its hash is known.
+func (s keyService) createKey(ctx context.Context, tenant string, name string, role Role) (Key, string, error) {
+	if !role.valid() {
+		return Key{}, "", ErrInvalidRole
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return Key{}, "", err
+	}
+	secret := keyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	k := newKey(tenant, name, secret, role)
+	return k, secret, s.repository.Add(ctx, k)
+}
+
+func (s keyService) listKeys(ctx context.Context, tenant string) ([]Key, error) {
+	return s.repository.FindAll(ctx, tenant)
+}
+
+func (s keyService) revokeKey(ctx context.Context, tenant string, id string) error {
+	if !bson.IsObjectIdHex(id) {
+		return mgo.ErrNotFound
+	}
+	return s.repository.Revoke(ctx, tenant, bson.ObjectIdHex(id))
+}
+
+// createTenant stores a tenant and the admin key its members use to create
+// their own keys
+func (s keyService) createTenant(ctx context.Context, id string, name string) (Tenant, Key, string, error) {
+	if !tenantID.MatchString(id) {
+		return Tenant{}, Key{}, "", ErrInvalidTenant
+	}
+	t := Tenant{ID: id, Name: name, CreatedAt: time.Now()}
+	if err := s.repository.AddTenant(ctx, t); err != nil {
+		return Tenant{}, Key{}, "", err
+	}
+	log.WithField("tenant", id).Info("Tenant created")
+	k, secret, err := s.createKey(ctx, id, "admin", RoleAdmin)
+	return t, k, secret, err
+}
+
+func (s keyService) listTenants(ctx context.Context) ([]Tenant, error) {
+	return s.repository.FindTenants(ctx)
+}
+
+func (s keyService) findTenant(ctx context.Context, id string) (Tenant, error) {
+	return s.repository.FindTenant(ctx, id)
+}
+
+func newKey(tenant string, name string, secret string, role Role) Key {
+	prefix := secret
+	if len(prefix) > len(keyPrefix)+4 {
+		prefix = prefix[:len(keyPrefix)+4]
+	}
+	return Key{
+		ID:        bson.NewObjectId(),
+		Tenant:    tenant,
+		Name:      name,
+		Hash:      hashSecret(secret),
+		Prefix:    prefix,
+		Role:      role,
+		CreatedAt: time.Now(),
+	}
+}
+
+// hashSecret returns the hex encoded sha256 of an API key secret. Secrets
+// are random, so a fast unsalted hash is enough to make stored keys useless.
+func hashSecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
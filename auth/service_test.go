@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+func Test_keyService_Authenticate(t *testing.T) {
+	s := NewService(NewMemoryRepository())
+	valid, validSecret, _ := s.createKey(context.Background(), DefaultTenant, "pipeline", RoleImporter)
+	revoked, revokedSecret, _ := s.createKey(context.Background(), DefaultTenant, "old", RoleReader)
+	s.revokeKey(context.Background(), DefaultTenant, revoked.ID.Hex())
+	tests := []struct {
+		name    string
+		secret  string
+		want    bson.ObjectId
+		wantErr error
+	}{
+		{"Valid key", validSecret, valid.ID, nil},
+		{"Revoked key", revokedSecret, "", ErrInvalidKey},
+		{"Unknown key", "dic_unknown", "", ErrInvalidKey},
+		{"Empty key", "", "", ErrInvalidKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Authenticate(context.Background(), tt.secret)
+			if err != tt.wantErr {
+				t.Errorf("keyService.Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got.ID != tt.want {
+				t.Errorf("keyService.Authenticate() = %v, want %v", got.ID, tt.want)
+			}
+		})
+	}
+}
+
+func Test_keyService_createKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    Role
+		wantErr error
+	}{
+		{"Reader key", RoleReader, nil},
+		{"Admin key", RoleAdmin, nil},
+		{"Unknown role", Role("owner"), ErrInvalidRole},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewMemoryRepository()
+			got, secret, err := NewService(r).createKey(context.Background(), DefaultTenant, "key", tt.role)
+			if err != tt.wantErr {
+				t.Errorf("keyService.createKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if !strings.HasPrefix(secret, got.Prefix) || got.Hash == secret {
+				t.Errorf("keyService.createKey() = %v, secret %v", got, secret)
+			}
+			if stored, _ := r.FindAll(context.Background(), DefaultTenant); len(stored) != 1 || stored[0].Hash != hashSecret(secret) {
+				t.Errorf("keyService.createKey() stored %v", stored)
+			}
+		})
+	}
+}
+
+func Test_keyService_EnsureKey(t *testing.T) {
+	r := NewMemoryRepository()
+	s := NewService(r)
+	for i := 0; i < 2; i++ {
+		if err := s.EnsureKey(context.Background(), "admin", "bootstrap", RoleAdmin); err != nil {
+			t.Errorf("keyService.EnsureKey() error = %v", err)
+		}
+	}
+	if stored, _ := r.FindAll(context.Background(), DefaultTenant); len(stored) != 1 {
+		t.Errorf("keyService.EnsureKey() stored %v keys, want 1", len(stored))
+	}
+}
+
+func Test_keyService_revokeKey(t *testing.T) {
+	s := NewService(NewMemoryRepository())
+	k, _, _ := s.createKey(context.Background(), DefaultTenant, "pipeline", RoleReader)
+	tests := []struct {
+		name    string
+		id      string
+		wantErr error
+	}{
+		{"Revoke key", k.ID.Hex(), nil},
+		{"Unknown key", bson.NewObjectId().Hex(), mgo.ErrNotFound},
+		{"Invalid id", "pipeline", mgo.ErrNotFound},
+	}
+	if err := s.revokeKey(context.Background(), "sales", k.ID.Hex()); err != mgo.ErrNotFound {
+		t.Errorf("keyService.revokeKey() of another tenant error = %v, want %v", err, mgo.ErrNotFound)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.revokeKey(context.Background(), DefaultTenant, tt.id); err != tt.wantErr {
+				t.Errorf("keyService.revokeKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_keyService_createTenant(t *testing.T) {
+	s := NewService(NewMemoryRepository())
+	s.EnsureTenant(context.Background(), DefaultTenant, "Default")
+	tests := []struct {
+		name    string
+		id      string
+		wantErr error
+	}{
+		{"Create tenant", "sales", nil},
+		{"Existing tenant", DefaultTenant, ErrTenantExists},
+		{"Upper case id", "Sales", ErrInvalidTenant},
+		{"Id with spaces", "sales team", ErrInvalidTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, k, secret, err := s.createTenant(context.Background(), tt.id, "Sales")
+			if err != tt.wantErr {
+				t.Errorf("keyService.createTenant() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			got, err := s.Authenticate(context.Background(), secret)
+			if err != nil || got.ID != k.ID || got.Tenant != tt.id || got.Role != RoleAdmin {
+				t.Errorf("keyService.createTenant() key = %v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestRole_Allows(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     Role
+		required Role
+		want     bool
+	}{
+		{"Admin imports", RoleAdmin, RoleImporter, true},
+		{"Importer reads", RoleImporter, RoleReader, true},
+		{"Reader cannot import", RoleReader, RoleImporter, false},
+		{"Importer cannot admin", RoleImporter, RoleAdmin, false},
+		{"Unknown role", Role(""), RoleReader, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.role.Allows(tt.required); got != tt.want {
+				t.Errorf("Role.Allows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/marcospsbrito/dic/apierror"
+)
+
+// DefaultTenant owns the records created before tenancy and the bootstrap
+// admin key. Its admins are the operators that manage the other tenants.
+const DefaultTenant = "default"
+
+var (
+	// ErrInvalidTenant is returned when a tenant id is not a lower case slug
+	ErrInvalidTenant = apierror.New(http.StatusBadRequest, "tenant.invalid", "Invalid tenant id, use lower case letters, digits and dashes")
+	// ErrTenantExists is returned when a tenant id is already taken
+	ErrTenantExists = apierror.New(http.StatusConflict, "tenant.exists", "Tenant already exists")
+	// ErrTenantNotFound is returned when a request names an unknown tenant
+	ErrTenantNotFound = apierror.New(http.StatusNotFound, "tenant.not_found", "Tenant not found")
+)
+
+var tenantID = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}$`)
+
+// Tenant entity is a group of callers sharing the same companies
+type Tenant struct {
+	ID        string    `bson:"_id" json:"id" example:"sales"`
+	Name      string    `json:"name" example:"Sales"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apex/log"
+
+	"github.com/marcospsbrito/dic/auth"
+	"github.com/marcospsbrito/dic/company"
+	"github.com/marcospsbrito/dic/config"
+	"github.com/marcospsbrito/dic/database"
+)
+
+// Exit codes of the commands
+const (
+	exitOK       = 0
+	exitFailure  = 1
+	exitUsage    = 2
+	exitRejected = 3
+	exitNotFound = 4
+)
+
+// invocation is a command called with its configuration, the flags it was
+// loaded from, its positional arguments and the writer of its output
+type invocation struct {
+	cfg   config.Config
+	flags config.Flags
+	args  []string
+	out   io.Writer
+}
+
+// command is a subcommand of the binary. setup registers its own flags on fs
+// and returns the function running it.
+type command struct {
+	params  string
+	summary string
+	args    int
+	setup   func(fs *flag.FlagSet) func(inv invocation) error
+}
+
+// commands by name, the first argument or two of the command line
+var commands = map[string]command{
+	"serve": {"", "Serve the API", 0, func(*flag.FlagSet) func(invocation) error {
+		return serve
+	}},
+	"import catalog":  {"<file>", "Add the companies of a catalog file that are missing", 1, importCatalogCommand},
+	"import websites": {"<file>", "Merge the websites of a file into the companies", 1, importWebsitesCommand},
+	"export":          {"", "Write every company of a tenant", 0, exportCommand},
+	"match":           {"<name> <zipcode>", "Find the company matching a name and zipcode", 2, matchCommand},
+	"migrate":         {"", "Create the indexes and update the records of the database", 0, migrateCommand},
+}
+
+// run runs the command of args, writing its output to out, and returns its
+// exit code. The API is served when no command is given.
+func run(args []string, out io.Writer) int {
+	name, rest := commandOf(args)
+	if name == "help" {
+		usage(out)
+		return exitOK
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+		usage(os.Stderr)
+		return exitUsage
+	}
+	fs := flag.NewFlagSet("dic "+name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dic %s [flags] %s\n\n%s\n\nFlags:\n", name, cmd.params, cmd.summary)
+		fs.PrintDefaults()
+	}
+	flags := config.Bind(fs)
+	runCommand := cmd.setup(fs)
+	if err := fs.Parse(rest); err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != cmd.args {
+		fmt.Fprintf(os.Stderr, "Usage: dic %s [flags] %s\n", name, cmd.params)
+		return exitUsage
+	}
+	cfg, err := config.Load(*flags)
+	if err != nil {
+		log.WithError(err).Error("Failed to load configuration")
+		return exitFailure
+	}
+	if flags.PrintConfig {
+		if err := cfg.Print(out); err != nil {
+			log.WithError(err).Error("Failed to print configuration")
+			return exitFailure
+		}
+		return exitOK
+	}
+	log.SetLevelFromString(cfg.LogLevel)
+	err = runCommand(invocation{cfg: cfg, flags: *flags, args: fs.Args(), out: out})
+	if err != nil {
+		log.WithError(err).WithField("command", name).Error("Command failed")
+	}
+	return exitCode(err)
+}
+
+// commandOf returns the name of the command of args and its remaining
+// arguments. Arguments starting with flags serve the API, as the binary did
+// before it had commands.
+func commandOf(args []string) (string, []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help" {
+		return "serve", args
+	}
+	switch args[0] {
+	case "-h", "--help":
+		return "help", nil
+	case "import":
+		if len(args) < 2 {
+			return "import", nil
+		}
+		return "import " + args[1], args[2:]
+	}
+	return args[0], args[1:]
+}
+
+// exitCode returns the exit code of a command that ended with err
+func exitCode(err error) int {
+	switch err {
+	case nil:
+		return exitOK
+	case company.ErrRowsRejected:
+		return exitRejected
+	case company.ErrCompanyNotFound:
+		return exitNotFound
+	}
+	return exitFailure
+}
+
+// usage writes the commands and exit codes to w
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "Usage: dic <command> [flags] [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-32s %s\n", strings.TrimSpace(name+" "+commands[name].params), commands[name].summary)
+	}
+	fmt.Fprintln(w, "\nRun dic <command> --help for the flags of a command, which include every setting.")
+	fmt.Fprintf(w, "\nExit codes: %d success, %d failure, %d invalid arguments, %d rows rejected, %d company not found\n",
+		exitOK, exitFailure, exitUsage, exitRejected, exitNotFound)
+}
+
+// withCommands runs fn with the company Commands of tenant over the
+// configured storage. The context of fn is canceled on SIGINT or SIGTERM,
+// which interrupts imports at their checkpoint.
+func withCommands(cfg config.Config, tenant string, fn func(ctx context.Context, c company.Commands) error) error {
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return fmt.Errorf("opening database: %v", err)
+	}
+	defer closeDatabase(db)
+	if err := migrateOnStartup(cfg, db); err != nil {
+		return fmt.Errorf("migrating database: %v", err)
+	}
+	repo, _ := newRepositories(db)
+	s, err := newService(cfg, repo)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	return fn(ctx, company.NewCommands(s.ForTenant(tenant)))
+}
+
+func importCatalogCommand(fs *flag.FlagSet) func(invocation) error {
+	tenant := fs.String("tenant", auth.DefaultTenant, "tenant of the companies")
+	return func(inv invocation) error {
+		return withCommands(inv.cfg, *tenant, func(ctx context.Context, c company.Commands) error {
+			return c.ImportCatalog(ctx, inv.args[0], inv.out)
+		})
+	}
+}
+
+func importWebsitesCommand(fs *flag.FlagSet) func(invocation) error {
+	tenant := fs.String("tenant", auth.DefaultTenant, "tenant of the companies")
+	opts := company.ImportOptions{Caller: "cli"}
+	fs.StringVar(&opts.IdempotencyKey, "idempotency-key", "", "key to safely retry the import")
+	fs.StringVar(&opts.Source, "source", company.SourceWebsites, "source of the file selecting its validation rules")
+	fs.BoolVar(&opts.Atomic, "atomic", false, "commit all rows or none of them")
+	fs.StringVar(&opts.Format, "format", "", "file format (csv, json, ndjson or xlsx), detected from the file name when empty")
+	fs.StringVar(&opts.Sheet, "sheet", "", "worksheet of xlsx files, the first one when empty")
+	fs.StringVar(&opts.Charset, "charset", "", "charset of text files, detected when empty")
+	fs.StringVar(&opts.Delimiter, "delimiter", "", "delimiter of csv files, detected when empty")
+	fs.StringVar(&opts.Comment, "comment", "", "comment character of csv files or none, detected when empty")
+	fs.StringVar(&opts.Header, "header", "", "whether the first row of csv files is a header, detected when empty")
+	return func(inv invocation) error {
+		return withCommands(inv.cfg, *tenant, func(ctx context.Context, c company.Commands) error {
+			return c.ImportWebsites(ctx, inv.args[0], opts, inv.out)
+		})
+	}
+}
+
+func exportCommand(fs *flag.FlagSet) func(invocation) error {
+	tenant := fs.String("tenant", auth.DefaultTenant, "tenant of the companies")
+	format := fs.String("format", company.ExportJSON, "output format: json, ndjson or csv")
+	output := fs.String("output", "", "output `file`, the standard output when empty")
+	return func(inv invocation) error {
+		return withCommands(inv.cfg, *tenant, func(ctx context.Context, c company.Commands) error {
+			if *output == "" {
+				return c.Export(ctx, *format, inv.out)
+			}
+			return writeFile(*output, func(w io.Writer) error {
+				return c.Export(ctx, *format, w)
+			})
+		})
+	}
+}
+
+// writeFile writes the file of path with write through a temporary file,
+// renamed to path only once write succeeded, so a failed command neither
+// leaves a partial file nor truncates an existing one
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func matchCommand(fs *flag.FlagSet) func(invocation) error {
+	tenant := fs.String("tenant", auth.DefaultTenant, "tenant of the companies")
+	return func(inv invocation) error {
+		return withCommands(inv.cfg, *tenant, func(ctx context.Context, c company.Commands) error {
+			return c.Match(ctx, inv.args[0], inv.args[1], inv.out)
+		})
+	}
+}
+
+// migrateCommand applies the migrations up to a version, the latest one by
+// default, or lists them
+func migrateCommand(fs *flag.FlagSet) func(invocation) error {
+	to := fs.Int("to", -1, "`version` to migrate to, reverting the later migrations, the latest one when negative")
+	status := fs.Bool("status", false, "list the migrations and whether they are applied instead")
+	return func(inv invocation) error {
+		db, err := openDatabase(inv.cfg)
+		if err != nil {
+			return fmt.Errorf("opening database: %v", err)
+		}
+		defer closeDatabase(db)
+		if db == nil {
+			fmt.Fprintln(inv.out, "Nothing to migrate with the memory storage")
+			return nil
+		}
+		m, err := database.NewMigrator(db, migrations()...)
+		if err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		ctx, cancel := company.WithTimeout(ctx, inv.cfg.MigrationTimeout)
+		defer cancel()
+		if *status {
+			statuses, err := m.Status(ctx)
+			writeMigrations(inv.out, statuses, "pending")
+			return err
+		}
+		target := *to
+		if target < 0 {
+			target = m.Latest()
+		}
+		ran, err := m.Migrate(ctx, target)
+		writeMigrations(inv.out, ran, "reverted")
+		if err == nil && len(ran) == 0 {
+			fmt.Fprintf(inv.out, "Database already at version %d\n", target)
+		}
+		return err
+	}
+}
+
+// writeMigrations writes the version, state and description of migrations
+// to w, describing the ones not applied as unapplied
+func writeMigrations(w io.Writer, migrations []database.MigrationStatus, unapplied string) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, m := range migrations {
+		state := unapplied
+		if m.Applied() {
+			state = "applied " + m.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", m.Version, state, m.Description)
+	}
+	tw.Flush()
+}
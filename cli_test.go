@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/marcospsbrito/dic/database"
+)
+
+func Test_commandOf(t *testing.T) {
+	tests := []struct {
+		args     []string
+		wantName string
+		wantRest []string
+	}{
+		{nil, "serve", nil},
+		{[]string{"--log-level", "info"}, "serve", []string{"--log-level", "info"}},
+		{[]string{"--help"}, "help", nil},
+		{[]string{"import", "catalog", "q1.csv"}, "import catalog", []string{"q1.csv"}},
+		{[]string{"import"}, "import", nil},
+		{[]string{"match", "acme", "01234"}, "match", []string{"acme", "01234"}},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			name, rest := commandOf(tt.args)
+			if name != tt.wantName || !reflect.DeepEqual(rest, tt.wantRest) {
+				t.Errorf("commandOf() = %q, %q, want %q, %q", name, rest, tt.wantName, tt.wantRest)
+			}
+		})
+	}
+}
+
+func Test_exportCommand_output(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "companies.csv")
+	memory := []string{"--storage", "memory", "--init-file", "", "--rules-file", "resource/rules.yaml", "--output", output}
+	tests := []struct {
+		name     string
+		args     []string
+		want     string
+		wantCode int
+	}{
+		{"Export written", append([]string{"export", "--format", "csv"}, memory...), "name;addresszip;website\n", exitOK},
+		{"Failed export keeps the file", append([]string{"export", "--format", "xml"}, memory...), "name;addresszip;website\n", exitFailure},
+		{"Invalid configuration keeps the file", append([]string{"export", "--log-level", "verbose"}, memory...), "name;addresszip;website\n", exitFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := run(tt.args, ioutil.Discard); code != tt.wantCode {
+				t.Errorf("run() = %v, want %v", code, tt.wantCode)
+			}
+			if got, _ := ioutil.ReadFile(output); string(got) != tt.want {
+				t.Errorf("output file = %q, want %q", got, tt.want)
+			}
+			if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+				t.Errorf("export left %v files, want 1", len(files))
+			}
+		})
+	}
+}
+
+func Test_run(t *testing.T) {
+	dir := t.TempDir()
+	catalog := filepath.Join(dir, "catalog.csv")
+	ioutil.WriteFile(catalog, []byte("name;addresszip\nacme;01234\nglobex\n"), 0600)
+	memory := []string{"--storage", "memory", "--init-file", catalog, "--rules-file", "resource/rules.yaml"}
+	tests := []struct {
+		name     string
+		args     []string
+		want     string
+		wantCode int
+	}{
+		{"Help", []string{"help"}, "Commands:", exitOK},
+		{"Unknown command", []string{"load"}, "", exitUsage},
+		{"Missing argument", append([]string{"match"}, memory...), "", exitUsage},
+		{"Invalid configuration", []string{"export", "--log-level", "verbose"}, "", exitFailure},
+		{"Rows rejected", append(append([]string{"import", "catalog"}, memory...), catalog), "Merged:    1", exitRejected},
+		{"Company not found", append(append([]string{"match"}, memory...), "acme", "01234"), "", exitNotFound},
+		{"Export", append([]string{"export", "--format", "csv"}, memory...), "name;addresszip;website\n", exitOK},
+		{"Print configuration", append([]string{"migrate", "--print-config"}, memory...), "storage: memory", exitOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if code := run(tt.args, &out); code != tt.wantCode {
+				t.Errorf("run() = %v, want %v", code, tt.wantCode)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("run() output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_migrations(t *testing.T) {
+	m, err := database.NewMigrator(nil, migrations()...)
+	if err != nil {
+		t.Fatalf("database.NewMigrator() error = %v", err)
+	}
+	if m.Latest() != len(migrations()) {
+		t.Errorf("database.NewMigrator() latest = %v, want the %v migrations numbered in sequence", m.Latest(), len(migrations()))
+	}
+}
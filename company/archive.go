@@ -0,0 +1,139 @@
+package company
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/klauspost/compress/zstd"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/marcospsbrito/dic/tracing"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	zipMagic   = []byte("PK\x03\x04")
+)
+
+// compressionExtensions are stripped from file names before detecting their format
+var compressionExtensions = []string{".gz", ".gzip", ".bz2", ".zst", ".zstd"}
+
+// decompress returns a stream of the uncompressed content of f when it is
+// gzip, bzip2 or zstd compressed, or f itself otherwise
+func decompress(f io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		log.Debug("Decompressing gzip stream")
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, bzip2Magic):
+		log.Debug("Decompressing bzip2 stream")
+		return ioutil.NopCloser(bzip2.NewReader(br)), nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		log.Debug("Decompressing zstd stream")
+		d, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return ioutil.NopCloser(br), nil
+}
+
+// isZipArchive reports whether f starts with a zip local file header. f is
+// rewound afterwards when it can seek, otherwise the peeked bytes are kept
+// in the returned reader.
+func isZipArchive(f io.Reader) (bool, io.Reader) {
+	if seeker, ok := f.(io.ReadSeeker); ok {
+		magic := make([]byte, len(zipMagic))
+		n, _ := io.ReadFull(seeker, magic)
+		if _, err := seeker.Seek(0, io.SeekStart); err == nil {
+			return bytes.Equal(magic[:n], zipMagic), f
+		}
+	}
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(len(zipMagic))
+	return bytes.Equal(magic, zipMagic), br
+}
+
+// importFile decompresses and transcodes f to UTF-8 and imports its rows,
+// or the rows of every supported file when f is a zip archive
+func (s companyService) importFile(ctx context.Context, f io.Reader, opts ImportOptions, sink rowSink) (report Report, err error) {
+	ctx, span := startSpan(ctx, "importFile", attribute.String("import.format", opts.Format))
+	defer func() {
+		span.SetAttributes(attribute.Int("import.rows", report.Rows), attribute.Int("import.merged", report.Merged))
+		tracing.End(span, err)
+	}()
+	if opts.Format != FormatXLSX {
+		var archive bool
+		if archive, f = isZipArchive(f); archive {
+			return s.importArchive(ctx, f, opts, sink)
+		}
+	}
+	r, err := decompress(f)
+	if err != nil {
+		return Report{}, err
+	}
+	defer r.Close()
+	if opts.Format == FormatXLSX {
+		return s.iterateFileAndCall(ctx, r, opts, sink)
+	}
+	text, err := decodeText(r, opts.Charset)
+	if err != nil {
+		return Report{}, err
+	}
+	return s.iterateFileAndCall(ctx, text, opts, sink)
+}
+
+// importArchive imports each supported file of a zip archive in turn,
+// reporting the results of every file. Entries are streamed; the archive
+// itself is only buffered when f cannot be read at random.
+func (s companyService) importArchive(ctx context.Context, f io.Reader, opts ImportOptions, sink rowSink) (Report, error) {
+	var report Report
+	ra, size, err := readerAt(f)
+	if err != nil {
+		return report, err
+	}
+	archive, err := zip.NewReader(ra, size)
+	if err != nil {
+		return report, err
+	}
+	for _, file := range archive.File {
+		format, ok := formatByName(file.Name)
+		if !ok || file.FileInfo().IsDir() || isHiddenEntry(file.Name) {
+			log.WithField("file", file.Name).Debug("Skipping archive entry")
+			continue
+		}
+		entryOpts := opts
+		entryOpts.Format = format
+		entryOpts.skip = opts.skip - report.Rows
+		rc, err := file.Open()
+		if err != nil {
+			return report, err
+		}
+		fileReport, err := s.importFile(ctx, rc, entryOpts, sink)
+		rc.Close()
+		fileReport.File = file.Name
+		report.add(fileReport)
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func isHiddenEntry(name string) bool {
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), ".")
+}
@@ -0,0 +1,150 @@
+package company
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const archiveTestRows = "a;12345\nb;12346\n"
+
+// bzip2 has no writer in the standard library, so the payload is kept compressed
+var bzip2Rows = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x53, 0xfa, 0x39, 0x14, 0x00, 0x00,
+	0x07, 0x49, 0x00, 0x00, 0x10, 0x3f, 0x08, 0x30, 0x00, 0x20, 0x00, 0x21, 0x28, 0x0d, 0x01, 0x00,
+	0x30, 0x4b, 0x0a, 0x2c, 0x46, 0x1b, 0xdc, 0x78, 0xbb, 0x92, 0x29, 0xc2, 0x84, 0x82, 0x9f, 0xd1,
+	0xc8, 0xa0,
+}
+
+func gzipBytes(t *testing.T, content string) []byte {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	w.Write([]byte(content))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func zstdBytes(t *testing.T, content string) []byte {
+	var b bytes.Buffer
+	w, err := zstd.NewWriter(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(content))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func zipBytes(t *testing.T, files map[string][]byte, order ...string) []byte {
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for _, name := range order {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(files[name])
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func Test_decompress(t *testing.T) {
+	tests := []struct {
+		name string
+		f    io.Reader
+	}{
+		{"Plain text", bytes.NewBufferString(archiveTestRows)},
+		{"Gzip", bytes.NewBuffer(gzipBytes(t, archiveTestRows))},
+		{"Bzip2", bytes.NewBuffer(bzip2Rows)},
+		{"Zstd", bytes.NewBuffer(zstdBytes(t, archiveTestRows))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := decompress(tt.f)
+			if err != nil {
+				t.Fatalf("decompress() error = %v", err)
+			}
+			defer r.Close()
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("decompress() read error = %v", err)
+			}
+			if string(got) != archiveTestRows {
+				t.Errorf("decompress() = %q, want %q", got, archiveTestRows)
+			}
+		})
+	}
+}
+
+func Test_companyService_importFile(t *testing.T) {
+	archive := zipBytes(t, map[string][]byte{
+		"clients/":             nil,
+		"clients/a.csv":        []byte(archiveTestRows),
+		"clients/b.json.gz":    gzipBytes(t, `[{"name": "c", "zipcode": "12347"}]`),
+		"clients/readme.md":    []byte("not imported"),
+		"__MACOSX/._a.csv":     []byte("junk"),
+		"clients/c.ndjson.zst": zstdBytes(t, `{"name": "d", "zipcode": "1"}`),
+	}, "clients/", "clients/a.csv", "clients/b.json.gz", "clients/readme.md", "__MACOSX/._a.csv", "clients/c.ndjson.zst")
+	tests := []struct {
+		name      string
+		f         io.Reader
+		wantRows  [][]string
+		wantFiles []string
+	}{
+		{"Import gzip file",
+			bytes.NewBuffer(gzipBytes(t, archiveTestRows)),
+			[][]string{{"a", "12345"}, {"b", "12346"}}, nil},
+		{"Import zip archive",
+			bytes.NewReader(archive),
+			[][]string{{"a", "12345"}, {"b", "12346"}, {"c", "12347"}, {"d", "1"}},
+			[]string{"clients/a.csv", "clients/b.json.gz", "clients/c.ndjson.zst"}},
+		{"Import zip archive stream",
+			bytes.NewBuffer(archive),
+			[][]string{{"a", "12345"}, {"b", "12346"}, {"c", "12347"}, {"d", "1"}},
+			[]string{"clients/a.csv", "clients/b.json.gz", "clients/c.ndjson.zst"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rows [][]string
+			handler := rowHandler(func(ctx context.Context, fields []string) error {
+				rows = append(rows, fields)
+				if fields[1] == "1" {
+					return errors.New("Invalid Zipcode lenght")
+				}
+				return nil
+			})
+			report, err := companyService{}.importFile(context.Background(), tt.f, ImportOptions{}, handler)
+			if err != nil {
+				t.Fatalf("companyService.importFile() error = %v", err)
+			}
+			if !reflect.DeepEqual(rows, tt.wantRows) {
+				t.Errorf("companyService.importFile() rows = %v, want %v", rows, tt.wantRows)
+			}
+			var files []string
+			for _, f := range report.Files {
+				files = append(files, f.File)
+			}
+			if !reflect.DeepEqual(files, tt.wantFiles) {
+				t.Errorf("companyService.importFile() files = %v, want %v", files, tt.wantFiles)
+			}
+			if report.Rows != len(tt.wantRows) || report.rejectedRows() != report.Rows-report.Merged {
+				t.Errorf("companyService.importFile() report = %+v", report)
+			}
+		})
+	}
+}
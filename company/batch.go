@@ -0,0 +1,128 @@
+package company
+
+import (
+	"context"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// defaultBatchSize is the number of rows written together by imports when
+// ImportConfig sets none
+const defaultBatchSize = 500
+
+// rowSink imports the valid rows of a file through the stages of the import
+// pipeline. parse and match run concurrently, on different rows and batches,
+// while write is called for one batch at a time, in the order of the file.
+// Each stage rejects a row by setting its err, and fails only when the
+// whole batch cannot be handled.
+type rowSink interface {
+	parse(row *importRow)
+	match(ctx context.Context, rows []*importRow) error
+	write(ctx context.Context, rows []*importRow) error
+}
+
+type rowHandler func(ctx context.Context, fields []string) error
+
+func (c rowHandler) parse(row *importRow) {}
+
+func (c rowHandler) match(ctx context.Context, rows []*importRow) error {
+	return nil
+}
+
+// write passes each row to c in order
+func (c rowHandler) write(ctx context.Context, rows []*importRow) error {
+	for _, row := range rows {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if row.pending() {
+			row.err = c(ctx, row.fields)
+		}
+	}
+	return nil
+}
+
+// websiteSink parses rows into the website of a company with toCompany,
+// matches the company and writes the website through store, which returns
+// the outcome of each company in order
+type websiteSink struct {
+	repository Repository
+	toCompany  func(fields []string) (Company, error)
+	store      func(ctx context.Context, companies []Company) ([]error, error)
+}
+
+func (w websiteSink) parse(row *importRow) {
+	row.company, row.err = w.toCompany(row.fields)
+}
+
+// match sets the ID of the company of each row to the one of the company it
+// matches, rejecting the rows matching none
+func (w websiteSink) match(ctx context.Context, rows []*importRow) error {
+	companies, valid := companiesOf(rows)
+	if len(companies) == 0 {
+		return nil
+	}
+	matches, err := w.repository.MatchCompanies(ctx, companies)
+	if err != nil {
+		return err
+	}
+	for i, m := range matches {
+		if m.ID == "" {
+			valid[i].err = mgo.ErrNotFound
+			continue
+		}
+		valid[i].company.ID = m.ID
+	}
+	return nil
+}
+
+func (w websiteSink) write(ctx context.Context, rows []*importRow) error {
+	companies, valid := companiesOf(rows)
+	if len(companies) == 0 {
+		return nil
+	}
+	outcomes, err := w.store(ctx, companies)
+	if err != nil {
+		return err
+	}
+	for i, err := range outcomes {
+		valid[i].err = err
+	}
+	return nil
+}
+
+// companiesOf returns the companies of the pending rows, and these rows
+func companiesOf(rows []*importRow) ([]Company, []*importRow) {
+	var companies []Company
+	var valid []*importRow
+	for _, row := range rows {
+		if row.pending() {
+			companies = append(companies, row.company)
+			valid = append(valid, row)
+		}
+	}
+	return companies, valid
+}
+
+// mergeSink returns the rowSink merging the websites of the rows into the
+// companies
+func (s companyService) mergeSink() rowSink {
+	return websiteSink{s.repository, s.validateAndParseToEntity, s.repository.MergeWebsites}
+}
+
+// stageSink returns the rowSink staging the websites of the rows for the
+// import id
+func (s companyService) stageSink(id bson.ObjectId) rowSink {
+	return websiteSink{s.repository, s.validateAndParseToEntity, func(ctx context.Context, companies []Company) ([]error, error) {
+		return s.repository.StageWebsites(ctx, id, companies)
+	}}
+}
+
+// batchSize returns the number of rows written together by imports
+func (c ImportConfig) batchSize() int {
+	if c.BatchSize <= 0 {
+		return defaultBatchSize
+	}
+	return c.BatchSize
+}
@@ -0,0 +1,83 @@
+package company
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// CatalogVersion records the content of a catalog file last loaded into a
+// tenant, so that loading it again is skipped until the file changes
+type CatalogVersion struct {
+	Tenant   string    `json:"-"`
+	File     string    `json:"file" example:"q1_catalog.csv"`
+	Hash     string    `json:"hash" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	Rows     int       `json:"rows" example:"1000"`
+	Added    int       `json:"added" example:"12"`
+	Removed  int       `json:"removed" example:"3"`
+	LoadedAt time.Time `bson:"loadedAt" json:"loadedAt"`
+}
+
+// companyKey identifies the company of a catalog row
+type companyKey struct {
+	name    string
+	zipcode Zipcode
+}
+
+// catalogRows collects the companies of the rows of a catalog file
+type catalogRows map[companyKey]Company
+
+// collect returns the rowHandler adding the company of each row to rows,
+// whose catalog is file
+func (rows catalogRows) collect(file string) rowHandler {
+	return func(ctx context.Context, fields []string) error {
+		if len(fields) < 2 {
+			return RuleViolation{RuleMissingFields, "Missing fields"}
+		}
+		zipcode, _ := strconv.ParseInt(fields[1], 10, 0)
+		c := Company{Name: fields[0], Zipcode: Zipcode(zipcode), Catalog: file}
+		rows[companyKey{c.Name, c.Zipcode}] = c
+		return nil
+	}
+}
+
+// sorted returns the companies of rows ordered by name, then by zipcode
+func (rows catalogRows) sorted() []Company {
+	companies := make([]Company, 0, len(rows))
+	for _, c := range rows {
+		companies = append(companies, c)
+	}
+	sort.Slice(companies, func(i, j int) bool {
+		if companies[i].Name != companies[j].Name {
+			return companies[i].Name < companies[j].Name
+		}
+		return companies[i].Zipcode < companies[j].Zipcode
+	})
+	return companies
+}
+
+// delta returns the companies of rows to upsert, those missing from existing
+// or in no catalog yet, in the order of sorted, and the ids of the companies
+// of existing loaded from file that are no longer in rows. Companies of other
+// catalogs are left to them.
+func (rows catalogRows) delta(file string, existing []Company) ([]Company, []bson.ObjectId) {
+	stored := make(map[companyKey]Company, len(existing))
+	var removed []bson.ObjectId
+	for _, c := range existing {
+		key := companyKey{c.Name, c.Zipcode}
+		stored[key] = c
+		if _, ok := rows[key]; !ok && c.Catalog == file {
+			removed = append(removed, c.ID)
+		}
+	}
+	var upserts []Company
+	for _, c := range rows.sorted() {
+		if e, ok := stored[companyKey{c.Name, c.Zipcode}]; !ok || e.Catalog == "" {
+			upserts = append(upserts, c)
+		}
+	}
+	return upserts, removed
+}
@@ -0,0 +1,80 @@
+package company
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+func Test_catalogRows_delta(t *testing.T) {
+	kept, stale, other, added := bson.NewObjectId(), bson.NewObjectId(), bson.NewObjectId(), bson.NewObjectId()
+	rows := catalogRows{
+		{"acme", 1}:    {Name: "acme", Zipcode: 1, Catalog: "q1.csv"},
+		{"globex", 2}:  {Name: "globex", Zipcode: 2, Catalog: "q1.csv"},
+		{"initech", 3}: {Name: "initech", Zipcode: 3, Catalog: "q1.csv"},
+		{"hooli", 4}:   {Name: "hooli", Zipcode: 4, Catalog: "q1.csv"},
+	}
+	existing := []Company{
+		{ID: kept, Name: "acme", Zipcode: 1, Catalog: "q1.csv"},
+		{ID: stale, Name: "umbrella", Zipcode: 5, Catalog: "q1.csv"},
+		{ID: other, Name: "globex", Zipcode: 2, Catalog: "q2.csv"},
+		{ID: added, Name: "initech", Zipcode: 3},
+		{Name: "soylent", Zipcode: 6},
+	}
+	upserts, removed := rows.delta("q1.csv", existing)
+	var names []string
+	for _, c := range upserts {
+		names = append(names, c.Name)
+	}
+	if want := []string{"hooli", "initech"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("catalogRows.delta() upserts = %v, want %v", names, want)
+	}
+	if want := []bson.ObjectId{stale}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("catalogRows.delta() removed = %v, want %v", removed, want)
+	}
+}
+
+func Test_companyService_loadCatalog(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "catalog.csv")
+	s := NewService(NewMemoryRepository(), ImportConfig{}, Timeouts{}).(companyService)
+	ctx := context.Background()
+	if err := s.repository.Add(ctx, Company{Name: "initech", Zipcode: 3}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    Report
+	}{
+		{"First load", "name;addresszip\nacme;00001\nglobex;00002\n", Report{Rows: 2, Merged: 2}},
+		{"Same file", "name;addresszip\nacme;00001\nglobex;00002\n", Report{Rows: 2, Unchanged: true}},
+		{"Changed file", "name;addresszip\nacme;00001\ninitech;00003\nhooli;00004\n", Report{Rows: 3, Merged: 2, Removed: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ioutil.WriteFile(file, []byte(tt.content), 0600)
+			got, err := s.loadCatalog(ctx, file)
+			if err != nil {
+				t.Fatalf("companyService.loadCatalog() error = %v", err)
+			}
+			tt.want.File = "catalog.csv"
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("companyService.loadCatalog() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	companies, _ := s.repository.FindAll(ctx)
+	var names []string
+	for _, c := range companies {
+		names = append(names, c.Name)
+	}
+	sort.Strings(names)
+	if want := []string{"acme", "hooli", "initech"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("companies after the loads = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,89 @@
+package company
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/apex/log"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	xunicode "golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/marcospsbrito/dic/apierror"
+)
+
+// charsetSniffLen is the number of bytes inspected to detect the encoding
+const charsetSniffLen = 4096
+
+// ErrUnsupportedCharset is returned when the requested charset is unknown
+var ErrUnsupportedCharset = apierror.New(http.StatusBadRequest, "upload.unsupported_charset", "Unsupported charset")
+
+var (
+	utf8BOM    = []byte{0xef, 0xbb, 0xbf}
+	utf16LEBOM = []byte{0xff, 0xfe}
+	utf16BEBOM = []byte{0xfe, 0xff}
+)
+
+// decodeText transcodes f from charset, or from the encoding detected from
+// its BOM or content when charset is empty, to NFC normalized UTF-8
+func decodeText(f io.Reader, charset string) (io.Reader, error) {
+	br := bufio.NewReaderSize(f, charsetSniffLen)
+	var enc encoding.Encoding
+	if charset != "" {
+		var err error
+		if enc, err = htmlindex.Get(charset); err != nil {
+			return nil, ErrUnsupportedCharset
+		}
+	} else {
+		sample, _ := br.Peek(charsetSniffLen)
+		enc = detectEncoding(sample)
+	}
+	// a BOM always wins over the declared charset, and is never passed on
+	decoder := xunicode.BOMOverride(enc.NewDecoder())
+	return transform.NewReader(br, transform.Chain(decoder, norm.NFC)), nil
+}
+
+// detectEncoding returns the encoding announced by the BOM of sample, UTF-8
+// when sample is valid UTF-8 or Windows-1252 otherwise
+func detectEncoding(sample []byte) encoding.Encoding {
+	switch {
+	case bytes.HasPrefix(sample, utf8BOM):
+		log.Debug("Detected UTF-8 BOM")
+		return xunicode.UTF8
+	case bytes.HasPrefix(sample, utf16LEBOM):
+		log.Debug("Detected UTF-16LE BOM")
+		return xunicode.UTF16(xunicode.LittleEndian, xunicode.ExpectBOM)
+	case bytes.HasPrefix(sample, utf16BEBOM):
+		log.Debug("Detected UTF-16BE BOM")
+		return xunicode.UTF16(xunicode.BigEndian, xunicode.ExpectBOM)
+	}
+	// the sample may end in the middle of a multi byte rune
+	for i := 0; i < utf8.UTFMax && len(sample) > 0 && !utf8.Valid(sample); i++ {
+		sample = sample[:len(sample)-1]
+	}
+	if utf8.Valid(sample) {
+		return xunicode.UTF8
+	}
+	log.Debug("Detected legacy encoding, decoding as Windows-1252")
+	return charmap.Windows1252
+}
+
+// foldKey returns the matching key of a name: accents are removed, letters
+// lower cased and spaces collapsed, so "Café  Zoé" and "cafe zoe" match
+func foldKey(name string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, name)
+	if err != nil {
+		folded = name
+	}
+	return strings.Join(strings.Fields(strings.ToLower(folded)), " ")
+}
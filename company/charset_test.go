@@ -0,0 +1,89 @@
+package company
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+func Test_decodeText(t *testing.T) {
+	type args struct {
+		f       []byte
+		charset string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{"Plain UTF-8", args{[]byte("café;12345"), ""}, "café;12345", false},
+		{"Strip UTF-8 BOM", args{[]byte("\xef\xbb\xbfcafé;12345"), ""}, "café;12345", false},
+		{"Detect UTF-16LE BOM", args{[]byte("\xff\xfec\x00a\x00f\x00\xe9\x00"), ""}, "café", false},
+		{"Detect Windows-1252", args{[]byte("caf\xe9 \x80;12345"), ""}, "café €;12345", false},
+		{"Explicit Latin-1", args{[]byte("caf\xe9"), "iso-8859-1"}, "café", false},
+		{"BOM overrides charset", args{[]byte("\xef\xbb\xbfcafé"), "latin1"}, "café", false},
+		{"Normalize to NFC", args{[]byte("café"), ""}, "café", false},
+		{"Unknown charset", args{[]byte("cafe"), "klingon"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := decodeText(bytes.NewReader(tt.args.f), tt.args.charset)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeText() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("decodeText() read error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("decodeText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_detectEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample []byte
+		want   encoding.Encoding
+	}{
+		{"Valid UTF-8", []byte("café"), xunicode.UTF8},
+		{"Rune split by the end of the sample", []byte("caf\xc3"), xunicode.UTF8},
+		{"Invalid UTF-8", []byte("caf\xe9;12345"), charmap.Windows1252},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectEncoding(tt.sample); got != tt.want {
+				t.Errorf("detectEncoding() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_foldKey(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Café  Zoé", "cafe zoe"},
+		{"SÃO PAULO", "sao paulo"},
+		{"café", "cafe"},
+		{"smith, jones & co", "smith, jones & co"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := foldKey(tt.name); got != tt.want {
+				t.Errorf("foldKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package company
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/globalsign/mgo"
+)
+
+// Export formats of Commands
+const (
+	ExportJSON   = "json"
+	ExportNDJSON = "ndjson"
+	ExportCSV    = "csv"
+)
+
+var (
+	// ErrRowsRejected is returned by imports that completed without some of
+	// their rows
+	ErrRowsRejected = errors.New("some rows were rejected")
+	// ErrUnsupportedExport is returned when companies are exported to an
+	// unknown format
+	ErrUnsupportedExport = errors.New("unsupported export format, expected json, ndjson or csv")
+)
+
+// Commands defines the command line operations on companies, which write
+// their results and summary reports to w
+type Commands interface {
+	ImportCatalog(ctx context.Context, file string, w io.Writer) error
+	ImportWebsites(ctx context.Context, file string, opts ImportOptions, w io.Writer) error
+	Export(ctx context.Context, format string, w io.Writer) error
+	Match(ctx context.Context, name string, zipcode string, w io.Writer) error
+}
+
+type companyCommands struct {
+	service Service
+}
+
+// NewCommands returns the Commands of service, scoped to its tenant
+func NewCommands(service Service) Commands {
+	return companyCommands{service}
+}
+
+// ImportCatalog brings the companies in line with the catalog of file,
+// failing with ErrRowsRejected when some of its rows were rejected
+func (c companyCommands) ImportCatalog(ctx context.Context, file string, w io.Writer) error {
+	report, err := c.service.loadCatalog(ctx, file)
+	writeReport(w, importStatus(err), report)
+	if err == nil && len(report.Rejected) > 0 {
+		err = ErrRowsRejected
+	}
+	return err
+}
+
+// ImportWebsites merges the websites of file into the companies like an
+// upload, failing with ErrRowsRejected when some of its rows were rejected
+func (c companyCommands) ImportWebsites(ctx context.Context, file string, opts ImportOptions, w io.Writer) error {
+	if opts.Format == "" {
+		opts.Format = DetectFormat(file, "")
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := checkContent(f, opts.Format); err != nil {
+		return err
+	}
+	upload, replayed, err := c.service.loadWebsites(ctx, f, opts)
+	if replayed {
+		fmt.Fprintf(w, "Upload %s already processed\n", upload.ID.Hex())
+	}
+	if upload.ID != "" {
+		upload.Report.File = filepath.Base(file)
+		writeReport(w, upload.Status, upload.Report)
+	}
+	if err == nil && len(upload.Report.Rejected) > 0 {
+		err = ErrRowsRejected
+	}
+	return err
+}
+
+// Export writes every company to w in format
+func (c companyCommands) Export(ctx context.Context, format string, w io.Writer) error {
+	if format != ExportJSON && format != ExportNDJSON && format != ExportCSV {
+		return ErrUnsupportedExport
+	}
+	companies, err := c.service.findAll(ctx)
+	if err != nil {
+		return err
+	}
+	return writeCompanies(w, format, companies)
+}
+
+// Match writes the company matching name and zipcode to w as JSON, failing
+// with ErrCompanyNotFound when there is none
+func (c companyCommands) Match(ctx context.Context, name string, zipcode string, w io.Writer) error {
+	result, err := c.service.findByNameAndZipCode(ctx, name, zipcode)
+	if err == mgo.ErrNotFound {
+		return ErrCompanyNotFound
+	}
+	if err != nil {
+		return err
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(result)
+}
+
+// writeReport writes a summary of report and its rejected rows to w
+func writeReport(w io.Writer, status string, report Report) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if report.File != "" {
+		fmt.Fprintf(tw, "File:\t%s\n", report.File)
+	}
+	fmt.Fprintf(tw, "Status:\t%s\n", status)
+	fmt.Fprintf(tw, "Rows:\t%d\n", report.Rows)
+	fmt.Fprintf(tw, "Merged:\t%d\n", report.Merged)
+	fmt.Fprintf(tw, "Rejected:\t%d\n", len(report.Rejected))
+	if report.Removed > 0 {
+		fmt.Fprintf(tw, "Removed:\t%d\n", report.Removed)
+	}
+	if report.Unchanged {
+		fmt.Fprintf(tw, "Unchanged:\ttrue\n")
+	}
+	if report.Skipped > 0 {
+		fmt.Fprintf(tw, "Skipped:\t%d\n", report.Skipped)
+	}
+	if report.RolledBack {
+		fmt.Fprintf(tw, "Rolled back:\ttrue\n")
+	}
+	for _, r := range report.Rejected {
+		fmt.Fprintf(tw, "  line %d:\t%s\n", r.Line, r.Reason)
+	}
+	tw.Flush()
+}
+
+// writeCompanies writes companies to w in format. The csv format matches
+// the files of the catalog, so exports can be imported again.
+func writeCompanies(w io.Writer, format string, companies []Company) error {
+	switch format {
+	case ExportNDJSON:
+		e := json.NewEncoder(w)
+		for _, c := range companies {
+			if err := e.Encode(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ExportCSV:
+		cw := csv.NewWriter(w)
+		cw.Comma = ';'
+		cw.Write([]string{"name", "addresszip", "website"})
+		for _, c := range companies {
+			cw.Write([]string{c.Name, c.Zipcode.String(), c.Website})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	if companies == nil {
+		companies = []Company{}
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(companies)
+}
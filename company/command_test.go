@@ -0,0 +1,132 @@
+package company
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestCommands returns the Commands of a service over a memory repository
+// holding the companies of catalog
+func newTestCommands(t *testing.T, catalog string) Commands {
+	t.Helper()
+	c := NewCommands(NewService(NewMemoryRepository(), ImportConfig{}, Timeouts{}))
+	if catalog != "" {
+		if err := c.ImportCatalog(context.Background(), writeTestFile(t, "catalog.csv", catalog), ioutil.Discard); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+func writeTestFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_companyCommands_ImportCatalog(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr error
+	}{
+		{"Catalog imported", "name;addresszip\nacme;01234\nglobex;54321\n", "Merged:    2", nil},
+		{"Rows rejected", "name;addresszip\nacme;01234\ninitech\n", "line 3:", ErrRowsRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := newTestCommands(t, "").ImportCatalog(context.Background(), writeTestFile(t, "catalog.csv", tt.content), &out)
+			if err != tt.wantErr {
+				t.Errorf("companyCommands.ImportCatalog() error = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("companyCommands.ImportCatalog() report = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_companyCommands_ImportWebsites(t *testing.T) {
+	c := newTestCommands(t, "name;addresszip\nacme;01234\n")
+	file := writeTestFile(t, "websites.csv", "name;addresszip;website\nacme;01234;http://acme.com\n")
+	var out bytes.Buffer
+	if err := c.ImportWebsites(context.Background(), file, ImportOptions{Source: SourceWebsites}, &out); err != nil {
+		t.Fatalf("companyCommands.ImportWebsites() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "Status:    done") {
+		t.Errorf("companyCommands.ImportWebsites() report = %q, want done", out.String())
+	}
+	out.Reset()
+	if err := c.ImportWebsites(context.Background(), file, ImportOptions{Source: SourceWebsites}, &out); err != nil {
+		t.Fatalf("replayed companyCommands.ImportWebsites() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "already processed") {
+		t.Errorf("replayed companyCommands.ImportWebsites() report = %q, want replayed", out.String())
+	}
+	if err := c.ImportWebsites(context.Background(), file, ImportOptions{Format: "xml"}, &out); err != ErrUnsupportedFormat {
+		t.Errorf("companyCommands.ImportWebsites() error = %v, want %v", err, ErrUnsupportedFormat)
+	}
+}
+
+func Test_companyCommands_Export(t *testing.T) {
+	c := newTestCommands(t, "name;addresszip\nacme;01234\n")
+	tests := []struct {
+		format  string
+		want    string
+		wantErr error
+	}{
+		{ExportJSON, "[\n  {\n", nil},
+		{ExportNDJSON, `"name":"acme","Zipcode":1234}` + "\n", nil},
+		{ExportCSV, "name;addresszip;website\nacme;01234;\n", nil},
+		{"xml", "", ErrUnsupportedExport},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := c.Export(context.Background(), tt.format, &out); err != tt.wantErr {
+				t.Fatalf("companyCommands.Export() error = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("companyCommands.Export() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_companyCommands_Match(t *testing.T) {
+	c := newTestCommands(t, "name;addresszip\nacme;01234\n")
+	tests := []struct {
+		name    string
+		company string
+		zipcode string
+		wantErr bool
+	}{
+		{"Company found", "acme", "01234", false},
+		{"Company not found", "globex", "01234", true},
+		{"Invalid zipcode", "acme", "1234", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := c.Match(context.Background(), tt.company, tt.zipcode, &out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("companyCommands.Match() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !strings.Contains(out.String(), `"name": "acme"`) {
+				t.Errorf("companyCommands.Match() = %q, want acme", out.String())
+			}
+		})
+	}
+	if err := c.Match(context.Background(), "globex", "01234", ioutil.Discard); err != ErrCompanyNotFound {
+		t.Errorf("companyCommands.Match() error = %v, want %v", err, ErrCompanyNotFound)
+	}
+}
@@ -0,0 +1,114 @@
+package company
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/marcospsbrito/dic/apierror"
+)
+
+// contentSniffLen is the number of bytes inspected to validate an upload
+const contentSniffLen = 512
+
+var (
+	// ErrUploadTooLarge is returned when an upload exceeds the size limit
+	ErrUploadTooLarge = apierror.New(http.StatusRequestEntityTooLarge, "upload.too_large", "Upload too large")
+	// ErrUnsupportedMediaType is returned when an upload is not an
+	// importable file
+	ErrUnsupportedMediaType = apierror.New(http.StatusUnsupportedMediaType, "upload.unsupported_media_type", "Unsupported media type, upload csv, tsv, json, ndjson, xlsx or zip files")
+)
+
+// uploadContentTypes are the content types accepted besides the ones of the
+// import formats. Browsers and tools send some of them for any file.
+var uploadContentTypes = map[string]bool{
+	"application/octet-stream":     true,
+	"application/vnd.ms-excel":     true,
+	"application/zip":              true,
+	"application/x-zip-compressed": true,
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+	"application/x-bzip2":          true,
+	"application/zstd":             true,
+}
+
+// LimitUploadSize middleware rejects request bodies larger than max bytes
+// with 413. Bodies without a Content-Length are cut off while streaming.
+func LimitUploadSize(max int64) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if max <= 0 {
+			ctx.Next()
+			return
+		}
+		if ctx.Request.ContentLength > max {
+			apierror.Abort(ctx, ErrUploadTooLarge)
+			return
+		}
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, max)
+		ctx.Next()
+	}
+}
+
+// isTooLarge reports whether err comes from a body cut off by LimitUploadSize
+func isTooLarge(err error) bool {
+	var e *http.MaxBytesError
+	return errors.As(err, &e)
+}
+
+// checkMediaType rejects uploads whose extension or declared content type
+// is not one of an importable file. Files without either are accepted and
+// left to checkContent.
+func checkMediaType(filename string, contentType string) error {
+	if ext := strings.ToLower(filepath.Ext(filename)); ext != "" {
+		if _, ok := formatByName(filename); !ok && ext != ".zip" {
+			return ErrUnsupportedMediaType
+		}
+	}
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ErrUnsupportedMediaType
+	}
+	if _, ok := formatsByContentType[mediaType]; !ok && !uploadContentTypes[mediaType] {
+		return ErrUnsupportedMediaType
+	}
+	return nil
+}
+
+// checkContent rejects uploads whose first bytes are neither text nor an
+// archive, compressed stream or workbook, so binary files are not parsed.
+// f is rewound afterwards.
+func checkContent(f io.ReadSeeker, format string) error {
+	sample := make([]byte, contentSniffLen)
+	n, err := io.ReadFull(f, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	sample = sample[:n]
+	if bytes.HasPrefix(sample, zipMagic) {
+		return nil
+	}
+	if format == FormatXLSX {
+		return ErrUnsupportedMediaType
+	}
+	for _, magic := range [][]byte{gzipMagic, bzip2Magic, zstdMagic, utf16LEBOM, utf16BEBOM} {
+		if bytes.HasPrefix(sample, magic) {
+			return nil
+		}
+	}
+	if !strings.HasPrefix(http.DetectContentType(sample), "text/") {
+		return ErrUnsupportedMediaType
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package company
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Test_checkMediaType(t *testing.T) {
+	tests := []struct {
+		name        string
+		filename    string
+		contentType string
+		wantErr     error
+	}{
+		{"Csv file", "websites.csv", "text/csv", nil},
+		{"Tsv file", "websites.tsv", "text/tab-separated-values", nil},
+		{"Compressed json", "websites.json.gz", "application/gzip", nil},
+		{"Zip archive", "websites.zip", "application/zip", nil},
+		{"Generic content type", "websites.xlsx", "application/octet-stream", nil},
+		{"No extension nor content type", "websites", "", nil},
+		{"Image", "logo.png", "image/png", ErrUnsupportedMediaType},
+		{"Executable with csv content type", "setup.exe", "text/csv", ErrUnsupportedMediaType},
+		{"Pdf content type", "websites.csv", "application/pdf", ErrUnsupportedMediaType},
+		{"Invalid content type", "websites.csv", "text/", ErrUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkMediaType(tt.filename, tt.contentType); err != tt.wantErr {
+				t.Errorf("checkMediaType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_checkContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		format  string
+		wantErr error
+	}{
+		{"Csv", "name;addresszip;website\ntola;78229;http://tola.com\n", FormatCSV, nil},
+		{"Latin-1 csv", "caf\xe9;78229\n", FormatCSV, nil},
+		{"Json", `[{"name":"tola"}]`, FormatJSON, nil},
+		{"Empty file", "", FormatCSV, nil},
+		{"Gzip stream", "\x1f\x8b\x08\x00\x00\x00", FormatCSV, nil},
+		{"Utf-16 text", "\xff\xfen\x00a\x00", FormatCSV, nil},
+		{"Workbook", "PK\x03\x04\x14\x00", FormatXLSX, nil},
+		{"Png image", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", FormatCSV, ErrUnsupportedMediaType},
+		{"Binary garbage", "\x00\x01\x02\x03\xfe\x00", FormatCSV, ErrUnsupportedMediaType},
+		{"Workbook that is text", "name,zip\n", FormatXLSX, ErrUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := strings.NewReader(tt.content)
+			if err := checkContent(f, tt.format); err != tt.wantErr {
+				t.Errorf("checkContent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if pos, _ := f.Seek(0, io.SeekCurrent); pos != 0 {
+				t.Errorf("checkContent() left the file at %v", pos)
+			}
+		})
+	}
+}
+
+func multipartUpload(filename string, contentType string, content []byte) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	if filename != "" {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", `form-data; name="data"; filename="`+filename+`"`)
+		h.Set("Content-Type", contentType)
+		part, _ := w.CreatePart(h)
+		part.Write(content)
+	}
+	w.Close()
+	return body, w.FormDataContentType()
+}
+
+func Test_companyController_LoadWebsites_validation(t *testing.T) {
+	sMock := serviceMock{loadWebsitesFn: func(io.ReadSeeker, ImportOptions) (Upload, bool, error) {
+		return Upload{Status: UploadDone}, false, nil
+	}}
+	csv := []byte("name;addresszip;website\ntola;78229;http://tola.com\n")
+	tsv := []byte("name\taddresszip\twebsite\ntola\t78229\thttp://tola.com\n")
+	tests := []struct {
+		name        string
+		filename    string
+		contentType string
+		content     []byte
+		chunked     bool
+		want        int
+	}{
+		{"Csv upload", "websites.csv", "text/csv", csv, false, http.StatusOK},
+		{"Tsv upload", "websites.tsv", "text/tab-separated-values", tsv, false, http.StatusOK},
+		{"Missing file", "", "", nil, false, http.StatusBadRequest},
+		{"Too large", "websites.csv", "text/csv", bytes.Repeat(csv, 100), false, http.StatusRequestEntityTooLarge},
+		{"Too large while streaming", "websites.csv", "text/csv", bytes.Repeat(csv, 100), true, http.StatusRequestEntityTooLarge},
+		{"Unsupported extension", "logo.png", "image/png", csv, false, http.StatusUnsupportedMediaType},
+		{"Binary content", "websites.csv", "text/csv", []byte("\x00\x01\x02\x03garbage"), false, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.POST("/websites", LimitUploadSize(1024), companyController{sMock}.LoadWebsites)
+			body, contentType := multipartUpload(tt.filename, tt.contentType, tt.content)
+			req := httptest.NewRequest(http.MethodPost, "/websites", body)
+			req.Header.Set("Content-Type", contentType)
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("companyController.LoadWebsites() status = %v, want %v: %v", w.Code, tt.want, w.Body.String())
+			}
+			if w.Code != http.StatusOK && !strings.Contains(w.Body.String(), `"message"`) {
+				t.Errorf("companyController.LoadWebsites() body = %v, want a JSON error", w.Body.String())
+			}
+		})
+	}
+}
@@ -1,22 +1,44 @@
 package company
 
 import (
-	"errors"
+	"context"
+	"mime"
 	"net/http"
+	"strconv"
 
 	"github.com/apex/log"
 	"github.com/gin-gonic/gin"
 	"github.com/globalsign/mgo"
-	"github.com/swaggo/swag/example/celler/httputil"
+
+	"github.com/marcospsbrito/dic/apierror"
+	"github.com/marcospsbrito/dic/auth"
 )
 
 // Controller defines methods to a Controller
 type Controller interface {
 	Find(ctx *gin.Context)
 	LoadWebsites(ctx *gin.Context)
-	InitDatabase(string)
+	CopyCatalog(ctx *gin.Context)
+	InitDatabase(ctx context.Context, file string) error
+	CheckCatalog() (interface{}, error)
+	CheckImports() (interface{}, error)
+	Drain(ctx context.Context) error
 }
 
+// Errors answered by the companyController
+var (
+	// ErrMissingParameters is answered when a company is searched without its name or zipcode
+	ErrMissingParameters = apierror.New(http.StatusBadRequest, "company.missing_parameters", "Missing parameters 'name' or 'zipcode'")
+	// ErrCompanyNotFound is answered when no company matches a search
+	ErrCompanyNotFound = apierror.New(http.StatusNotFound, "company.not_found", "Company not found")
+	// ErrMissingFile is answered when an upload has no multipart file
+	ErrMissingFile = apierror.New(http.StatusBadRequest, "upload.missing_file", "Missing multipart file 'data'")
+	// ErrInvalidAtomic is answered when the atomic parameter is not a boolean
+	ErrInvalidAtomic = apierror.New(http.StatusBadRequest, "upload.invalid_atomic", "Invalid parameter 'atomic'")
+	// ErrInvalidSourceTenant is answered when a catalog is copied from no other tenant
+	ErrInvalidSourceTenant = apierror.New(http.StatusBadRequest, "tenant.invalid_source", "Parameter 'from' must name another tenant")
+)
+
 type companyController struct {
 	service Service
 }
@@ -26,10 +48,22 @@ func NewController(service Service) Controller {
 	return companyController{service}
 }
 
+// serviceFor returns the Service of the tenant of the caller
+func (c companyController) serviceFor(ctx *gin.Context) Service {
+	return c.service.ForTenant(auth.TenantFrom(ctx))
+}
+
+// IsExport reports whether a request to Find returns the whole collection
+func IsExport(ctx *gin.Context) bool {
+	_, hasName := ctx.GetQuery("name")
+	_, hasZip := ctx.GetQuery("zipcode")
+	return !hasName && !hasZip
+}
+
 func (c companyController) GetAll(ctx *gin.Context) {
-	results, err := c.service.findAll()
+	results, err := c.serviceFor(ctx).findAll(ctx.Request.Context())
 	if err != nil {
-		httputil.NewError(ctx, http.StatusInternalServerError, err)
+		apierror.Abort(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, results)
@@ -43,9 +77,16 @@ func (c companyController) GetAll(ctx *gin.Context) {
 // @Param name query string true "Name"
 // @Param zipcode query string true "Zipcode"
 // @Success 200 {array} company.Company
-// @Failure 400 {object} httputil.HTTPError
-// @Failure 404 {object} httputil.HTTPError
-// @Failure 500 {object} httputil.HTTPError
+// @Failure 400 {object} apierror.Error
+// @Failure 401 {object} apierror.Error
+// @Failure 403 {object} apierror.Error
+// @Failure 404 {object} apierror.Error
+// @Failure 429 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Failure 503 {object} apierror.Error
+// @Failure 504 {object} apierror.Error
+// @Security ApiKeyAuth
+// @Security OAuth2Application[read]
 // @Router /companies [get]
 func (c companyController) Find(ctx *gin.Context) {
 	name, hasName := ctx.GetQuery("name")
@@ -53,62 +94,198 @@ func (c companyController) Find(ctx *gin.Context) {
 	var result Company
 	var err error
 
-	if !hasName && !hasZip {
+	if IsExport(ctx) {
 		c.GetAll(ctx)
 		return
 	}
 
 	if hasName && hasZip {
-		result, err = c.service.findByNameAndZipCode(name, zipcode)
+		result, err = c.serviceFor(ctx).findByNameAndZipCode(ctx.Request.Context(), name, zipcode)
 	} else {
-		err = errors.New("Missing parameters 'name' or 'zipcode'")
+		err = ErrMissingParameters
 	}
 
+	if err == mgo.ErrNotFound {
+		err = ErrCompanyNotFound
+	}
 	if err != nil {
-		if err == mgo.ErrNotFound {
-			log.WithError(err).Error("Company not found")
-			ctx.Status(http.StatusNoContent)
-			return
-		}
-		log.WithError(err).Error("fail")
-		ctx.AbortWithError(http.StatusBadRequest, err)
+		apierror.Abort(ctx, violationError(err))
 		return
 	}
 
 	ctx.JSON(http.StatusOK, result)
 }
 
+// violationError returns a 400 with the rule of err when err is a
+// RuleViolation, err otherwise
+func violationError(err error) error {
+	if v, ok := err.(RuleViolation); ok {
+		return apierror.New(http.StatusBadRequest, v.Rule, v.Message)
+	}
+	return err
+}
+
 // LoadWebsites godoc
 // @Summary Load a csv file with websites to merge with companies data
-// @Description post website file to merge with companies
+// @Description post website file to merge with companies. Uploads with the same content or Idempotency-Key are processed only once.
 // @ID post-load-websites
 // @accept mpfd
-// @Produce  plain
-// @Success 200 {string} string "OK"
-// @Failure 400 {object} httputil.HTTPError
-// @Failure 404 {object} httputil.HTTPError
-// @Failure 500 {object} httputil.HTTPError
-// @Router /companies/websites [get]
+// @Produce json
+// @Param data formData file true "File to import, optionally gzip, bzip2 or zstd compressed, or a zip archive of files"
+// @Param Idempotency-Key header string false "Key to safely retry the upload"
+// @Param atomic query boolean false "Commit all rows or none of them"
+// @Param source query string false "Source of the file selecting its validation rules" default(websites)
+// @Param format query string false "File format (csv, json, ndjson or xlsx), detected from the file when empty"
+// @Param sheet query string false "Worksheet of xlsx files, the first one when empty"
+// @Param charset query string false "Charset of text files (e.g. utf-8, iso-8859-1, windows-1252), detected when empty"
+// @Param delimiter query string false "Delimiter of csv files: a character or comma, semicolon, tab or pipe, detected when empty"
+// @Param comment query string false "Comment character of csv files or none, detected when empty"
+// @Param header query boolean false "Whether the first row of csv files is a header, detected when empty"
+// @Success 200 {object} company.Upload
+// @Failure 400 {object} apierror.Error
+// @Failure 401 {object} apierror.Error
+// @Failure 403 {object} apierror.Error
+// @Failure 409 {object} apierror.Error
+// @Failure 413 {object} apierror.Error
+// @Failure 415 {object} apierror.Error
+// @Failure 422 {object} apierror.Error
+// @Failure 429 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Failure 503 {object} apierror.Error
+// @Failure 504 {object} apierror.Error
+// @Security ApiKeyAuth
+// @Security OAuth2Application[write]
+// @Router /companies/websites [post]
 func (c companyController) LoadWebsites(ctx *gin.Context) {
+	atomic, err := strconv.ParseBool(ctx.DefaultQuery("atomic", "false"))
+	if err != nil {
+		apierror.Abort(ctx, ErrInvalidAtomic)
+		return
+	}
 	fileheader, err := ctx.FormFile("data")
+	if isTooLarge(err) {
+		err = ErrUploadTooLarge
+	} else if err != nil {
+		err = ErrMissingFile
+	}
 	if err != nil {
-		ctx.AbortWithError(http.StatusInternalServerError, err)
+		apierror.Abort(ctx, err)
+		return
+	}
+	contentType := fileheader.Header.Get("Content-Type")
+	if err := checkMediaType(fileheader.Filename, contentType); err != nil {
+		apierror.Abort(ctx, err)
 		return
 	}
 	file, err := fileheader.Open()
 	if err != nil {
-		ctx.AbortWithError(http.StatusInternalServerError, err)
+		apierror.Abort(ctx, err)
+		return
+	}
+	defer file.Close()
+	opts := ImportOptions{
+		IdempotencyKey: ctx.GetHeader("Idempotency-Key"),
+		Source:         ctx.DefaultQuery("source", SourceWebsites),
+		Atomic:         atomic,
+		Format:         ctx.Query("format"),
+		Sheet:          ctx.Query("sheet"),
+		Charset:        ctx.Query("charset"),
+		Delimiter:      ctx.Query("delimiter"),
+		Comment:        ctx.Query("comment"),
+		Header:         ctx.Query("header"),
+	}
+	if p, ok := auth.PrincipalFrom(ctx); ok {
+		opts.Caller = p.Method + ":" + p.Subject
+	}
+	if opts.Format == "" {
+		opts.Format = DetectFormat(fileheader.Filename, contentType)
+	}
+	if _, params, err := mime.ParseMediaType(contentType); opts.Charset == "" && err == nil {
+		opts.Charset = params["charset"]
+	}
+	if err := opts.validate(); err != nil {
+		apierror.Abort(ctx, violationError(err))
+		return
+	}
+	if err := checkContent(file, opts.Format); err != nil {
+		apierror.Abort(ctx, err)
+		return
+	}
+	upload, replayed, err := c.serviceFor(ctx).loadWebsites(ctx.Request.Context(), file, opts)
+	if err != nil {
+		apierror.Abort(ctx, violationError(err))
+		return
+	}
+	if replayed {
+		ctx.Header("Idempotent-Replayed", "true")
+	}
+	ctx.JSON(http.StatusOK, upload)
+}
+
+// CopyCatalog godoc
+// @Summary Copy a catalog between tenants
+// @Description add the companies of tenant from that are missing in tenant id. Only admins of the default tenant manage tenants.
+// @ID post-tenant-catalog
+// @Produce json
+// @Param id path string true "Target tenant"
+// @Param from query string true "Source tenant"
+// @Success 200 {object} company.CopyResult
+// @Failure 400 {object} apierror.Error
+// @Failure 401 {object} apierror.Error
+// @Failure 403 {object} apierror.Error
+// @Failure 404 {object} apierror.Error
+// @Failure 429 {object} apierror.Error
+// @Failure 500 {object} apierror.Error
+// @Failure 503 {object} apierror.Error
+// @Failure 504 {object} apierror.Error
+// @Security ApiKeyAuth
+// @Security OAuth2Application[admin]
+// @Router /tenants/{id}/catalog [post]
+func (c companyController) CopyCatalog(ctx *gin.Context) {
+	to, from := ctx.Param("id"), ctx.Query("from")
+	if from == "" || from == to {
+		apierror.Abort(ctx, ErrInvalidSourceTenant)
 		return
 	}
-	err = c.service.loadWebsites(file)
+	copied, err := c.service.ForTenant(to).copyCatalog(ctx.Request.Context(), from)
 	if err != nil {
-		ctx.AbortWithError(http.StatusInternalServerError, err)
+		apierror.Abort(ctx, err)
 		return
 	}
-	ctx.Status(http.StatusOK)
-	return
+	ctx.JSON(http.StatusOK, CopyResult{From: from, To: to, Copied: copied})
+}
+
+// CopyResult reports a catalog copy
+type CopyResult struct {
+	From   string `json:"from" example:"default"`
+	To     string `json:"to" example:"sales"`
+	Copied int    `json:"copied" example:"42"`
+}
+
+// InitDatabase loads the catalog of file, until ctx is done, and returns
+// the error that failed it
+func (c companyController) InitDatabase(ctx context.Context, file string) error {
+	if err := c.service.InitDatabase(ctx, file); err != nil {
+		log.WithError(err).Error("Failed to load catalog")
+		return err
+	}
+	log.Info("Catalog loaded")
+	return nil
+}
+
+// CheckCatalog reports the initial load of the catalog, failing until it
+// completes
+func (c companyController) CheckCatalog() (interface{}, error) {
+	return c.service.catalogStatus()
+}
+
+// CheckImports reports the saturation of the import workers
+func (c companyController) CheckImports() (interface{}, error) {
+	return c.service.importStatus(), nil
 }
 
-func (c companyController) InitDatabase(file string) {
-	c.service.InitDatabase(file)
+// Drain waits for the running imports to be done or interrupted, failing
+// with the error of ctx when it ends first
+func (c companyController) Drain(ctx context.Context) error {
+	return c.service.drain(ctx)
 }
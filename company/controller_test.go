@@ -1,12 +1,11 @@
 package company
 
 import (
+	"context"
 	"errors"
 	"io"
-	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
-	"net/textproto"
 	"reflect"
 	"strings"
 	"testing"
@@ -20,27 +19,61 @@ type serviceMock struct {
 	findByNameAndZipCodeFn func(string, string) (Company, error)
 	addFn                  func(Company) error
 	InitDatabaseFn         func(string) error
-	loadWebsitesFn         func(io.Reader) error
+	RecoverImportsFn       func() error
+	loadCatalogFn          func(string) (Report, error)
+	loadWebsitesFn         func(io.ReadSeeker, ImportOptions) (Upload, bool, error)
+	copyCatalogFn          func(string) (int, error)
+	catalogStatusFn        func() (CatalogStatus, error)
+	importStatusFn         func() ImportStatus
+	drainFn                func(context.Context) error
 }
 
-func (s serviceMock) findByNameAndZipCode(n string, z string) (Company, error) {
+func (s serviceMock) catalogStatus() (CatalogStatus, error) {
+	return s.catalogStatusFn()
+}
+
+func (s serviceMock) importStatus() ImportStatus {
+	return s.importStatusFn()
+}
+
+func (s serviceMock) drain(ctx context.Context) error {
+	return s.drainFn(ctx)
+}
+
+func (s serviceMock) ForTenant(string) Service {
+	return s
+}
+
+func (s serviceMock) copyCatalog(ctx context.Context, from string) (int, error) {
+	return s.copyCatalogFn(from)
+}
+
+func (s serviceMock) findByNameAndZipCode(ctx context.Context, n string, z string) (Company, error) {
 	return s.findByNameAndZipCodeFn(n, z)
 }
 
-func (s serviceMock) add(c Company) error {
+func (s serviceMock) add(ctx context.Context, c Company) error {
 	return s.addFn(c)
 }
 
-func (s serviceMock) InitDatabase(st string) error {
+func (s serviceMock) InitDatabase(ctx context.Context, st string) error {
 	return s.InitDatabaseFn(st)
 }
 
-func (s serviceMock) findAll() ([]Company, error) {
+func (s serviceMock) RecoverImports(ctx context.Context) error {
+	return s.RecoverImportsFn()
+}
+
+func (s serviceMock) loadCatalog(ctx context.Context, st string) (Report, error) {
+	return s.loadCatalogFn(st)
+}
+
+func (s serviceMock) findAll(ctx context.Context) ([]Company, error) {
 	return s.findAllFn()
 }
 
-func (s serviceMock) loadWebsites(f io.Reader) error {
-	return s.loadWebsitesFn(f)
+func (s serviceMock) loadWebsites(ctx context.Context, f io.ReadSeeker, o ImportOptions) (Upload, bool, error) {
+	return s.loadWebsitesFn(f, o)
 }
 
 func TestNewController(t *testing.T) {
@@ -67,6 +100,7 @@ func TestNewController(t *testing.T) {
 
 func Test_companyController_GetAll(t *testing.T) {
 	ctxMock, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctxMock.Request = httptest.NewRequest(http.MethodGet, "/companies", nil)
 	sMockError := serviceMock{
 		findAllFn: func() ([]Company, error) { return []Company{}, errors.New("mock error") },
 	}
@@ -102,18 +136,12 @@ func Test_companyController_GetAll(t *testing.T) {
 }
 
 func Test_companyController_Find(t *testing.T) {
-	ctxMockMany, _ := gin.CreateTestContext(httptest.NewRecorder())
-	ctxMockMany.Request, _ = http.NewRequest("GET", "ab.com/test", strings.NewReader(""))
-	ctxMockError, _ := gin.CreateTestContext(httptest.NewRecorder())
-	ctxMockError.Request, _ = http.NewRequest("GET", "ab.com/test?zipcode=12345", strings.NewReader(""))
-	ctxMockOne, _ := gin.CreateTestContext(httptest.NewRecorder())
-	ctxMockOne.Request, _ = http.NewRequest("GET", "ab.com/test?zipcode=12345&name=asdf", strings.NewReader(""))
-	ctxMockMgoError, _ := gin.CreateTestContext(httptest.NewRecorder())
-	ctxMockMgoError.Request, _ = http.NewRequest("GET", "ab.com/test?zipcode=123&name=mgo", strings.NewReader(""))
-
 	sMock := serviceMock{
 		findAllFn: func() ([]Company, error) { return []Company{{}, {}}, nil },
 		findByNameAndZipCodeFn: func(a string, b string) (Company, error) {
+			if _, err := validateZipcode(b); err != nil {
+				return Company{}, err
+			}
 			if a == "mgo" {
 				return Company{}, mgo.ErrNotFound
 			}
@@ -123,76 +151,117 @@ func Test_companyController_Find(t *testing.T) {
 			return Company{}, nil
 		},
 	}
-	type fields struct {
-		service Service
-	}
-	type args struct {
-		ctx *gin.Context
-	}
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
+		name     string
+		path     string
+		want     int
+		wantCode string
 	}{
-		{"Find many", fields{sMock}, args{ctxMockMany}},
-		{"Find one", fields{sMock}, args{ctxMockOne}},
-		{"Company not found", fields{sMock}, args{ctxMockError}},
-		{"mgo.error", fields{sMock}, args{ctxMockMgoError}},
+		{"Find many", "/companies", http.StatusOK, ""},
+		{"Find one", "/companies?zipcode=12345&name=asdf", http.StatusOK, ""},
+		{"Missing parameters", "/companies?zipcode=12345", http.StatusBadRequest, "company.missing_parameters"},
+		{"Invalid zipcode", "/companies?zipcode=abc&name=asdf", http.StatusBadRequest, RuleInvalidZipcode},
+		{"Company not found", "/companies?zipcode=12345&name=mgo", http.StatusNotFound, "company.not_found"},
+		{"Service error", "/companies?zipcode=12345&name=error", http.StatusInternalServerError, "internal"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c := companyController{
-				service: tt.fields.service,
+			r := gin.New()
+			r.GET("/companies", companyController{sMock}.Find)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.want {
+				t.Errorf("companyController.Find() status = %v, want %v", w.Code, tt.want)
+			}
+			if tt.wantCode != "" && !strings.Contains(w.Body.String(), `"code":"`+tt.wantCode+`"`) {
+				t.Errorf("companyController.Find() body = %v, want code %v", w.Body.String(), tt.wantCode)
 			}
-			c.Find(tt.args.ctx)
 		})
 	}
 }
 
 func Test_companyController_LoadWebsites(t *testing.T) {
-	ctxMockFile, _ := gin.CreateTestContext(httptest.NewRecorder())
-	ctxMockFile.Request = &http.Request{Method: "GET", Host: "ab.com/test",
-		MultipartForm: &multipart.Form{
-			Value: make(map[string][]string),
-			File:  make(map[string][]*multipart.FileHeader),
-		}}
-	ctxMockFile.Request.MultipartForm.Value["data"] = []string{"file"}
-	ctxMockFile.Request.MultipartForm.File["data"] = []*multipart.FileHeader{{Filename: "", Header: textproto.MIMEHeader{}, Size: 128}}
-	ctxMockNoFile, _ := gin.CreateTestContext(httptest.NewRecorder())
-	ctxMockNoFile.Request, _ = http.NewRequest("GET", "ab.com/test", strings.NewReader(""))
-
-	sMock := serviceMock{
-		findAllFn: func() ([]Company, error) { return []Company{{}, {}}, nil },
-		findByNameAndZipCodeFn: func(a string, b string) (Company, error) {
-			if a == "mgo" {
-				return Company{}, mgo.ErrNotFound
+	sMock := serviceMock{loadWebsitesFn: func(f io.ReadSeeker, opts ImportOptions) (Upload, bool, error) {
+		switch opts.IdempotencyKey {
+		case "replayed":
+			return Upload{Hash: "abc", Status: UploadDone, Report: Report{Rows: 1, Merged: 1}}, true, nil
+		case "in-progress":
+			return Upload{}, false, ErrUploadInProgress
+		case "error":
+			return Upload{}, false, errors.New("mock error")
+		}
+		return Upload{Hash: "abc", Status: UploadDone, Report: Report{Rows: 1, Merged: 1, Atomic: opts.Atomic}}, false, nil
+	}}
+	csv := []byte("name;addresszip;website\ntola;78229;http://tola.com\n")
+	tests := []struct {
+		name         string
+		query        string
+		key          string
+		want         int
+		wantBody     string
+		wantReplayed bool
+	}{
+		{"Load websites", "", "", http.StatusOK, `"report":{"rows":1,"merged":1}`, false},
+		{"Load websites atomically", "?atomic=true", "", http.StatusOK, `"report":{"rows":1,"merged":1,"atomic":true}`, false},
+		{"Replay upload", "", "replayed", http.StatusOK, `"status":"done"`, true},
+		{"Upload in progress", "", "in-progress", http.StatusConflict, `"code":"upload.in_progress"`, false},
+		{"Service error", "", "error", http.StatusInternalServerError, `"code":"internal"`, false},
+		{"Invalid atomic", "?atomic=maybe", "", http.StatusBadRequest, `"code":"upload.invalid_atomic"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.POST("/websites", companyController{sMock}.LoadWebsites)
+			body, contentType := multipartUpload("websites.csv", "text/csv", csv)
+			req := httptest.NewRequest(http.MethodPost, "/websites"+tt.query, body)
+			req.Header.Set("Content-Type", contentType)
+			if tt.key != "" {
+				req.Header.Set("Idempotency-Key", tt.key)
 			}
-			if a == "error" {
-				return Company{}, errors.New("mock error")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("companyController.LoadWebsites() status = %v, want %v", w.Code, tt.want)
 			}
-			return Company{}, nil
-		},
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("companyController.LoadWebsites() body = %v, want %v", w.Body.String(), tt.wantBody)
+			}
+			if replayed := w.Header().Get("Idempotent-Replayed") == "true"; replayed != tt.wantReplayed {
+				t.Errorf("companyController.LoadWebsites() replayed = %v, want %v", replayed, tt.wantReplayed)
+			}
+		})
 	}
+}
 
-	type fields struct {
-		service Service
-	}
-	type args struct {
-		ctx *gin.Context
+func Test_companyController_CopyCatalog(t *testing.T) {
+	sMock := serviceMock{
+		copyCatalogFn: func(from string) (int, error) {
+			if from == "error" {
+				return 0, errors.New("mock error")
+			}
+			return 2, nil
+		},
 	}
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
+		name string
+		path string
+		want int
 	}{
-		{"Load website", fields{sMock}, args{ctxMockFile}},
+		{"Copy catalog", "/tenants/sales/catalog?from=default", http.StatusOK},
+		{"Missing source", "/tenants/sales/catalog", http.StatusBadRequest},
+		{"Same tenant", "/tenants/sales/catalog?from=sales", http.StatusBadRequest},
+		{"Copy error", "/tenants/sales/catalog?from=error", http.StatusInternalServerError},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c := companyController{
-				service: tt.fields.service,
+			r := gin.New()
+			r.POST("/tenants/:id/catalog", companyController{sMock}.CopyCatalog)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, nil))
+			if w.Code != tt.want {
+				t.Errorf("companyController.CopyCatalog() status = %v, want %v", w.Code, tt.want)
 			}
-			c.LoadWebsites(tt.args.ctx)
 		})
 	}
 }
@@ -226,19 +295,22 @@ func Test_companyController_InitDatabase(t *testing.T) {
 		file string
 	}
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
 	}{
-		{"call", fields{sMock}, args{file: ""}},
-		{"call error", fields{sMock}, args{}},
+		{"call", fields{sMock}, args{file: ""}, false},
+		{"call error", fields{sMock}, args{file: "missing.csv"}, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := companyController{
 				service: tt.fields.service,
 			}
-			c.InitDatabase(tt.args.file)
+			if err := c.InitDatabase(context.Background(), tt.args.file); (err != nil) != tt.wantErr {
+				t.Errorf("companyController.InitDatabase() error = %v, wantErr %v", err, tt.wantErr)
+			}
 		})
 	}
 }
@@ -0,0 +1,232 @@
+package company
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/apex/log"
+
+	"github.com/marcospsbrito/dic/apierror"
+)
+
+// dialectSniffLen is the number of bytes inspected to detect the dialect
+const dialectSniffLen = 64 * 1024
+
+// ErrInvalidDialect is returned when a dialect override cannot be used
+var ErrInvalidDialect = apierror.New(http.StatusBadRequest, "upload.invalid_dialect", "Invalid delimiter, comment or header parameter")
+
+// RuleDialectConflict is reported when the comment character of a file would
+// also be its delimiter
+const RuleDialectConflict = "dialect.conflict"
+
+// delimiterCandidates are the delimiters tried by the sniffer, in order of
+// preference when they fit the sample equally well
+var delimiterCandidates = []rune{',', ';', '\t', '|'}
+
+var delimiterNames = map[string]rune{
+	"comma":     ',',
+	"semicolon": ';',
+	"tab":       '\t',
+	`\t`:        '\t',
+	"pipe":      '|',
+}
+
+// knownHeaders are column names that mark the first row as a header
+var knownHeaders = map[string]bool{
+	"name":       true,
+	"zip":        true,
+	"zipcode":    true,
+	"addresszip": true,
+	"website":    true,
+}
+
+// Dialect describes how a delimited text file is written
+type Dialect struct {
+	Delimiter rune
+	// Comment starts lines that are ignored, 0 when there are none
+	Comment rune
+	// Quoted is set when fields are enclosed in double quotes
+	Quoted bool
+	// LazyQuotes is set when quotes appear inside unquoted fields
+	LazyQuotes bool
+	// Header is set when the first row holds the column names
+	Header bool
+}
+
+// sniffDialect guesses the dialect of a delimited file from a sample of its
+// first lines
+func sniffDialect(sample string, truncated bool) Dialect {
+	lines := strings.Split(sample, "\n")
+	if truncated && len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+	}
+	var d Dialect
+	var data []string
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		switch {
+		case strings.TrimSpace(line) == "":
+		case strings.HasPrefix(line, "#"):
+			d.Comment = '#'
+		default:
+			data = append(data, line)
+		}
+	}
+	d.Delimiter = sniffDelimiter(data)
+	d.Quoted, d.LazyQuotes = sniffQuoting(data, d.Delimiter)
+	d.Header = sniffHeader(data, d.Delimiter)
+	return d
+}
+
+// sniffDelimiter returns the candidate found the same number of times on
+// most lines
+func sniffDelimiter(lines []string) rune {
+	best, bestScore, bestCount := delimiterCandidates[0], 0.0, 0
+	for _, candidate := range delimiterCandidates {
+		frequency := make(map[int]int)
+		for _, line := range lines {
+			frequency[len(splitFields(line, candidate))-1]++
+		}
+		mode, modeLines := 0, 0
+		for count, n := range frequency {
+			if count > 0 && (n > modeLines || (n == modeLines && count > mode)) {
+				mode, modeLines = count, n
+			}
+		}
+		if mode == 0 {
+			continue
+		}
+		score := float64(modeLines) / float64(len(lines))
+		if score > bestScore || (score == bestScore && mode > bestCount) {
+			best, bestScore, bestCount = candidate, score, mode
+		}
+	}
+	return best
+}
+
+// sniffQuoting reports whether fields are quoted and whether quotes also
+// appear inside unquoted fields
+func sniffQuoting(lines []string, delimiter rune) (quoted bool, lazy bool) {
+	for _, line := range lines {
+		for _, field := range splitFields(line, delimiter) {
+			field = strings.TrimSpace(field)
+			if strings.HasPrefix(field, `"`) {
+				quoted = true
+			} else if strings.Contains(field, `"`) {
+				lazy = true
+			}
+		}
+	}
+	return quoted, lazy
+}
+
+// sniffHeader reports whether the first line names the columns: either one
+// of its fields is a known column name, or it is not numeric in a column
+// that is numeric on most of the other lines
+func sniffHeader(lines []string, delimiter rune) bool {
+	if len(lines) == 0 {
+		return false
+	}
+	first := splitFields(lines[0], delimiter)
+	for _, field := range first {
+		if knownHeaders[strings.ToLower(unquote(field))] {
+			return true
+		}
+	}
+	for column, field := range first {
+		if isNumeric(unquote(field)) {
+			continue
+		}
+		numeric := 0
+		for _, line := range lines[1:] {
+			fields := splitFields(line, delimiter)
+			if column < len(fields) && isNumeric(unquote(fields[column])) {
+				numeric++
+			}
+		}
+		if numeric > 0 && numeric*2 > len(lines)-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// splitFields splits line on delimiter, ignoring delimiters between quotes
+func splitFields(line string, delimiter rune) []string {
+	var fields []string
+	inQuotes, start := false, 0
+	for i, ch := range line {
+		switch {
+		case ch == '"':
+			inQuotes = !inQuotes
+		case ch == delimiter && !inQuotes:
+			fields = append(fields, line[start:i])
+			start = i + utf8.RuneLen(ch)
+		}
+	}
+	return append(fields, line[start:])
+}
+
+func unquote(field string) string {
+	return strings.Trim(strings.TrimSpace(field), `"`)
+}
+
+func isNumeric(field string) bool {
+	_, err := strconv.ParseFloat(field, 64)
+	return err == nil
+}
+
+// applyOverrides replaces the sniffed settings with the ones of opts. It fails
+// with ErrInvalidDialect when an override cannot be used and with a
+// RuleViolation when the resulting comment character is the delimiter.
+func (d *Dialect) applyOverrides(opts ImportOptions) error {
+	if opts.Delimiter != "" {
+		delimiter, err := parseDelimiter(opts.Delimiter)
+		if err != nil {
+			return err
+		}
+		d.Delimiter = delimiter
+	}
+	switch opts.Comment {
+	case "":
+	case "none":
+		d.Comment = 0
+	default:
+		comment, size := utf8.DecodeRuneInString(opts.Comment)
+		if size != len(opts.Comment) || comment == '"' {
+			return ErrInvalidDialect
+		}
+		d.Comment = comment
+	}
+	if opts.Header != "" {
+		header, err := strconv.ParseBool(opts.Header)
+		if err != nil {
+			return ErrInvalidDialect
+		}
+		d.Header = header
+	}
+	if d.Comment != 0 && d.Comment == d.Delimiter {
+		return RuleViolation{RuleDialectConflict, fmt.Sprintf("The comment character %q is also the delimiter", d.Comment)}
+	}
+	log.WithFields(log.Fields{
+		"delimiter": string(d.Delimiter),
+		"comment":   string(d.Comment),
+		"quoted":    d.Quoted,
+		"header":    d.Header,
+	}).Debug("Using dialect")
+	return nil
+}
+
+func parseDelimiter(s string) (rune, error) {
+	if delimiter, ok := delimiterNames[strings.ToLower(s)]; ok {
+		return delimiter, nil
+	}
+	delimiter, size := utf8.DecodeRuneInString(s)
+	if size != len(s) || delimiter == '"' || delimiter == '\r' || delimiter == '\n' || delimiter == utf8.RuneError {
+		return 0, ErrInvalidDialect
+	}
+	return delimiter, nil
+}
@@ -0,0 +1,125 @@
+package company
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_sniffDialect(t *testing.T) {
+	type args struct {
+		sample    string
+		truncated bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want Dialect
+	}{
+		{"Is semicolon", args{"a;b;c", false}, Dialect{Delimiter: ';'}},
+		{"Is comma", args{"a,b,c", false}, Dialect{Delimiter: ','}},
+		{"Semicolon with comma in a name",
+			args{"smith, jones & co;12345;http://sj.com\ntola sales group;78229;http://tola.com\n", false},
+			Dialect{Delimiter: ';'}},
+		{"Tab with header",
+			args{"name\taddressZip\ntola sales group\t78229\n", false},
+			Dialect{Delimiter: '\t', Header: true}},
+		{"Pipe with numeric header detection",
+			args{"company|code\ntola|78229\ndirectv|38006\n", false},
+			Dialect{Delimiter: '|', Header: true}},
+		{"Quoted fields and comments",
+			args{"# exported 2019-03-01\n\"smith; jones\";12345\n\"tola\";78229\n", false},
+			Dialect{Delimiter: ';', Comment: '#', Quoted: true}},
+		{"Stray quotes",
+			args{"joe's \"best\" pizza,12345\n", false},
+			Dialect{Delimiter: ',', LazyQuotes: true}},
+		{"Ignore the truncated last line",
+			args{"a;12345\nb;12346\nc,d,e,f", true},
+			Dialect{Delimiter: ';'}},
+		{"Empty sample", args{"", false}, Dialect{Delimiter: ','}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sniffDialect(tt.args.sample, tt.args.truncated); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sniffDialect() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialect_applyOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    ImportOptions
+		want    Dialect
+		wantErr bool
+	}{
+		{"No overrides", ImportOptions{}, Dialect{Delimiter: ',', Comment: '#'}, false},
+		{"Named delimiter", ImportOptions{Delimiter: "tab"}, Dialect{Delimiter: '\t', Comment: '#'}, false},
+		{"Character delimiter", ImportOptions{Delimiter: ";"}, Dialect{Delimiter: ';', Comment: '#'}, false},
+		{"Disable comments", ImportOptions{Comment: "none"}, Dialect{Delimiter: ','}, false},
+		{"Force header", ImportOptions{Header: "true"}, Dialect{Delimiter: ',', Comment: '#', Header: true}, false},
+		{"Invalid delimiter", ImportOptions{Delimiter: "ab"}, Dialect{}, true},
+		{"Quote delimiter", ImportOptions{Delimiter: `"`}, Dialect{}, true},
+		{"Comment equal to delimiter", ImportOptions{Comment: ","}, Dialect{}, true},
+		{"Delimiter equal to comment", ImportOptions{Delimiter: "#"}, Dialect{}, true},
+		{"Comment and delimiter swapped", ImportOptions{Delimiter: "#", Comment: ","}, Dialect{Delimiter: '#', Comment: ','}, false},
+		{"Invalid header", ImportOptions{Header: "maybe"}, Dialect{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Dialect{Delimiter: ',', Comment: '#'}
+			err := d.applyOverrides(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Dialect.applyOverrides() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(d, tt.want) {
+				t.Errorf("Dialect.applyOverrides() = %+v, want %+v", d, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportOptions_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    ImportOptions
+		wantErr error
+	}{
+		{"Comma comment", ImportOptions{Comment: ","}, nil},
+		{"Comment and delimiter", ImportOptions{Delimiter: "semicolon", Comment: ","}, nil},
+		{"Comment equal to delimiter", ImportOptions{Delimiter: ";", Comment: ";"}, RuleViolation{RuleDialectConflict, `The comment character ';' is also the delimiter`}},
+		{"Invalid comment", ImportOptions{Comment: "//"}, ErrInvalidDialect},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.validate(); err != tt.wantErr {
+				t.Errorf("ImportOptions.validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_newCSVRowReader_dialectConflict(t *testing.T) {
+	_, err := newCSVRowReader(strings.NewReader("name;zip\nfoo;12345\n"), ImportOptions{Comment: ";"})
+	if v, ok := err.(RuleViolation); !ok || v.Rule != RuleDialectConflict {
+		t.Errorf("newCSVRowReader() error = %v, want a %v violation", err, RuleDialectConflict)
+	}
+}
+
+func Test_newCSVRowReader(t *testing.T) {
+	r, err := newCSVRowReader(strings.NewReader("name;addresszip;website\n\nsmith, jones & co;12345\n"), ImportOptions{})
+	if err != nil {
+		t.Fatalf("newCSVRowReader() error = %v", err)
+	}
+	row, err := r.Read()
+	if err != nil {
+		t.Fatalf("csvRowReader.Read() error = %v", err)
+	}
+	if !reflect.DeepEqual(row, []string{"smith, jones & co", "12345"}) {
+		t.Errorf("csvRowReader.Read() = %v", row)
+	}
+	if line := r.(lineReader).Line(); line != 3 {
+		t.Errorf("csvRowReader.Line() = %v, want 3", line)
+	}
+}
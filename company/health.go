@@ -0,0 +1,161 @@
+package company
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrCatalogLoading is reported until InitDatabase loads the catalog
+var ErrCatalogLoading = errors.New("Catalog load in progress")
+
+// CatalogStatus reports the initial load of the catalog
+type CatalogStatus struct {
+	Loaded bool `json:"loaded" example:"true"`
+	Rows   int  `json:"rows" example:"1000"`
+	// Unchanged reports a catalog skipped as it was already loaded
+	Unchanged bool `json:"unchanged,omitempty" example:"false"`
+}
+
+// ImportStatus reports the imports being processed by the workers
+type ImportStatus struct {
+	Busy int `json:"busy" example:"2"`
+	// Workers is the number of imports processed at once, 0 for no limit
+	Workers   int  `json:"workers" example:"4"`
+	Saturated bool `json:"saturated" example:"false"`
+}
+
+// catalogLoad tracks the initial load of the catalog by InitDatabase
+type catalogLoad struct {
+	mu     sync.RWMutex
+	status CatalogStatus
+	err    error
+}
+
+func (l *catalogLoad) finish(report Report, err error) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.status = CatalogStatus{Loaded: err == nil, Rows: report.Rows, Unchanged: report.Unchanged}
+	l.err = err
+}
+
+func (l *catalogLoad) get() (CatalogStatus, error) {
+	if l == nil {
+		return CatalogStatus{}, ErrCatalogLoading
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if !l.status.Loaded && l.err == nil {
+		return l.status, ErrCatalogLoading
+	}
+	return l.status, l.err
+}
+
+// workerPool bounds the imports processed at once. Imports wait for a free
+// worker when every one is busy.
+type workerPool struct {
+	slots chan struct{}
+	busy  int32
+}
+
+// newWorkerPool returns a workerPool of size workers, which does not bound
+// the imports when size is not positive
+func newWorkerPool(size int) *workerPool {
+	if size <= 0 {
+		return &workerPool{}
+	}
+	return &workerPool{slots: make(chan struct{}, size)}
+}
+
+// acquire waits for a free worker, failing with the error of ctx when it
+// ends first
+func (p *workerPool) acquire(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	if p.slots != nil {
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	atomic.AddInt32(&p.busy, 1)
+	return nil
+}
+
+func (p *workerPool) release() {
+	if p == nil {
+		return
+	}
+	atomic.AddInt32(&p.busy, -1)
+	if p.slots != nil {
+		<-p.slots
+	}
+}
+
+func (p *workerPool) status() ImportStatus {
+	if p == nil {
+		return ImportStatus{}
+	}
+	s := ImportStatus{Busy: int(atomic.LoadInt32(&p.busy)), Workers: cap(p.slots)}
+	s.Saturated = s.Workers > 0 && s.Busy >= s.Workers
+	return s
+}
+
+// jobTracker tracks the imports running, so that shutdown can wait for them
+type jobTracker struct {
+	mu      sync.Mutex
+	running int
+	// idle is closed once the last running job is done
+	idle chan struct{}
+}
+
+func (t *jobTracker) start() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.running == 0 {
+		t.idle = make(chan struct{})
+	}
+	t.running++
+}
+
+func (t *jobTracker) done() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.running--
+	if t.running == 0 {
+		close(t.idle)
+	}
+}
+
+// wait waits for the running jobs to be done, failing with the error of ctx
+// when it ends first
+func (t *jobTracker) wait(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	idle := t.idle
+	running := t.running
+	t.mu.Unlock()
+	if running == 0 {
+		return nil
+	}
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,97 @@
+package company
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_companyService_catalogStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    CatalogStatus
+		wantErr bool
+	}{
+		{"Catalog loaded", "../resource/q1_catalog.csv", CatalogStatus{Loaded: true}, false},
+		{"Catalog file missing", "missing.csv", CatalogStatus{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(NewMemoryRepository(), ImportConfig{}, Timeouts{}).(companyService)
+			if _, err := s.catalogStatus(); err != ErrCatalogLoading {
+				t.Errorf("companyService.catalogStatus() before load error = %v, want %v", err, ErrCatalogLoading)
+			}
+			s.InitDatabase(context.Background(), tt.file)
+			got, err := s.catalogStatus()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("companyService.catalogStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Loaded != tt.want.Loaded || (got.Loaded && got.Rows == 0) {
+				t.Errorf("companyService.catalogStatus() = %+v, want loaded %v", got, tt.want.Loaded)
+			}
+		})
+	}
+}
+
+func Test_companyService_InitDatabase_noFile(t *testing.T) {
+	s := NewService(NewMemoryRepository(), ImportConfig{}, Timeouts{}).(companyService)
+	if err := s.InitDatabase(context.Background(), ""); err != nil {
+		t.Fatalf("companyService.InitDatabase() error = %v", err)
+	}
+	if got, err := s.catalogStatus(); err != nil || !got.Loaded {
+		t.Errorf("companyService.catalogStatus() = %+v, %v, want loaded", got, err)
+	}
+}
+
+func Test_workerPool_status(t *testing.T) {
+	p := newWorkerPool(2)
+	p.acquire(context.Background())
+	if got := p.status(); got != (ImportStatus{Busy: 1, Workers: 2}) {
+		t.Errorf("workerPool.status() = %+v, want 1 of 2 busy", got)
+	}
+	p.acquire(context.Background())
+	if got := p.status(); !got.Saturated {
+		t.Errorf("workerPool.status() = %+v, want saturated", got)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.acquire(ctx); err != context.DeadlineExceeded {
+		t.Errorf("saturated workerPool.acquire() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	p.release()
+	p.release()
+	if got := newWorkerPool(0).status(); got.Saturated || got.Workers != 0 {
+		t.Errorf("unbounded workerPool.status() = %+v, want not saturated", got)
+	}
+}
+
+func Test_catalogLoad_get(t *testing.T) {
+	var l *catalogLoad
+	if _, err := l.get(); err != ErrCatalogLoading {
+		t.Errorf("nil catalogLoad.get() error = %v, want %v", err, ErrCatalogLoading)
+	}
+	l = &catalogLoad{}
+	l.finish(Report{Rows: 3}, errors.New("mock error"))
+	if got, err := l.get(); err == nil || got.Loaded {
+		t.Errorf("catalogLoad.get() = %+v, %v, want failed load", got, err)
+	}
+}
+
+func Test_jobTracker_wait(t *testing.T) {
+	var j jobTracker
+	if err := j.wait(context.Background()); err != nil {
+		t.Errorf("idle jobTracker.wait() error = %v", err)
+	}
+	j.start()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := j.wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("busy jobTracker.wait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	go j.done()
+	if err := j.wait(context.Background()); err != nil {
+		t.Errorf("jobTracker.wait() once done error = %v", err)
+	}
+}
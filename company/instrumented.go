@@ -0,0 +1,229 @@
+package company
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/marcospsbrito/dic/database"
+	"github.com/marcospsbrito/dic/metrics"
+	"github.com/marcospsbrito/dic/tracing"
+)
+
+// instrumentedRepository traces the operations of a Repository and records
+// their latency
+type instrumentedRepository struct {
+	Repository
+}
+
+// NewInstrumentedRepository returns a Repository tracing the operations of r
+// and recording their latency
+func NewInstrumentedRepository(r Repository) Repository {
+	return instrumentedRepository{r}
+}
+
+func (r instrumentedRepository) ForTenant(tenant string) Repository {
+	return instrumentedRepository{r.Repository.ForTenant(tenant)}
+}
+
+func (r instrumentedRepository) CopyCatalog(ctx context.Context, from string) (int, error) {
+	done := database.Instrument(ctx, "company", "CopyCatalog")
+	copied, err := r.Repository.CopyCatalog(ctx, from)
+	done(err)
+	return copied, err
+}
+
+func (r instrumentedRepository) FindAll(ctx context.Context) ([]Company, error) {
+	done := database.Instrument(ctx, "company", "FindAll")
+	companies, err := r.Repository.FindAll(ctx)
+	done(err)
+	return companies, err
+}
+
+func (r instrumentedRepository) FindByNameAndZip(ctx context.Context, name string, zipcode Zipcode) (Company, error) {
+	done := database.Instrument(ctx, "company", "FindByNameAndZip")
+	c, err := r.Repository.FindByNameAndZip(ctx, name, zipcode)
+	done(err)
+	return c, err
+}
+
+func (r instrumentedRepository) Add(ctx context.Context, c Company) error {
+	done := database.Instrument(ctx, "company", "Add")
+	err := r.Repository.Add(ctx, c)
+	done(err)
+	return err
+}
+
+func (r instrumentedRepository) FindCatalogCompanies(ctx context.Context, file string, companies []Company) ([]Company, error) {
+	done := database.Instrument(ctx, "company", "FindCatalogCompanies")
+	found, err := r.Repository.FindCatalogCompanies(ctx, file, companies)
+	done(err)
+	return found, err
+}
+
+func (r instrumentedRepository) UpsertCompanies(ctx context.Context, companies []Company) error {
+	done := database.Instrument(ctx, "company", "UpsertCompanies")
+	err := r.Repository.UpsertCompanies(ctx, companies)
+	done(err)
+	return err
+}
+
+func (r instrumentedRepository) RemoveCompanies(ctx context.Context, ids []bson.ObjectId) error {
+	done := database.Instrument(ctx, "company", "RemoveCompanies")
+	err := r.Repository.RemoveCompanies(ctx, ids)
+	done(err)
+	return err
+}
+
+func (r instrumentedRepository) FindCatalogVersion(ctx context.Context, file string) (CatalogVersion, error) {
+	done := database.Instrument(ctx, "company", "FindCatalogVersion")
+	v, err := r.Repository.FindCatalogVersion(ctx, file)
+	done(err)
+	return v, err
+}
+
+func (r instrumentedRepository) SaveCatalogVersion(ctx context.Context, v CatalogVersion) error {
+	done := database.Instrument(ctx, "company", "SaveCatalogVersion")
+	err := r.Repository.SaveCatalogVersion(ctx, v)
+	done(err)
+	return err
+}
+
+func (r instrumentedRepository) MatchCompanies(ctx context.Context, companies []Company) ([]Company, error) {
+	done := database.Instrument(ctx, "company", "MatchCompanies")
+	matches, err := r.Repository.MatchCompanies(ctx, companies)
+	done(err)
+	return matches, err
+}
+
+func (r instrumentedRepository) MergeWebsites(ctx context.Context, companies []Company) ([]error, error) {
+	done := database.Instrument(ctx, "company", "MergeWebsites")
+	outcomes, err := r.Repository.MergeWebsites(ctx, companies)
+	done(err)
+	return outcomes, err
+}
+
+func (r instrumentedRepository) FindUpload(ctx context.Context, hash string, key string) (Upload, error) {
+	done := database.Instrument(ctx, "company", "FindUpload")
+	u, err := r.Repository.FindUpload(ctx, hash, key)
+	done(err)
+	return u, err
+}
+
+func (r instrumentedRepository) ClaimUpload(ctx context.Context, u Upload, previous string) error {
+	done := database.Instrument(ctx, "company", "ClaimUpload")
+	err := r.Repository.ClaimUpload(ctx, u, previous)
+	done(err)
+	return err
+}
+
+func (r instrumentedRepository) SaveUpload(ctx context.Context, u Upload) error {
+	done := database.Instrument(ctx, "company", "SaveUpload")
+	err := r.Repository.SaveUpload(ctx, u)
+	done(err)
+	return err
+}
+
+func (r instrumentedRepository) StageWebsites(ctx context.Context, importID bson.ObjectId, companies []Company) ([]error, error) {
+	done := database.Instrument(ctx, "company", "StageWebsites")
+	outcomes, err := r.Repository.StageWebsites(ctx, importID, companies)
+	done(err)
+	return outcomes, err
+}
+
+func (r instrumentedRepository) CommitStaged(ctx context.Context, importID bson.ObjectId) error {
+	done := database.Instrument(ctx, "company", "CommitStaged")
+	err := r.Repository.CommitStaged(ctx, importID)
+	done(err)
+	return err
+}
+
+func (r instrumentedRepository) DiscardStaged(ctx context.Context, importID bson.ObjectId) error {
+	done := database.Instrument(ctx, "company", "DiscardStaged")
+	err := r.Repository.DiscardStaged(ctx, importID)
+	done(err)
+	return err
+}
+
+func (r instrumentedRepository) RecoverStaged(ctx context.Context, before time.Time) (int, error) {
+	done := database.Instrument(ctx, "company", "RecoverStaged")
+	n, err := r.Repository.RecoverStaged(ctx, before)
+	done(err)
+	return n, err
+}
+
+// sourceLabel returns the metrics label of source, grouping the sources
+// without rules so that callers cannot create series at will
+func (s companyService) sourceLabel(source string) string {
+	if _, ok := s.config.Rules[source]; ok || source == SourceCatalog || source == SourceWebsites {
+		return source
+	}
+	return "other"
+}
+
+// observeImport records an import job of source and its rows
+func (s companyService) observeImport(source string, status string, report Report) {
+	source = s.sourceLabel(source)
+	metrics.Imports.WithLabelValues(source, status).Inc()
+	rejected := report.rejectionsByRule()
+	total := 0
+	for rule, count := range rejected {
+		if rule == "" {
+			rule = "none"
+		}
+		metrics.ImportRejections.WithLabelValues(source, rule).Add(float64(count))
+		total += count
+	}
+	metrics.ImportRows.WithLabelValues(source, "read").Add(float64(report.Rows))
+	metrics.ImportRows.WithLabelValues(source, "merged").Add(float64(report.Merged))
+	metrics.ImportRows.WithLabelValues(source, "rejected").Add(float64(total))
+}
+
+// matchScore returns the share of the words of search found in name, both
+// compared by their matching keys
+func matchScore(search string, name string) float64 {
+	words := strings.Fields(foldKey(search))
+	if len(words) == 0 {
+		return 0
+	}
+	found := make(map[string]bool)
+	for _, w := range strings.Fields(foldKey(name)) {
+		found[w] = true
+	}
+	matched := 0
+	for _, w := range words {
+		if found[w] {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(words))
+}
+
+// importBatchSize is the number of rows traced by each import.batch span
+const importBatchSize = 500
+
+// importBatch is the span of a batch of rows of an import
+type importBatch struct {
+	span  trace.Span
+	start Report
+}
+
+// startBatch starts the span of the batch following the rows of report
+func startBatch(ctx context.Context, report Report) importBatch {
+	_, span := tracing.Start(ctx, "import.batch", attribute.Int("batch.firstRow", report.Rows+1))
+	return importBatch{span, report}
+}
+
+// end ends the span of b, whose rows are the ones of report read since b
+// started
+func (b importBatch) end(report Report, err error) {
+	b.span.SetAttributes(
+		attribute.Int("batch.rows", report.Rows-b.start.Rows),
+		attribute.Int("batch.merged", report.Merged-b.start.Merged),
+		attribute.Int("batch.rejected", len(report.Rejected)-len(b.start.Rejected)))
+	tracing.End(b.span, err)
+}
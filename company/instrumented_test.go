@@ -0,0 +1,34 @@
+package company
+
+import "testing"
+
+func Test_matchScore(t *testing.T) {
+	tests := []struct {
+		name   string
+		search string
+		found  string
+		want   float64
+	}{
+		{"Same name", "Tola Sales Group", "tola sales group", 1},
+		{"Accents and case ignored", "Tolá SALES", "tola sales group", 1},
+		{"Some words found", "tola marketing", "tola sales group", 0.5},
+		{"No words found", "directv", "tola sales group", 0},
+		{"Empty search", "", "tola sales group", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchScore(tt.search, tt.found); got != tt.want {
+				t.Errorf("matchScore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReport_rejectionsByRule(t *testing.T) {
+	r := Report{Rejected: []Rejection{{Rule: RuleInvalidZipcode}, {Rule: ""}}}
+	r.add(Report{Rejected: []Rejection{{Rule: RuleInvalidZipcode}, {Rule: RuleMissingFields}}})
+	got := r.rejectionsByRule()
+	if got[RuleInvalidZipcode] != 2 || got[RuleMissingFields] != 1 || got[""] != 1 {
+		t.Errorf("Report.rejectionsByRule() = %v", got)
+	}
+}
@@ -0,0 +1,398 @@
+package company
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/marcospsbrito/dic/auth"
+)
+
+// memoryStore keeps the records of every tenant in memory
+type memoryStore struct {
+	mu        sync.RWMutex
+	companies []Company
+	uploads   map[bson.ObjectId]Upload
+	staging   map[bson.ObjectId][]stagedWebsite
+	catalogs  []CatalogVersion
+}
+
+// memoryRepository is an embedded Repository that keeps every record in
+// memory. It is meant for local runs and tests where no database is available.
+// Operations fail with the error of their context when it is already done.
+type memoryRepository struct {
+	*memoryStore
+	tenant string
+}
+
+// NewMemoryRepository returns an embedded Repository impl scoped to the
+// default tenant
+func NewMemoryRepository() Repository {
+	return memoryRepository{
+		memoryStore: &memoryStore{
+			uploads: make(map[bson.ObjectId]Upload),
+			staging: make(map[bson.ObjectId][]stagedWebsite),
+		},
+		tenant: auth.DefaultTenant,
+	}
+}
+
+func (r memoryRepository) ForTenant(tenant string) Repository {
+	r.tenant = tenant
+	return r
+}
+
+func (r memoryRepository) FindAll(ctx context.Context) ([]Company, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var results []Company
+	for _, c := range r.companies {
+		if c.Tenant == r.tenant {
+			results = append(results, c)
+		}
+	}
+	return results, nil
+}
+
+func (r memoryRepository) FindByNameAndZip(ctx context.Context, name string, zipcode Zipcode) (Company, error) {
+	if err := ctx.Err(); err != nil {
+		return Company{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.companies {
+		if c.Tenant == r.tenant && c.Zipcode == zipcode && matchesText(c.Name, name) {
+			return c, nil
+		}
+	}
+	return Company{}, mgo.ErrNotFound
+}
+
+func (r memoryRepository) Add(ctx context.Context, c Company) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.companies {
+		if e.Tenant == r.tenant && e.Zipcode == c.Zipcode && matchesText(e.Name, c.Name) {
+			return nil
+		}
+	}
+	if c.ID == "" {
+		c.ID = bson.NewObjectId()
+	}
+	c.Tenant = r.tenant
+	r.companies = append(r.companies, c)
+	return nil
+}
+
+func (r memoryRepository) FindCatalogCompanies(ctx context.Context, file string, companies []Company) ([]Company, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	keys := make(map[companyKey]bool, len(companies))
+	for _, c := range companies {
+		keys[companyKey{c.Name, c.Zipcode}] = true
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var results []Company
+	for _, c := range r.companies {
+		if c.Tenant == r.tenant && (c.Catalog == file || keys[companyKey{c.Name, c.Zipcode}]) {
+			results = append(results, c)
+		}
+	}
+	return results, nil
+}
+
+func (r memoryRepository) UpsertCompanies(ctx context.Context, companies []Company) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing := make(map[companyKey]int)
+	for i, c := range r.companies {
+		if c.Tenant == r.tenant {
+			existing[companyKey{c.Name, c.Zipcode}] = i
+		}
+	}
+	for _, c := range companies {
+		if i, ok := existing[companyKey{c.Name, c.Zipcode}]; ok {
+			r.companies[i].Catalog = c.Catalog
+			continue
+		}
+		existing[companyKey{c.Name, c.Zipcode}] = len(r.companies)
+		r.companies = append(r.companies, Company{
+			ID:      bson.NewObjectId(),
+			Tenant:  r.tenant,
+			Name:    c.Name,
+			Zipcode: c.Zipcode,
+			Catalog: c.Catalog,
+		})
+	}
+	return nil
+}
+
+func (r memoryRepository) RemoveCompanies(ctx context.Context, ids []bson.ObjectId) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	removed := make(map[bson.ObjectId]bool, len(ids))
+	for _, id := range ids {
+		removed[id] = true
+	}
+	kept := r.companies[:0]
+	for _, c := range r.companies {
+		if c.Tenant != r.tenant || !removed[c.ID] {
+			kept = append(kept, c)
+		}
+	}
+	r.companies = kept
+	return nil
+}
+
+func (r memoryRepository) FindCatalogVersion(ctx context.Context, file string) (CatalogVersion, error) {
+	if err := ctx.Err(); err != nil {
+		return CatalogVersion{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, v := range r.catalogs {
+		if v.Tenant == r.tenant && v.File == file {
+			return v, nil
+		}
+	}
+	return CatalogVersion{}, mgo.ErrNotFound
+}
+
+func (r memoryRepository) SaveCatalogVersion(ctx context.Context, v CatalogVersion) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v.Tenant = r.tenant
+	for i, e := range r.catalogs {
+		if e.Tenant == v.Tenant && e.File == v.File {
+			r.catalogs[i] = v
+			return nil
+		}
+	}
+	r.catalogs = append(r.catalogs, v)
+	return nil
+}
+
+func (r memoryRepository) CopyCatalog(ctx context.Context, from string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing := make(map[Company]bool)
+	for _, c := range r.companies {
+		if c.Tenant == r.tenant {
+			existing[Company{Name: c.Name, Zipcode: c.Zipcode}] = true
+		}
+	}
+	copied := 0
+	for _, c := range r.companies {
+		key := Company{Name: c.Name, Zipcode: c.Zipcode}
+		if c.Tenant != from || existing[key] {
+			continue
+		}
+		existing[key] = true
+		c.ID, c.Tenant, c.Catalog = bson.NewObjectId(), r.tenant, ""
+		r.companies = append(r.companies, c)
+		copied++
+	}
+	return copied, nil
+}
+
+func (r memoryRepository) MatchCompanies(ctx context.Context, companies []Company) ([]Company, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	matches := make([]Company, len(companies))
+	for i, c := range companies {
+		if j := r.indexByNameOrZip(c.Name, c.Zipcode); j >= 0 {
+			matches[i] = r.companies[j]
+		}
+	}
+	return matches, nil
+}
+
+// MergeWebsites merges the websites of companies under a single lock, as
+// one transaction
+func (r memoryRepository) MergeWebsites(ctx context.Context, companies []Company) ([]error, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	outcomes := make([]error, len(companies))
+	for i, c := range companies {
+		j := r.indexByID(c.ID)
+		if j < 0 {
+			outcomes[i] = mgo.ErrNotFound
+			continue
+		}
+		r.companies[j].Website = c.Website
+	}
+	return outcomes, nil
+}
+
+func (r memoryRepository) FindUpload(ctx context.Context, hash string, key string) (Upload, error) {
+	if err := ctx.Err(); err != nil {
+		return Upload{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, u := range r.uploads {
+		if u.Tenant == r.tenant && (u.Hash == hash || (key != "" && u.IdempotencyKey == key)) {
+			return u, nil
+		}
+	}
+	return Upload{}, mgo.ErrNotFound
+}
+
+func (r memoryRepository) ClaimUpload(ctx context.Context, u Upload, previous string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, e := range r.uploads {
+		if id != u.ID && e.Tenant == r.tenant && (e.Hash == u.Hash || (u.IdempotencyKey != "" && e.IdempotencyKey == u.IdempotencyKey)) {
+			return &mgo.LastError{Code: 11000, Err: "duplicate key"}
+		}
+	}
+	e, ok := r.uploads[u.ID]
+	switch {
+	case previous == "" && ok:
+		return &mgo.LastError{Code: 11000, Err: "duplicate key"}
+	case previous != "" && (!ok || e.Tenant != r.tenant || e.Status != previous):
+		return mgo.ErrNotFound
+	}
+	u.Tenant = r.tenant
+	r.uploads[u.ID] = u
+	return nil
+}
+
+func (r memoryRepository) SaveUpload(ctx context.Context, u Upload) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u.Tenant = r.tenant
+	r.uploads[u.ID] = u
+	return nil
+}
+
+func (r memoryRepository) StageWebsites(ctx context.Context, importID bson.ObjectId, companies []Company) ([]error, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	outcomes := make([]error, len(companies))
+	for i, c := range companies {
+		j := r.indexByID(c.ID)
+		if j < 0 {
+			outcomes[i] = mgo.ErrNotFound
+			continue
+		}
+		r.staging[importID] = append(r.staging[importID], stagedWebsite{
+			Tenant:   r.tenant,
+			Import:   importID,
+			Company:  c.ID,
+			Website:  c.Website,
+			Previous: r.companies[j].Website,
+		})
+	}
+	return outcomes, nil
+}
+
+// CommitStaged applies the staged changes under a single lock, so other
+// callers never observe a partially committed import
+func (r memoryRepository) CommitStaged(ctx context.Context, importID bson.ObjectId) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, w := range r.staging[importID] {
+		if w.Tenant != r.tenant {
+			continue
+		}
+		for i := range r.companies {
+			if r.companies[i].ID == w.Company && r.companies[i].Tenant == r.tenant {
+				r.companies[i].Website = w.Website
+			}
+		}
+	}
+	delete(r.staging, importID)
+	return nil
+}
+
+func (r memoryRepository) DiscardStaged(ctx context.Context, importID bson.ObjectId) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.staging, importID)
+	return nil
+}
+
+// RecoverStaged has nothing to recover, since the commits of the memory
+// repository cannot be interrupted
+func (r memoryRepository) RecoverStaged(ctx context.Context, before time.Time) (int, error) {
+	return 0, ctx.Err()
+}
+
+func (r memoryRepository) indexByID(id bson.ObjectId) int {
+	for i, c := range r.companies {
+		if c.Tenant == r.tenant && c.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r memoryRepository) indexByNameOrZip(name string, zipcode Zipcode) int {
+	key := foldKey(name)
+	for i, c := range r.companies {
+		if c.Tenant == r.tenant && (foldKey(c.Name) == key || c.Zipcode == zipcode) {
+			return i
+		}
+	}
+	return -1
+}
+
+// matchesText mimics a mongo $text search: the name matches when any of the
+// search terms is one of its words, ignoring case and accents
+func matchesText(name string, search string) bool {
+	words := strings.Fields(foldKey(name))
+	for _, term := range strings.Fields(foldKey(search)) {
+		for _, w := range words {
+			if w == term {
+				return true
+			}
+		}
+	}
+	return false
+}
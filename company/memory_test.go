@@ -0,0 +1,226 @@
+package company
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/marcospsbrito/dic/auth"
+)
+
+func newTestMemoryRepository(companies ...Company) Repository {
+	r := NewMemoryRepository()
+	for _, c := range companies {
+		r.Add(context.Background(), c)
+	}
+	return r
+}
+
+func Test_memoryRepository_FindByNameAndZip(t *testing.T) {
+	r := newTestMemoryRepository(Company{Name: "tola sales group", Zipcode: 78229})
+	type args struct {
+		name    string
+		zipcode Zipcode
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr error
+	}{
+		{"Find by a word of the name", args{"TOLA", 78229}, "tola sales group", nil},
+		{"Find ignoring accents", args{"Tolá", 78229}, "tola sales group", nil},
+		{"Not found by zipcode", args{"tola", 78228}, "", mgo.ErrNotFound},
+		{"Not found by name", args{"directv", 78229}, "", mgo.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.FindByNameAndZip(context.Background(), tt.args.name, tt.args.zipcode)
+			if err != tt.wantErr {
+				t.Errorf("memoryRepository.FindByNameAndZip() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got.Name != tt.want {
+				t.Errorf("memoryRepository.FindByNameAndZip() = %v, want %v", got.Name, tt.want)
+			}
+		})
+	}
+}
+
+func Test_memoryRepository_Add(t *testing.T) {
+	r := newTestMemoryRepository(Company{Name: "directv", Zipcode: 38006})
+	r.Add(context.Background(), Company{Name: "directv", Zipcode: 38006})
+	r.Add(context.Background(), Company{Name: "directv", Zipcode: 38007})
+	got, _ := r.FindAll(context.Background())
+	if len(got) != 2 {
+		t.Errorf("memoryRepository.Add() stored %v companies, want 2", len(got))
+	}
+}
+
+func Test_memoryRepository_FindCatalogCompanies(t *testing.T) {
+	r := newTestMemoryRepository(
+		Company{Name: "acme", Zipcode: 1, Catalog: "q1.csv"},
+		Company{Name: "globex", Zipcode: 2},
+		Company{Name: "globex", Zipcode: 3},
+		Company{Name: "initech", Zipcode: 4, Catalog: "q2.csv"},
+	)
+	got, err := r.FindCatalogCompanies(context.Background(), "q1.csv", []Company{{Name: "globex", Zipcode: 2}, {Name: "hooli", Zipcode: 5}})
+	if err != nil {
+		t.Fatalf("memoryRepository.FindCatalogCompanies() error = %v", err)
+	}
+	var names []string
+	for _, c := range got {
+		names = append(names, fmt.Sprintf("%s %v", c.Name, c.Zipcode))
+	}
+	if want := []string{"acme 00001", "globex 00002"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("memoryRepository.FindCatalogCompanies() = %v, want %v", names, want)
+	}
+}
+
+func Test_memoryRepository_MergeWebsites(t *testing.T) {
+	r := newTestMemoryRepository(Company{Name: "directv", Zipcode: 38006})
+	companies := []Company{
+		{Name: "directv", Website: "http://directv.com"},
+		{Name: "DirécTV", Website: "http://directv.com"},
+		{Name: "other", Zipcode: 1},
+		{Zipcode: 38006, Website: "http://directv.com.br"},
+	}
+	matches, err := r.MatchCompanies(context.Background(), companies)
+	if err != nil {
+		t.Fatalf("memoryRepository.MatchCompanies() error = %v", err)
+	}
+	for i, m := range matches {
+		if found := m.ID != ""; found != (i != 2) {
+			t.Errorf("memoryRepository.MatchCompanies() of %+v = %+v", companies[i], m)
+		}
+		companies[i].ID = m.ID
+	}
+	outcomes, err := r.MergeWebsites(context.Background(), companies)
+	if err != nil {
+		t.Fatalf("memoryRepository.MergeWebsites() error = %v", err)
+	}
+	if want := []error{nil, nil, mgo.ErrNotFound, nil}; !reflect.DeepEqual(outcomes, want) {
+		t.Errorf("memoryRepository.MergeWebsites() = %v, want %v", outcomes, want)
+	}
+	if got, _ := r.FindAll(context.Background()); got[0].Website != "http://directv.com.br" {
+		t.Errorf("memoryRepository.MergeWebsites() website = %v, want the last one merged", got[0].Website)
+	}
+}
+
+func Test_memoryRepository_Staging(t *testing.T) {
+	tests := []struct {
+		name   string
+		commit bool
+		want   string
+	}{
+		{"Commit staged websites", true, "http://directv.com"},
+		{"Discard staged websites", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestMemoryRepository(Company{Name: "directv", Zipcode: 38006})
+			id := bson.NewObjectId()
+			stored, _ := r.FindAll(context.Background())
+			outcomes, err := r.StageWebsites(context.Background(), id, []Company{
+				{ID: stored[0].ID, Website: "http://directv.com"},
+				{ID: bson.NewObjectId(), Website: "http://other.com"},
+			})
+			if err != nil {
+				t.Fatalf("memoryRepository.StageWebsites() error = %v", err)
+			}
+			if want := []error{nil, mgo.ErrNotFound}; !reflect.DeepEqual(outcomes, want) {
+				t.Errorf("memoryRepository.StageWebsites() = %v, want %v", outcomes, want)
+			}
+			if got, _ := r.FindAll(context.Background()); got[0].Website != "" {
+				t.Errorf("memoryRepository.StageWebsites() applied website %v", got[0].Website)
+			}
+			if tt.commit {
+				r.CommitStaged(context.Background(), id)
+			} else {
+				r.DiscardStaged(context.Background(), id)
+			}
+			got, _ := r.FindAll(context.Background())
+			if !reflect.DeepEqual(got[0].Website, tt.want) {
+				t.Errorf("website = %v, want %v", got[0].Website, tt.want)
+			}
+		})
+	}
+}
+
+func Test_memoryRepository_ClaimUpload(t *testing.T) {
+	r := newTestMemoryRepository()
+	u := Upload{ID: bson.NewObjectId(), Hash: "abc", Status: UploadProcessing}
+	if err := r.ClaimUpload(context.Background(), u, ""); err != nil {
+		t.Fatalf("memoryRepository.ClaimUpload() error = %v", err)
+	}
+	if err := r.ClaimUpload(context.Background(), Upload{ID: bson.NewObjectId(), Hash: "abc", Status: UploadProcessing}, ""); !mgo.IsDup(err) {
+		t.Errorf("memoryRepository.ClaimUpload() of an identical upload error = %v, want a duplicate key", err)
+	}
+	if err := r.ClaimUpload(context.Background(), u, UploadFailed); err != mgo.ErrNotFound {
+		t.Errorf("memoryRepository.ClaimUpload() of a claimed upload error = %v, want %v", err, mgo.ErrNotFound)
+	}
+	u.Status = UploadFailed
+	r.SaveUpload(context.Background(), u)
+	u.Status = UploadProcessing
+	if err := r.ClaimUpload(context.Background(), u, UploadFailed); err != nil {
+		t.Errorf("memoryRepository.ClaimUpload() of a failed upload error = %v", err)
+	}
+}
+
+func Test_memoryRepository_ForTenant(t *testing.T) {
+	r := newTestMemoryRepository(Company{Name: "directv", Zipcode: 38006}, Company{Name: "tola sales group", Zipcode: 78229, Catalog: "q1_catalog.csv"})
+	sales := r.ForTenant("sales")
+	sales.Add(context.Background(), Company{Name: "directv", Zipcode: 38006})
+	if got, _ := sales.FindAll(context.Background()); len(got) != 1 {
+		t.Errorf("memoryRepository.FindAll() of tenant = %v companies, want 1", len(got))
+	}
+	if _, err := sales.FindByNameAndZip(context.Background(), "tola", 78229); err != mgo.ErrNotFound {
+		t.Errorf("memoryRepository.FindByNameAndZip() found a company of another tenant")
+	}
+	if matches, _ := sales.MatchCompanies(context.Background(), []Company{{Name: "tola sales group", Zipcode: 78229}}); matches[0].ID != "" {
+		t.Errorf("memoryRepository.MatchCompanies() matched a company of another tenant")
+	}
+	tola, _ := r.FindByNameAndZip(context.Background(), "tola", 78229)
+	if outcomes, _ := sales.MergeWebsites(context.Background(), []Company{{ID: tola.ID, Website: "http://tola.com"}}); outcomes[0] != mgo.ErrNotFound {
+		t.Errorf("memoryRepository.MergeWebsites() changed a company of another tenant")
+	}
+	sales.SaveUpload(context.Background(), Upload{ID: bson.NewObjectId(), Hash: "abc"})
+	if _, err := r.FindUpload(context.Background(), "abc", ""); err != mgo.ErrNotFound {
+		t.Errorf("memoryRepository.FindUpload() found an upload of another tenant")
+	}
+	copied, err := sales.CopyCatalog(context.Background(), auth.DefaultTenant)
+	if err != nil || copied != 1 {
+		t.Errorf("memoryRepository.CopyCatalog() = %v, %v, want 1", copied, err)
+	}
+	got, _ := sales.FindAll(context.Background())
+	if len(got) != 2 {
+		t.Errorf("memoryRepository.FindAll() after copy = %v companies, want 2", len(got))
+	}
+	for _, c := range got {
+		if c.Catalog != "" {
+			t.Errorf("memoryRepository.CopyCatalog() kept catalog %v of the source tenant", c.Catalog)
+		}
+	}
+	if got, _ := r.FindAll(context.Background()); len(got) != 2 {
+		t.Errorf("memoryRepository.FindAll() of source tenant = %v companies, want 2", len(got))
+	}
+}
+
+func Test_memoryRepository_canceled(t *testing.T) {
+	r := newTestMemoryRepository(Company{Name: "directv", Zipcode: 38006})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.FindByNameAndZip(ctx, "directv", 38006); err != context.Canceled {
+		t.Errorf("memoryRepository.FindByNameAndZip() error = %v, want %v", err, context.Canceled)
+	}
+	if err := r.Add(ctx, Company{Name: "other", Zipcode: 1}); err != context.Canceled {
+		t.Errorf("memoryRepository.Add() error = %v, want %v", err, context.Canceled)
+	}
+	if got, _ := r.FindAll(context.Background()); len(got) != 1 {
+		t.Errorf("memoryRepository.Add() with a canceled context added a company")
+	}
+}
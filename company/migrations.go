@@ -0,0 +1,175 @@
+package company
+
+import (
+	"fmt"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/marcospsbrito/dic/auth"
+	"github.com/marcospsbrito/dic/database"
+)
+
+// Migrations of the company collections. Their versions are shared with the
+// migrations of the other packages.
+var Migrations = []database.Migration{
+	{
+		Version:     1,
+		Description: "Assign companies and uploads created before tenancy to the default tenant",
+		Up: func(db *mgo.Database) error {
+			legacy := bson.M{"tenant": bson.M{"$exists": false}}
+			backfill := bson.M{"$set": bson.M{"tenant": auth.DefaultTenant}}
+			for _, name := range []string{"Company", "Upload"} {
+				if _, err := db.C(name).UpdateAll(legacy, backfill); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		// the tenant of the records of the default tenant is removed, which
+		// only works while no other tenant has records: the collections
+		// cannot tell them apart without it
+		Down: func(db *mgo.Database) error {
+			for _, name := range []string{"Company", "Upload"} {
+				n, err := db.C(name).Find(bson.M{"tenant": bson.M{"$exists": true, "$ne": auth.DefaultTenant}}).Count()
+				if err != nil {
+					return err
+				}
+				if n > 0 {
+					return fmt.Errorf("%d records of %s belong to other tenants than %s: %v", n, name, auth.DefaultTenant, database.ErrIrreversible)
+				}
+			}
+			for _, name := range []string{"Company", "Upload"} {
+				if _, err := db.C(name).UpdateAll(bson.M{"tenant": auth.DefaultTenant}, bson.M{"$unset": bson.M{"tenant": ""}}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     2,
+		Description: "Prefix the company and upload indexes with the tenant",
+		Up: func(db *mgo.Database) error {
+			for _, err := range []error{
+				database.DropIndex(db.C("Company"), "$text:name"),
+				database.DropIndex(db.C("Upload"), "hash"),
+				database.DropIndex(db.C("Upload"), "idempotencyKey"),
+				db.C("Company").EnsureIndexKey("tenant", "$text:name"),
+				db.C("Company").EnsureIndexKey("tenant", "zipcode"),
+				db.C("Upload").EnsureIndex(mgo.Index{Key: []string{"tenant", "hash"}, Unique: true}),
+				db.C("Upload").EnsureIndex(mgo.Index{
+					Key:           []string{"tenant", "idempotencyKey"},
+					Unique:        true,
+					PartialFilter: bson.M{"idempotencyKey": bson.M{"$exists": true}},
+				}),
+			} {
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(db *mgo.Database) error {
+			for _, err := range []error{
+				database.DropIndex(db.C("Company"), "tenant", "$text:name"),
+				database.DropIndex(db.C("Company"), "tenant", "zipcode"),
+				database.DropIndex(db.C("Upload"), "tenant", "hash"),
+				database.DropIndex(db.C("Upload"), "tenant", "idempotencyKey"),
+				db.C("Company").EnsureIndexKey("$text:name"),
+				db.C("Upload").EnsureIndex(mgo.Index{Key: []string{"hash"}, Unique: true}),
+				db.C("Upload").EnsureIndex(mgo.Index{Key: []string{"idempotencyKey"}, Unique: true, Sparse: true}),
+			} {
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     3,
+		Description: "Index the staged websites by import",
+		Up: func(db *mgo.Database) error {
+			return db.C("Staging").EnsureIndexKey("import")
+		},
+		Down: func(db *mgo.Database) error {
+			return database.DropIndex(db.C("Staging"), "import")
+		},
+	},
+	{
+		Version:     6,
+		Description: "Index the catalog versions and the companies by exact name",
+		Up: func(db *mgo.Database) error {
+			for _, err := range []error{
+				db.C("Catalog").EnsureIndex(mgo.Index{Key: []string{"tenant", "file"}, Unique: true}),
+				db.C("Company").EnsureIndexKey("tenant", "name", "zipcode"),
+			} {
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(db *mgo.Database) error {
+			for _, err := range []error{
+				database.DropIndex(db.C("Catalog"), "tenant", "file"),
+				database.DropIndex(db.C("Company"), "tenant", "name", "zipcode"),
+			} {
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     7,
+		Description: "Store zipcodes as zero-padded strings",
+		Up: func(db *mgo.Database) error {
+			return convertZipcodes(db.C("Company"), bson.M{"$exists": true, "$not": bson.M{"$type": 2}}, func(z Zipcode) interface{} {
+				return z.String()
+			})
+		},
+		Down: func(db *mgo.Database) error {
+			return convertZipcodes(db.C("Company"), bson.M{"$type": 2}, func(z Zipcode) interface{} {
+				return int64(z)
+			})
+		},
+	},
+}
+
+// zipcodeBatch is the number of companies whose zipcode convertZipcodes
+// updates in a single bulk write
+const zipcodeBatch = 1000
+
+// convertZipcodes replaces the zipcode of the companies of c whose zipcode
+// matches query with the value convert returns for it, in bulk writes of
+// zipcodeBatch companies
+func convertZipcodes(c *mgo.Collection, query bson.M, convert func(Zipcode) interface{}) error {
+	var company struct {
+		ID      bson.ObjectId `bson:"_id"`
+		Zipcode Zipcode       `bson:"zipcode"`
+	}
+	iter := c.Find(bson.M{"zipcode": query}).Select(bson.M{"zipcode": 1}).Batch(zipcodeBatch).Iter()
+	bulk, pending := c.Bulk(), 0
+	for iter.Next(&company) {
+		bulk.Update(bson.M{"_id": company.ID}, bson.M{"$set": bson.M{"zipcode": convert(company.Zipcode)}})
+		if pending++; pending < zipcodeBatch {
+			continue
+		}
+		if _, err := bulk.Run(); err != nil {
+			iter.Close()
+			return err
+		}
+		bulk, pending = c.Bulk(), 0
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+	if pending == 0 {
+		return nil
+	}
+	_, err := bulk.Run()
+	return err
+}
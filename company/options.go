@@ -0,0 +1,91 @@
+package company
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// ImportConfig holds the import settings shared by every upload
+type ImportConfig struct {
+	// MaxErrorRate is the highest rejected/rows ratio an atomic import
+	// accepts before it is rolled back
+	MaxErrorRate float64
+	// Rules validate the rows of each import source
+	Rules Rules
+	// Workers is the number of uploads imported at once, others waiting for
+	// a free worker. Uploads are not bounded when it is not positive.
+	Workers int
+	// BatchSize is the number of rows written together, defaultBatchSize
+	// when it is not positive
+	BatchSize int
+	// ParseWorkers and MatchWorkers are the number of workers parsing the
+	// rows and matching the batches of each import, 1 when not positive
+	ParseWorkers int
+	MatchWorkers int
+}
+
+// Timeouts bound the operations of a Service. An operation is not bounded
+// when its timeout is not positive.
+type Timeouts struct {
+	// Search bounds the search of a company
+	Search time.Duration
+	// Export bounds the listing of every company of a tenant
+	Export time.Duration
+	// Import bounds each upload and the initial load of the catalog
+	Import time.Duration
+	// Copy bounds the copy of a catalog between tenants
+	Copy time.Duration
+}
+
+// WithTimeout returns a context of ctx that also ends after timeout, unless
+// timeout is not positive
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// ImportOptions holds the settings of a single upload
+type ImportOptions struct {
+	IdempotencyKey string
+	// Caller identifies who uploaded the file, for auditing
+	Caller string
+	// Source selects the validation rules applied to the rows
+	Source string
+	// Atomic stages every row and only commits them if the error rate stays
+	// within ImportConfig.MaxErrorRate
+	Atomic bool
+	// Format selects the RowReader of the file, csv when empty
+	Format string
+	// Sheet is the worksheet read from xlsx files, the first one when empty
+	Sheet string
+	// Charset of text files, detected from their content when empty
+	Charset string
+	// Delimiter, Comment and Header override the dialect sniffed from csv
+	// files. Delimiter is a single character or comma, semicolon, tab or
+	// pipe; Comment is a single character or none; Header is true or false.
+	Delimiter string
+	Comment   string
+	Header    string
+	// skip is the number of leading rows an interrupted import of the same
+	// file already imported
+	skip int
+}
+
+// validate checks the options before any file is read. Overrides conflicting
+// with the sniffed dialect are only found once the file is read.
+func (o ImportOptions) validate() error {
+	if o.Format != "" && !hasRowReader(o.Format) {
+		return ErrUnsupportedFormat
+	}
+	if o.Charset != "" {
+		if _, err := htmlindex.Get(o.Charset); err != nil {
+			return ErrUnsupportedCharset
+		}
+	}
+	var d Dialect
+	return d.applyOverrides(o)
+}
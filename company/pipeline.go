@@ -0,0 +1,254 @@
+package company
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// importRow is a row of an import flowing through the pipeline. seq numbers
+// the rows in the order they were read.
+type importRow struct {
+	seq    int
+	line   int
+	fields []string
+	// skipped rows were imported by a previous run of an interrupted import
+	skipped bool
+	company Company
+	// err rejects the row
+	err error
+}
+
+// pending reports whether the row is still to be imported
+func (r *importRow) pending() bool {
+	return !r.skipped && r.err == nil
+}
+
+// rowBatch is a batch of consecutive rows, seq numbering the batches in the
+// order of their rows
+type rowBatch struct {
+	seq  int
+	rows []*importRow
+	// err fails the whole batch
+	err error
+}
+
+// pipeline imports the rows of a file through concurrent stages connected
+// by bounded channels: a reader, parse workers validating and parsing rows,
+// which a batcher puts back in order and groups in batches, match workers
+// matching the batches and a writer, the caller of run, writing them one at
+// a time in the order of the file. The results do not depend on the number
+// of workers. At most window rows are between the reader and the writer, so
+// the reader waits for slower stages.
+type pipeline struct {
+	ctx    context.Context
+	sink   rowSink
+	rules  Rules
+	source string
+	skip   int
+	size   int
+	window chan struct{}
+	rows   chan *importRow
+	parsed chan *importRow
+	// batches and matched hold the batches before and after their match
+	batches chan *rowBatch
+	matched chan *rowBatch
+	readErr error
+}
+
+// newPipeline returns the pipeline importing rows into sink, until ctx is
+// done
+func (s companyService) newPipeline(ctx context.Context, opts ImportOptions, sink rowSink) *pipeline {
+	size, matchers := s.config.batchSize(), s.config.matchWorkers()
+	return &pipeline{
+		ctx:     ctx,
+		sink:    sink,
+		rules:   s.config.Rules,
+		source:  opts.Source,
+		skip:    opts.skip,
+		size:    size,
+		window:  make(chan struct{}, size*(matchers+2)),
+		rows:    make(chan *importRow, size),
+		parsed:  make(chan *importRow, size),
+		batches: make(chan *rowBatch, matchers),
+		matched: make(chan *rowBatch, matchers),
+	}
+}
+
+// start starts the stages before the writer, reading the rows of reader
+func (p *pipeline) start(reader RowReader, parsers int, matchers int) *sync.WaitGroup {
+	var stages, parsing, matching sync.WaitGroup
+	stages.Add(2)
+	go func() {
+		defer stages.Done()
+		p.read(reader)
+	}()
+	go func() {
+		defer stages.Done()
+		p.batch()
+	}()
+	for i := 0; i < parsers; i++ {
+		parsing.Add(1)
+		go func() {
+			defer parsing.Done()
+			p.parse()
+		}()
+	}
+	for i := 0; i < matchers; i++ {
+		matching.Add(1)
+		go func() {
+			defer matching.Done()
+			p.match()
+		}()
+	}
+	stages.Add(2)
+	go func() {
+		defer stages.Done()
+		parsing.Wait()
+		close(p.parsed)
+	}()
+	go func() {
+		defer stages.Done()
+		matching.Wait()
+		close(p.matched)
+	}()
+	return &stages
+}
+
+// read sends the rows of reader in order, waiting for room in the window
+func (p *pipeline) read(reader RowReader) {
+	defer close(p.rows)
+	for seq, line := 0, 1; ; seq, line = seq+1, line+1 {
+		fields, err := reader.Read()
+		if err != nil {
+			if err != io.EOF {
+				p.readErr = err
+			}
+			return
+		}
+		if lr, ok := reader.(lineReader); ok {
+			line = lr.Line()
+		}
+		select {
+		case p.window <- struct{}{}:
+		case <-p.ctx.Done():
+			return
+		}
+		row := &importRow{seq: seq, line: line, fields: fields, skipped: seq < p.skip}
+		select {
+		case p.rows <- row:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// parse validates and parses rows as they are read
+func (p *pipeline) parse() {
+	for row := range p.rows {
+		if !row.skipped {
+			if row.err = p.rules.check(p.source, row.fields); row.err == nil {
+				p.sink.parse(row)
+			}
+		}
+		select {
+		case p.parsed <- row:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// batch puts the parsed rows back in the order they were read and sends
+// them in batches of size rows
+func (p *pipeline) batch() {
+	defer close(p.batches)
+	early := make(map[int]*importRow)
+	next := 0
+	b := &rowBatch{}
+	for row := range p.parsed {
+		early[row.seq] = row
+		for r, ok := early[next]; ok; r, ok = early[next] {
+			delete(early, next)
+			next++
+			if b.rows = append(b.rows, r); len(b.rows) < p.size {
+				continue
+			}
+			if !p.send(b) {
+				return
+			}
+			b = &rowBatch{seq: b.seq + 1}
+		}
+	}
+	if len(b.rows) > 0 {
+		p.send(b)
+	}
+}
+
+func (p *pipeline) send(b *rowBatch) bool {
+	select {
+	case p.batches <- b:
+		return true
+	case <-p.ctx.Done():
+		return false
+	}
+}
+
+// match matches batches as they are formed
+func (p *pipeline) match() {
+	for b := range p.batches {
+		b.err = p.sink.match(p.ctx, b.rows)
+		select {
+		case p.matched <- b:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// run writes the matched batches in order, passing each written batch to
+// done, and returns the error failing the import
+func (p *pipeline) run(done func(b *rowBatch)) error {
+	early := make(map[int]*rowBatch)
+	next := 0
+	for b := range p.matched {
+		early[b.seq] = b
+		for b, ok := early[next]; ok; b, ok = early[next] {
+			delete(early, next)
+			next++
+			if err := p.ctx.Err(); err != nil {
+				return err
+			}
+			if b.err == nil {
+				b.err = p.sink.write(p.ctx, b.rows)
+			}
+			if b.err != nil {
+				return b.err
+			}
+			done(b)
+			for range b.rows {
+				<-p.window
+			}
+		}
+	}
+	if err := p.ctx.Err(); err != nil {
+		return err
+	}
+	return p.readErr
+}
+
+// parseWorkers returns the number of workers parsing the rows of an import
+func (c ImportConfig) parseWorkers() int {
+	if c.ParseWorkers <= 0 {
+		return 1
+	}
+	return c.ParseWorkers
+}
+
+// matchWorkers returns the number of workers matching the rows of an import
+func (c ImportConfig) matchWorkers() int {
+	if c.MatchWorkers <= 0 {
+		return 1
+	}
+	return c.MatchWorkers
+}
@@ -0,0 +1,146 @@
+package company
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// pipelineRepository matches every company but the ones named missing and
+// records the companies written, failing the writes of the ones named
+// conflict
+func pipelineRepository(written *[]string, matchErr error, writeErr error) Repository {
+	return repoMock{
+		MatchCompaniesFn: func(companies []Company) ([]Company, error) {
+			if matchErr != nil {
+				return nil, matchErr
+			}
+			matches := make([]Company, len(companies))
+			for i, c := range companies {
+				if c.Name != "missing" {
+					matches[i] = Company{ID: bson.NewObjectId(), Name: c.Name}
+				}
+			}
+			return matches, nil
+		},
+		MergeWebsitesFn: func(companies []Company) ([]error, error) {
+			if writeErr != nil {
+				return nil, writeErr
+			}
+			outcomes := make([]error, len(companies))
+			for i, c := range companies {
+				*written = append(*written, c.Name)
+				if c.Name == "conflict" {
+					outcomes[i] = errors.New("write conflict")
+				}
+			}
+			return outcomes, nil
+		},
+	}
+}
+
+func Test_companyService_iterateFileAndCall_pipeline(t *testing.T) {
+	const file = "a;12345;x\nb;1;y\nc;12347;z\nmissing;12348;w\nconflict;12349;v\nf;12350;u\n"
+	tests := []struct {
+		name         string
+		config       ImportConfig
+		matchErr     error
+		writeErr     error
+		wantWritten  []string
+		wantMerged   int
+		wantRejected []int
+		wantErr      bool
+	}{
+		{"One batch", ImportConfig{}, nil, nil, []string{"a", "c", "conflict", "f"}, 3, []int{2, 4, 5}, false},
+		{"Batches of two", ImportConfig{BatchSize: 2, ParseWorkers: 3, MatchWorkers: 2}, nil, nil, []string{"a", "c", "conflict", "f"}, 3, []int{2, 4, 5}, false},
+		{"Row by row", ImportConfig{BatchSize: 1, ParseWorkers: 4, MatchWorkers: 4}, nil, nil, []string{"a", "c", "conflict", "f"}, 3, []int{2, 4, 5}, false},
+		{"Match failure", ImportConfig{BatchSize: 2}, errors.New("mock error"), nil, nil, 0, nil, true},
+		{"Write failure", ImportConfig{BatchSize: 2}, nil, errors.New("mock error"), nil, 0, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var written []string
+			s := companyService{repository: pipelineRepository(&written, tt.matchErr, tt.writeErr), config: tt.config}
+			report, err := s.iterateFileAndCall(context.Background(), strings.NewReader(file), ImportOptions{}, s.mergeSink())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("companyService.iterateFileAndCall() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var rejected []int
+			for _, r := range report.Rejected {
+				rejected = append(rejected, r.Line)
+			}
+			if !reflect.DeepEqual(written, tt.wantWritten) || report.Merged != tt.wantMerged || !reflect.DeepEqual(rejected, tt.wantRejected) {
+				t.Errorf("companyService.iterateFileAndCall() wrote %v, report %+v, want %v, merged %v, rejected lines %v",
+					written, report, tt.wantWritten, tt.wantMerged, tt.wantRejected)
+			}
+		})
+	}
+}
+
+func Test_companyService_iterateFileAndCall_deterministic(t *testing.T) {
+	var file strings.Builder
+	for i := 0; i < 2000; i++ {
+		switch {
+		case i%7 == 0:
+			fmt.Fprintf(&file, "invalid%d;1;x\n", i)
+		case i%11 == 0:
+			fmt.Fprintf(&file, "missing;%05d;x\n", i)
+		default:
+			fmt.Fprintf(&file, "c%d;%05d;x\n", i, i)
+		}
+	}
+	var want Report
+	var wantWritten []string
+	for i, config := range []ImportConfig{
+		{BatchSize: 1},
+		{BatchSize: 64, ParseWorkers: 8, MatchWorkers: 4},
+		{BatchSize: 500, ParseWorkers: 3, MatchWorkers: 7},
+	} {
+		var written []string
+		s := companyService{repository: pipelineRepository(&written, nil, nil), config: config}
+		report, err := s.iterateFileAndCall(context.Background(), strings.NewReader(file.String()), ImportOptions{skip: 10}, s.mergeSink())
+		if err != nil {
+			t.Fatalf("companyService.iterateFileAndCall() with %+v error = %v", config, err)
+		}
+		if i == 0 {
+			want, wantWritten = report, written
+			continue
+		}
+		if !reflect.DeepEqual(report, want) || !reflect.DeepEqual(written, wantWritten) {
+			t.Errorf("companyService.iterateFileAndCall() with %+v = %+v, want the results of a single worker %+v", config, report, want)
+		}
+	}
+	if want.Rows != 2000 || want.Skipped != 10 || want.Merged+len(want.Rejected)+want.Skipped != want.Rows {
+		t.Errorf("companyService.iterateFileAndCall() report = %+v, want every row accounted for", want)
+	}
+}
+
+func Test_companyService_iterateFileAndCall_canceled(t *testing.T) {
+	var file strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&file, "c%d;%05d;x\n", i, i)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	writes := 0
+	s := companyService{repository: repoMock{
+		MatchCompaniesFn: matchAll,
+		MergeWebsitesFn: func(companies []Company) ([]error, error) {
+			if writes++; writes == 3 {
+				cancel()
+			}
+			return make([]error, len(companies)), nil
+		},
+	}, config: ImportConfig{BatchSize: 10, ParseWorkers: 4, MatchWorkers: 2}}
+	report, err := s.iterateFileAndCall(ctx, strings.NewReader(file.String()), ImportOptions{}, s.mergeSink())
+	if err != context.Canceled {
+		t.Errorf("companyService.iterateFileAndCall() error = %v, want %v", err, context.Canceled)
+	}
+	if report.Rows != 30 || report.Merged != 30 {
+		t.Errorf("companyService.iterateFileAndCall() report = %+v, want the rows of the batches written", report)
+	}
+}
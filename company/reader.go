@@ -0,0 +1,261 @@
+package company
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/marcospsbrito/dic/apierror"
+)
+
+// Supported import formats
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatXLSX   = "xlsx"
+)
+
+// ErrUnsupportedFormat is returned when no RowReader is registered for a format
+var ErrUnsupportedFormat = apierror.New(http.StatusBadRequest, "upload.unsupported_format", "Unsupported file format")
+
+// ErrJSONNotArray is returned when a json upload holds another value than an
+// array of rows
+var ErrJSONNotArray = apierror.New(http.StatusBadRequest, "upload.invalid_json", "JSON file must be an array")
+
+// RowReader reads the rows of an imported file. Read returns io.EOF when
+// there are no more rows.
+type RowReader interface {
+	Read() ([]string, error)
+}
+
+// lineReader is implemented by RowReaders that know the line of the last
+// row read, which is otherwise counted from the rows themselves
+type lineReader interface {
+	Line() int
+}
+
+// RowReaderFactory returns a RowReader over f
+type RowReaderFactory func(f io.Reader, opts ImportOptions) (RowReader, error)
+
+var (
+	rowReadersMu sync.RWMutex
+	rowReaders   = map[string]RowReaderFactory{
+		FormatCSV:    newCSVRowReader,
+		FormatJSON:   newJSONRowReader,
+		FormatNDJSON: newNDJSONRowReader,
+		FormatXLSX:   newXLSXRowReader,
+	}
+)
+
+var formatsByExtension = map[string]string{
+	".csv":    FormatCSV,
+	".tsv":    FormatCSV,
+	".txt":    FormatCSV,
+	".json":   FormatJSON,
+	".ndjson": FormatNDJSON,
+	".jsonl":  FormatNDJSON,
+	".xlsx":   FormatXLSX,
+}
+
+var formatsByContentType = map[string]string{
+	"text/csv":                  FormatCSV,
+	"text/plain":                FormatCSV,
+	"text/tab-separated-values": FormatCSV,
+	"application/csv":           FormatCSV,
+	"application/json":          FormatJSON,
+	"application/x-ndjson":      FormatNDJSON,
+	"application/jsonl":         FormatNDJSON,
+	"application/x-jsonlines":   FormatNDJSON,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": FormatXLSX,
+}
+
+// RegisterRowReader adds or replaces the RowReader used for format
+func RegisterRowReader(format string, factory RowReaderFactory) {
+	rowReadersMu.Lock()
+	defer rowReadersMu.Unlock()
+	rowReaders[format] = factory
+}
+
+// DetectFormat returns the format of a file from its name, falling back to
+// its content type and then to csv
+func DetectFormat(filename string, contentType string) string {
+	if format, ok := formatByName(filename); ok {
+		return format
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if format, ok := formatsByContentType[mediaType]; ok {
+			return format
+		}
+	}
+	return FormatCSV
+}
+
+// formatByName returns the format of a file from its extension, ignoring
+// compression extensions like .gz
+func formatByName(filename string) (string, bool) {
+	name := strings.ToLower(filename)
+	for _, ext := range compressionExtensions {
+		name = strings.TrimSuffix(name, ext)
+	}
+	format, ok := formatsByExtension[filepath.Ext(name)]
+	return format, ok
+}
+
+func hasRowReader(format string) bool {
+	rowReadersMu.RLock()
+	defer rowReadersMu.RUnlock()
+	_, ok := rowReaders[format]
+	return ok
+}
+
+func newRowReader(f io.Reader, opts ImportOptions) (RowReader, error) {
+	format := opts.Format
+	if format == "" {
+		format = FormatCSV
+	}
+	rowReadersMu.RLock()
+	factory, ok := rowReaders[format]
+	rowReadersMu.RUnlock()
+	if !ok {
+		return nil, ErrUnsupportedFormat
+	}
+	return factory(f, opts)
+}
+
+// csvRowReader reads delimited text files in the dialect sniffed from their
+// first lines
+type csvRowReader struct {
+	*csv.Reader
+}
+
+func newCSVRowReader(f io.Reader, opts ImportOptions) (RowReader, error) {
+	br := bufio.NewReaderSize(f, dialectSniffLen)
+	sample, err := br.Peek(dialectSniffLen)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	d := sniffDialect(string(sample), err == nil)
+	if err := d.applyOverrides(opts); err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(br)
+	reader.Comma = d.Delimiter
+	reader.Comment = d.Comment
+	reader.LazyQuotes = d.LazyQuotes
+	reader.FieldsPerRecord = -1
+	if d.Header {
+		if _, err := reader.Read(); err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+	return csvRowReader{reader}, nil
+}
+
+// Line returns the line where the last row read starts
+func (r csvRowReader) Line() int {
+	line, _ := r.FieldPos(0)
+	return line
+}
+
+// jsonRowReader reads the elements of a JSON array, or a stream of JSON
+// values when ndjson is set
+type jsonRowReader struct {
+	decoder *json.Decoder
+	ndjson  bool
+}
+
+func newJSONRowReader(f io.Reader, opts ImportOptions) (RowReader, error) {
+	decoder := json.NewDecoder(f)
+	decoder.UseNumber()
+	t, err := decoder.Token()
+	if err == io.EOF {
+		return &jsonRowReader{decoder: decoder, ndjson: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t != json.Delim('[') {
+		return nil, ErrJSONNotArray
+	}
+	return &jsonRowReader{decoder: decoder}, nil
+}
+
+func newNDJSONRowReader(f io.Reader, opts ImportOptions) (RowReader, error) {
+	decoder := json.NewDecoder(f)
+	decoder.UseNumber()
+	return &jsonRowReader{decoder: decoder, ndjson: true}, nil
+}
+
+func (r *jsonRowReader) Read() ([]string, error) {
+	if !r.ndjson && !r.decoder.More() {
+		return nil, io.EOF
+	}
+	var v interface{}
+	if err := r.decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return jsonRow(v), nil
+}
+
+// jsonColumns maps the keys of a JSON object to the positional fields
+// expected by the row handlers
+var jsonColumns = []struct {
+	index int
+	keys  []string
+}{
+	{0, []string{"name"}},
+	{1, []string{"zipcode", "zip", "addresszip"}},
+	{2, []string{"website"}},
+}
+
+// jsonRow converts a JSON array or object to row fields. Any other value
+// results in an empty row, which the handlers reject.
+func jsonRow(v interface{}) []string {
+	switch value := v.(type) {
+	case []interface{}:
+		row := make([]string, len(value))
+		for i, field := range value {
+			row[i] = jsonField(field)
+		}
+		return row
+	case map[string]interface{}:
+		fields := make(map[string]interface{}, len(value))
+		for k, field := range value {
+			fields[strings.ToLower(k)] = field
+		}
+		var row []string
+		for _, column := range jsonColumns {
+			for _, key := range column.keys {
+				if field, ok := fields[key]; ok {
+					for len(row) <= column.index {
+						row = append(row, "")
+					}
+					row[column.index] = jsonField(field)
+					break
+				}
+			}
+		}
+		return row
+	}
+	return nil
+}
+
+func jsonField(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	}
+	return fmt.Sprint(v)
+}
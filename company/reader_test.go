@@ -0,0 +1,111 @@
+package company
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readAllRows(r RowReader) ([][]string, error) {
+	var rows [][]string
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	type args struct {
+		filename    string
+		contentType string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Detect by extension", args{"clients.JSON", "text/csv"}, FormatJSON},
+		{"Detect jsonl extension", args{"clients.jsonl", ""}, FormatNDJSON},
+		{"Detect tsv content type", args{"", "text/tab-separated-values"}, FormatCSV},
+		{"Detect by content type", args{"clients", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"}, FormatXLSX},
+		{"Detect content type with params", args{"", "application/x-ndjson; charset=utf-8"}, FormatNDJSON},
+		{"Default to csv", args{"clients.dat", "application/octet-stream"}, FormatCSV},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectFormat(tt.args.filename, tt.args.contentType); got != tt.want {
+				t.Errorf("DetectFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newRowReader(t *testing.T) {
+	type args struct {
+		f    io.Reader
+		opts ImportOptions
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    [][]string
+		wantErr bool
+	}{
+		{"Read csv",
+			args{strings.NewReader("a;12345;http://a.com\n"), ImportOptions{}},
+			[][]string{{"a", "12345", "http://a.com"}}, false},
+		{"Read json array of objects",
+			args{strings.NewReader(`[{"Name": "a", "addressZip": 12345, "website": "http://a.com"}, {"name": "b"}]`), ImportOptions{Format: FormatJSON}},
+			[][]string{{"a", "12345", "http://a.com"}, {"b"}}, false},
+		{"Read json array of arrays",
+			args{strings.NewReader(`[["a", "12345", null]]`), ImportOptions{Format: FormatJSON}},
+			[][]string{{"a", "12345", ""}}, false},
+		{"Read empty json",
+			args{strings.NewReader(""), ImportOptions{Format: FormatJSON}},
+			nil, false},
+		{"Reject json object",
+			args{strings.NewReader(`{"name": "a"}`), ImportOptions{Format: FormatJSON}},
+			nil, true},
+		{"Read ndjson",
+			args{strings.NewReader("{\"name\": \"a\", \"zip\": \"12345\"}\n\"b\"\n"), ImportOptions{Format: FormatNDJSON}},
+			[][]string{{"a", "12345"}, nil}, false},
+		{"Unsupported format",
+			args{strings.NewReader(""), ImportOptions{Format: "xml"}},
+			nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newRowReader(tt.args.f, tt.args.opts)
+			if err == nil {
+				var got [][]string
+				got, err = readAllRows(r)
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("newRowReader() rows = %v, want %v", got, tt.want)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newRowReader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegisterRowReader(t *testing.T) {
+	RegisterRowReader("test", func(f io.Reader, opts ImportOptions) (RowReader, error) {
+		return newCSVRowReader(f, opts)
+	})
+	r, err := newRowReader(strings.NewReader("a,b"), ImportOptions{Format: "test"})
+	if err != nil {
+		t.Fatalf("newRowReader() error = %v", err)
+	}
+	if got, _ := readAllRows(r); !reflect.DeepEqual(got, [][]string{{"a", "b"}}) {
+		t.Errorf("registered reader rows = %v", got)
+	}
+}
@@ -1,77 +1,533 @@
 package company
 
 import (
+	"context"
+	"time"
+
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
+
+	"github.com/marcospsbrito/dic/auth"
+	"github.com/marcospsbrito/dic/database"
 )
 
 // Company entity
 type Company struct {
 	ID      bson.ObjectId `bson:"_id,omitempty" json:"id,omitempty" example:"12345"`
+	Tenant  string        `json:"-"`
 	Name    string        `json:"name" example:"Company Name"`
-	Zipcode int64         `json:"Zipcode,omitempty" example:"123"`
+	Zipcode Zipcode       `json:"Zipcode,omitempty" example:"123"`
 	Website string        `json:"website,omitempty" example:"1" example:"http://localhost"`
+	// Catalog is the base name of the catalog file the company was loaded
+	// from, empty for companies added otherwise
+	Catalog string `bson:"catalog,omitempty" json:"-"`
 }
 
-// Repository interface difines necessary methods
+// Repository interface difines necessary methods. Every method only sees
+// the records of the tenant the Repository is scoped to, and fails with the
+// error of its context once the context is done.
 type Repository interface {
-	FindAll() ([]Company, error)
-	FindByNameAndZip(string, int64) (Company, error)
-	Add(Company) error
-	MergeWebsite(Company) (*mgo.ChangeInfo, error)
+	ForTenant(tenant string) Repository
+	CopyCatalog(ctx context.Context, from string) (int, error)
+	FindAll(ctx context.Context) ([]Company, error)
+	FindByNameAndZip(ctx context.Context, name string, zipcode Zipcode) (Company, error)
+	Add(ctx context.Context, c Company) error
+	FindCatalogCompanies(ctx context.Context, file string, companies []Company) ([]Company, error)
+	UpsertCompanies(ctx context.Context, companies []Company) error
+	RemoveCompanies(ctx context.Context, ids []bson.ObjectId) error
+	FindCatalogVersion(ctx context.Context, file string) (CatalogVersion, error)
+	SaveCatalogVersion(ctx context.Context, v CatalogVersion) error
+	MatchCompanies(ctx context.Context, companies []Company) ([]Company, error)
+	MergeWebsites(ctx context.Context, companies []Company) ([]error, error)
+	FindUpload(ctx context.Context, hash string, key string) (Upload, error)
+	ClaimUpload(ctx context.Context, u Upload, previous string) error
+	SaveUpload(ctx context.Context, u Upload) error
+	StageWebsites(ctx context.Context, importID bson.ObjectId, companies []Company) ([]error, error)
+	CommitStaged(ctx context.Context, importID bson.ObjectId) error
+	DiscardStaged(ctx context.Context, importID bson.ObjectId) error
+	RecoverStaged(ctx context.Context, before time.Time) (int, error)
 }
 
+// stagedWebsite is a website change waiting for its import to be committed
+type stagedWebsite struct {
+	ID       bson.ObjectId `bson:"_id,omitempty"`
+	Tenant   string        `bson:"tenant"`
+	Import   bson.ObjectId `bson:"import"`
+	Company  bson.ObjectId `bson:"company"`
+	Website  string        `bson:"website"`
+	Previous string        `bson:"previous"`
+	// Committing is when the commit of the import started, zero before
+	Committing time.Time `bson:"committing,omitempty"`
+}
+
+// nameCollation compares names by their base letters, ignoring case and accents
+var nameCollation = &mgo.Collation{Locale: "en", Strength: 1}
+
 type companyRepository struct {
+	tenant    string
 	companies *mgo.Collection
+	uploads   *mgo.Collection
+	staging   *mgo.Collection
+	catalogs  *mgo.Collection
 }
 
-// NewRepository function returns a Repository impl
+// NewRepository function returns a Repository impl scoped to the default
+// tenant. The collections are set up by Migrations.
 func NewRepository(db *mgo.Database) Repository {
 	if db == nil {
 		return nil
 	}
-	db.C("Company").EnsureIndexKey("$text:name")
-	return companyRepository{db.C("Company")}
+	return companyRepository{auth.DefaultTenant, db.C("Company"), db.C("Upload"), db.C("Staging"), db.C("Catalog")}
+}
+
+// ForTenant returns a Repository sharing the collections of r scoped to tenant
+func (r companyRepository) ForTenant(tenant string) Repository {
+	r.tenant = tenant
+	return r
+}
+
+// run runs op on the collections of r bound to a session that honours the
+// deadline of ctx
+func (r companyRepository) run(ctx context.Context, op func(r companyRepository) error) error {
+	return database.Run(ctx, r.companies.Database.Session, func(s *mgo.Session) error {
+		return op(r.with(s))
+	})
+}
+
+// read runs op like run, retrying it while the database is unavailable. op
+// must only read.
+func (r companyRepository) read(ctx context.Context, op func(r companyRepository) error) error {
+	return database.Retry(ctx, r.companies.Database.Session, func(s *mgo.Session) error {
+		return op(r.with(s))
+	})
+}
+
+// with returns r with its collections bound to s
+func (r companyRepository) with(s *mgo.Session) companyRepository {
+	r.companies, r.uploads, r.staging = r.companies.With(s), r.uploads.With(s), r.staging.With(s)
+	r.catalogs = r.catalogs.With(s)
+	return r
+}
+
+// scoped restricts query to the records of the tenant of r
+func (r companyRepository) scoped(query bson.M) bson.M {
+	if query == nil {
+		return bson.M{"tenant": r.tenant}
+	}
+	return bson.M{"$and": []bson.M{{"tenant": r.tenant}, query}}
 }
 
-func (r companyRepository) FindAll() ([]Company, error) {
+func (r companyRepository) FindAll(ctx context.Context) ([]Company, error) {
 	var results []Company
-	err := r.companies.Find(nil).All(&results)
+	err := r.read(ctx, func(r companyRepository) error {
+		return r.companies.Find(r.scoped(nil)).All(&results)
+	})
 	return results, err
 }
 
-func (r companyRepository) FindByNameAndZip(name string, zipcode int64) (Company, error) {
+func (r companyRepository) FindByNameAndZip(ctx context.Context, name string, zipcode Zipcode) (Company, error) {
 	var result Company
-	query := getCompanyNameAndZipQuery(name, zipcode)
-	err := r.companies.Find(query).One(&result)
+	query := r.scoped(getCompanyNameAndZipQuery(name, zipcode))
+	err := r.read(ctx, func(r companyRepository) error {
+		return r.companies.Find(query).One(&result)
+	})
 	return result, err
 }
 
-func (r companyRepository) Add(c Company) error {
-	count, err := r.companies.Find(getCompanyNameAndZipQuery(c.Name, c.Zipcode)).Count()
-	if err != nil || count > 0 {
+func (r companyRepository) Add(ctx context.Context, c Company) error {
+	return r.run(ctx, func(r companyRepository) error {
+		count, err := r.companies.Find(r.scoped(getCompanyNameAndZipQuery(c.Name, c.Zipcode))).Count()
+		if err != nil || count > 0 {
+			return err
+		}
+		c.Tenant = r.tenant
+		return r.companies.Insert(c)
+	})
+}
+
+// catalogKeyBatch is the number of companies FindCatalogCompanies looks up
+// in a single query
+const catalogKeyBatch = 1000
+
+// FindCatalogCompanies returns the companies loaded from the catalog file and
+// the ones with the exact name and zipcode of one of companies, looked up by
+// batches of catalogKeyBatch
+func (r companyRepository) FindCatalogCompanies(ctx context.Context, file string, companies []Company) ([]Company, error) {
+	var results []Company
+	err := r.read(ctx, func(r companyRepository) error {
+		if err := r.companies.Find(r.scoped(bson.M{"catalog": file})).All(&results); err != nil {
+			return err
+		}
+		for start := 0; start < len(companies); start += catalogKeyBatch {
+			end := start + catalogKeyBatch
+			if end > len(companies) {
+				end = len(companies)
+			}
+			keys := make([]bson.M, 0, end-start)
+			for _, c := range companies[start:end] {
+				keys = append(keys, bson.M{"name": c.Name, "zipcode": c.Zipcode})
+			}
+			var found []Company
+			query := r.scoped(bson.M{"$or": keys, "catalog": bson.M{"$ne": file}})
+			if err := r.companies.Find(query).All(&found); err != nil {
+				return err
+			}
+			results = append(results, found...)
+		}
+		return nil
+	})
+	return results, err
+}
+
+// UpsertCompanies inserts the companies missing from the tenant of r and
+// sets the catalog of the existing ones, matched by exact name and zipcode,
+// in one unordered bulk write
+func (r companyRepository) UpsertCompanies(ctx context.Context, companies []Company) error {
+	if len(companies) == 0 {
+		return nil
+	}
+	return r.run(ctx, func(r companyRepository) error {
+		bulk := r.companies.Bulk()
+		bulk.Unordered()
+		for _, c := range companies {
+			bulk.Upsert(
+				bson.M{"tenant": r.tenant, "name": c.Name, "zipcode": c.Zipcode},
+				bson.M{"$set": bson.M{"catalog": c.Catalog}},
+			)
+		}
+		_, err := bulk.Run()
 		return err
+	})
+}
+
+// RemoveCompanies removes the companies of the tenant of r with ids
+func (r companyRepository) RemoveCompanies(ctx context.Context, ids []bson.ObjectId) error {
+	if len(ids) == 0 {
+		return nil
 	}
-	return r.companies.Insert(c)
+	return r.run(ctx, func(r companyRepository) error {
+		_, err := r.companies.RemoveAll(r.scoped(bson.M{"_id": bson.M{"$in": ids}}))
+		return err
+	})
 }
 
-func (r companyRepository) MergeWebsite(c Company) (*mgo.ChangeInfo, error) {
-	query := getCompanyNameOrZipQuery(c.Name, c.Zipcode)
-	change := mgo.Change{
-		Update:    bson.M{"$set": bson.M{"website": c.Website}},
-		ReturnNew: true,
+// FindCatalogVersion returns the version of the catalog file last loaded
+func (r companyRepository) FindCatalogVersion(ctx context.Context, file string) (CatalogVersion, error) {
+	var result CatalogVersion
+	err := r.read(ctx, func(r companyRepository) error {
+		return r.catalogs.Find(r.scoped(bson.M{"file": file})).One(&result)
+	})
+	return result, err
+}
+
+// SaveCatalogVersion inserts or replaces the version of a catalog file
+func (r companyRepository) SaveCatalogVersion(ctx context.Context, v CatalogVersion) error {
+	v.Tenant = r.tenant
+	return r.run(ctx, func(r companyRepository) error {
+		_, err := r.catalogs.Upsert(bson.M{"tenant": v.Tenant, "file": v.File}, v)
+		return err
+	})
+}
+
+// CopyCatalog adds the companies of the tenant from that are not in the
+// tenant of r yet, returning how many were added. The copies belong to no
+// catalog of the tenant of r until one of its catalog loads claims them. It
+// stops between two companies once ctx is done.
+func (r companyRepository) CopyCatalog(ctx context.Context, from string) (int, error) {
+	copied := 0
+	err := r.run(ctx, func(r companyRepository) error {
+		var c Company
+		iter := r.companies.Find(bson.M{"tenant": from}).Iter()
+		for iter.Next(&c) {
+			if err := ctx.Err(); err != nil {
+				iter.Close()
+				return err
+			}
+			count, err := r.companies.Find(r.scoped(bson.M{"name": c.Name, "zipcode": c.Zipcode})).Count()
+			if err != nil {
+				iter.Close()
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			c.ID, c.Tenant, c.Catalog = bson.NewObjectId(), r.tenant, ""
+			if err := r.companies.Insert(c); err != nil {
+				iter.Close()
+				return err
+			}
+			copied++
+		}
+		return iter.Close()
+	})
+	return copied, err
+}
+
+// MatchCompanies returns the company matching each of companies, by name or
+// zipcode, found in one query. Companies matching none get a Company
+// without ID.
+func (r companyRepository) MatchCompanies(ctx context.Context, companies []Company) ([]Company, error) {
+	names := make([]string, len(companies))
+	zipcodes := make([]Zipcode, len(companies))
+	for i, c := range companies {
+		names[i], zipcodes[i] = c.Name, c.Zipcode
+	}
+	var found []Company
+	query := r.scoped(bson.M{"$or": []bson.M{
+		{"name": bson.M{"$in": names}},
+		{"zipcode": bson.M{"$in": zipcodes}}}})
+	err := r.read(ctx, func(r companyRepository) error {
+		return r.companies.Find(query).Collation(nameCollation).All(&found)
+	})
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]Company, len(companies))
+	for i, c := range companies {
+		if j := firstNameOrZip(found, c.Name, c.Zipcode); j >= 0 {
+			matches[i] = found[j]
+		}
 	}
-	return r.companies.Find(query).Apply(change, &c)
+	return matches, nil
 }
 
-func getCompanyNameAndZipQuery(name string, zipcode int64) bson.M {
+// MergeWebsites sets the website of each of companies, identified by their
+// ID, in one unordered bulk write. When several have the same ID the last
+// one wins, as if they were merged in order.
+func (r companyRepository) MergeWebsites(ctx context.Context, companies []Company) ([]error, error) {
+	outcomes := make([]error, len(companies))
+	last := make(map[bson.ObjectId]int, len(companies))
+	for i, c := range companies {
+		last[c.ID] = i
+	}
+	var indexes []int
+	for i, c := range companies {
+		if last[c.ID] == i {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) == 0 {
+		return outcomes, nil
+	}
+	err := r.run(ctx, func(r companyRepository) error {
+		bulk := r.companies.Bulk()
+		bulk.Unordered()
+		for _, i := range indexes {
+			bulk.Update(r.scoped(bson.M{"_id": companies[i].ID}), bson.M{"$set": bson.M{"website": companies[i].Website}})
+		}
+		_, err := bulk.Run()
+		return bulkOutcomes(err, indexes, outcomes)
+	})
+	return outcomes, err
+}
+
+// FindUpload returns the upload with the given hash or idempotency key
+func (r companyRepository) FindUpload(ctx context.Context, hash string, key string) (Upload, error) {
+	var result Upload
+	query := bson.M{"hash": hash}
+	if key != "" {
+		query = bson.M{"$or": []bson.M{query, {"idempotencyKey": key}}}
+	}
+	err := r.read(ctx, func(r companyRepository) error {
+		return r.uploads.Find(r.scoped(query)).One(&result)
+	})
+	return result, err
+}
+
+// ClaimUpload stores u for the import processing it: u is inserted when
+// previous is empty, or replaces the upload while its status is still
+// previous. Another import claiming the upload first makes it fail with a
+// duplicate key error or mgo.ErrNotFound.
+func (r companyRepository) ClaimUpload(ctx context.Context, u Upload, previous string) error {
+	u.Tenant = r.tenant
+	return r.run(ctx, func(r companyRepository) error {
+		if previous == "" {
+			return r.uploads.Insert(u)
+		}
+		return r.uploads.Update(r.scoped(bson.M{"_id": u.ID, "status": previous}), u)
+	})
+}
+
+// SaveUpload inserts or replaces an upload record
+func (r companyRepository) SaveUpload(ctx context.Context, u Upload) error {
+	u.Tenant = r.tenant
+	return r.run(ctx, func(r companyRepository) error {
+		_, err := r.uploads.UpsertId(u.ID, u)
+		return err
+	})
+}
+
+// StageWebsites records the website change of each of companies, identified
+// by their ID, without applying it, in one unordered bulk write. The outcome
+// of a company is mgo.ErrNotFound when its ID is unknown.
+func (r companyRepository) StageWebsites(ctx context.Context, importID bson.ObjectId, companies []Company) ([]error, error) {
+	outcomes := make([]error, len(companies))
+	if len(companies) == 0 {
+		return outcomes, nil
+	}
+	ids := make([]bson.ObjectId, len(companies))
+	for i, c := range companies {
+		ids[i] = c.ID
+	}
+	err := r.run(ctx, func(r companyRepository) error {
+		var current []Company
+		if err := r.companies.Find(r.scoped(bson.M{"_id": bson.M{"$in": ids}})).All(&current); err != nil {
+			return err
+		}
+		previous := make(map[bson.ObjectId]string, len(current))
+		for _, c := range current {
+			previous[c.ID] = c.Website
+		}
+		bulk := r.staging.Bulk()
+		bulk.Unordered()
+		var indexes []int
+		for i, c := range companies {
+			website, ok := previous[c.ID]
+			if !ok {
+				outcomes[i] = mgo.ErrNotFound
+				continue
+			}
+			bulk.Insert(stagedWebsite{
+				Tenant:   r.tenant,
+				Import:   importID,
+				Company:  c.ID,
+				Website:  c.Website,
+				Previous: website,
+			})
+			indexes = append(indexes, i)
+		}
+		if len(indexes) == 0 {
+			return nil
+		}
+		_, err := bulk.Run()
+		return bulkOutcomes(err, indexes, outcomes)
+	})
+	return outcomes, err
+}
+
+// bulkOutcomes sets the outcome of each operation of a bulk write that err
+// reports as failed, the operations being the ones of the companies at
+// indexes. It returns err when the bulk write failed as a whole.
+func bulkOutcomes(err error, indexes []int, outcomes []error) error {
+	berr, ok := err.(*mgo.BulkError)
+	if !ok {
+		return err
+	}
+	for _, c := range berr.Cases() {
+		if c.Index < 0 || c.Index >= len(indexes) {
+			return err
+		}
+		outcomes[indexes[c.Index]] = c.Err
+	}
+	return nil
+}
+
+// CommitStaged applies every staged change of the import. The companies
+// cannot be updated atomically, so readers may see the import partially
+// applied until it returns. The staged changes are marked as committing first
+// and only removed once applied: when the commit fails DiscardStaged restores
+// the companies it changed, and when its process dies RecoverStaged does.
+func (r companyRepository) CommitStaged(ctx context.Context, importID bson.ObjectId) error {
+	return r.run(ctx, func(r companyRepository) error {
+		query := r.scoped(bson.M{"import": importID})
+		if _, err := r.staging.UpdateAll(query, bson.M{"$set": bson.M{"committing": time.Now()}}); err != nil {
+			return err
+		}
+		var staged []stagedWebsite
+		if err := r.staging.Find(query).All(&staged); err != nil {
+			return err
+		}
+		if len(staged) > 0 {
+			bulk := r.companies.Bulk()
+			bulk.Unordered()
+			for _, w := range staged {
+				bulk.Update(r.scoped(bson.M{"_id": w.Company}), bson.M{"$set": bson.M{"website": w.Website}})
+			}
+			if _, err := bulk.Run(); err != nil {
+				return err
+			}
+		}
+		_, err := r.staging.RemoveAll(query)
+		return err
+	})
+}
+
+// DiscardStaged removes the staged changes of the import. The companies a
+// failed commit of the import changed get their previous website back,
+// unless their website changed since.
+func (r companyRepository) DiscardStaged(ctx context.Context, importID bson.ObjectId) error {
+	return r.run(ctx, func(r companyRepository) error {
+		return r.discard(importID)
+	})
+}
+
+func (r companyRepository) discard(importID bson.ObjectId) error {
+	var committed []stagedWebsite
+	if err := r.staging.Find(r.scoped(bson.M{"import": importID, "committing": bson.M{"$exists": true}})).All(&committed); err != nil {
+		return err
+	}
+	if len(committed) > 0 {
+		bulk := r.companies.Bulk()
+		bulk.Unordered()
+		for _, w := range committed {
+			bulk.Update(r.scoped(bson.M{"_id": w.Company, "website": w.Website}), bson.M{"$set": bson.M{"website": w.Previous}})
+		}
+		if _, err := bulk.Run(); err != nil {
+			return err
+		}
+	}
+	_, err := r.staging.RemoveAll(r.scoped(bson.M{"import": importID}))
+	return err
+}
+
+// RecoverStaged discards, like DiscardStaged, the imports of every tenant
+// whose commit started before before and never finished because its process
+// died. Their uploads are marked interrupted so that they can be sent again.
+// It returns the number of imports discarded.
+func (r companyRepository) RecoverStaged(ctx context.Context, before time.Time) (int, error) {
+	var interrupted []struct {
+		ID struct {
+			Tenant string        `bson:"tenant"`
+			Import bson.ObjectId `bson:"import"`
+		} `bson:"_id"`
+	}
+	err := r.run(ctx, func(r companyRepository) error {
+		err := r.staging.Pipe([]bson.M{
+			{"$match": bson.M{"committing": bson.M{"$lt": before}}},
+			{"$group": bson.M{"_id": bson.M{"tenant": "$tenant", "import": "$import"}}},
+		}).All(&interrupted)
+		if err != nil {
+			return err
+		}
+		for _, i := range interrupted {
+			r.tenant = i.ID.Tenant
+			if err := r.discard(i.ID.Import); err != nil {
+				return err
+			}
+			err := r.uploads.Update(r.scoped(bson.M{"_id": i.ID.Import, "status": UploadProcessing}), bson.M{"$set": bson.M{"status": UploadInterrupted}})
+			if err != nil && err != mgo.ErrNotFound {
+				return err
+			}
+		}
+		return nil
+	})
+	return len(interrupted), err
+}
+
+func getCompanyNameAndZipQuery(name string, zipcode Zipcode) bson.M {
 	return bson.M{"$and": []bson.M{
-		{"$text": bson.M{"$search": name}},
+		{"$text": bson.M{"$search": foldKey(name)}},
 		{"zipcode": zipcode}}}
 }
 
-func getCompanyNameOrZipQuery(name string, zipcode int64) bson.M {
-	return bson.M{"$or": []bson.M{
-		{"name": name},
-		{"zipcode": zipcode}}}
+// firstNameOrZip returns the index of the first of companies with the name,
+// compared by matching keys, or the zipcode, -1 when there is none
+func firstNameOrZip(companies []Company, name string, zipcode Zipcode) int {
+	key := foldKey(name)
+	for i, c := range companies {
+		if foldKey(c.Name) == key || c.Zipcode == zipcode {
+			return i
+		}
+	}
+	return -1
 }
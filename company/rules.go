@@ -0,0 +1,160 @@
+package company
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Import sources
+const (
+	SourceCatalog  = "catalog"
+	SourceWebsites = "websites"
+	// SourceAll holds the rules applied to every source
+	SourceAll = "*"
+)
+
+// Built in rule IDs reported when a row cannot be parsed
+const (
+	RuleMissingFields  = "fields.missing"
+	RuleInvalidZipcode = "zipcode.invalid"
+)
+
+// ruleFields maps the field names used in rules to their column in a row
+var ruleFields = map[string]int{
+	"name":    0,
+	"zipcode": 1,
+	"website": 2,
+}
+
+// Rule is a declarative validation of a field of the imported rows
+type Rule struct {
+	ID        string   `yaml:"id"`
+	Field     string   `yaml:"field"`
+	Message   string   `yaml:"message"`
+	Required  bool     `yaml:"required"`
+	Pattern   string   `yaml:"pattern"`
+	MinLength int      `yaml:"minLength"`
+	MaxLength int      `yaml:"maxLength"`
+	Allowed   []string `yaml:"allowed"`
+	HTTPS     bool     `yaml:"https"`
+	Blacklist []string `yaml:"blacklist"`
+
+	column  int
+	pattern *regexp.Regexp
+}
+
+// Rules holds the rules of each import source
+type Rules map[string][]Rule
+
+// RuleViolation is the rejection of a row by a rule
+type RuleViolation struct {
+	Rule    string
+	Message string
+}
+
+func (v RuleViolation) Error() string {
+	return v.Message
+}
+
+// LoadRules reads the rules of each source from a YAML file like
+//
+//	sources:
+//	  websites:
+//	    - id: website.https
+//	      field: website
+//	      https: true
+func LoadRules(file string) (Rules, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Sources Rules `yaml:"sources"`
+	}
+	if err := yaml.UnmarshalStrict(b, &doc); err != nil {
+		return nil, err
+	}
+	for source, rules := range doc.Sources {
+		ids := make(map[string]bool, len(rules))
+		for i := range rules {
+			r := &rules[i]
+			if r.ID == "" || ids[r.ID] {
+				return nil, fmt.Errorf("Rule %d of source %s must have an unique id", i+1, source)
+			}
+			ids[r.ID] = true
+			column, ok := ruleFields[r.Field]
+			if !ok {
+				return nil, fmt.Errorf("Rule %s has unknown field %q", r.ID, r.Field)
+			}
+			r.column = column
+			if r.Pattern != "" {
+				if r.pattern, err = regexp.Compile(r.Pattern); err != nil {
+					return nil, fmt.Errorf("Rule %s: %v", r.ID, err)
+				}
+			}
+		}
+	}
+	return doc.Sources, nil
+}
+
+// check returns the violation of the first rule of source, or of every
+// source, that rejects row
+func (rs Rules) check(source string, row []string) error {
+	for _, rules := range [][]Rule{rs[SourceAll], rs[source]} {
+		for _, r := range rules {
+			if message, ok := r.check(row); !ok {
+				if r.Message != "" {
+					message = r.Message
+				}
+				return RuleViolation{Rule: r.ID, Message: message}
+			}
+		}
+	}
+	return nil
+}
+
+func (r Rule) check(row []string) (string, bool) {
+	var value string
+	if r.column < len(row) {
+		value = strings.TrimSpace(row[r.column])
+	}
+	if value == "" {
+		return fmt.Sprintf("%s is required", r.Field), !r.Required
+	}
+	length := len([]rune(value))
+	switch {
+	case r.MinLength > 0 && length < r.MinLength:
+		return fmt.Sprintf("%s must have at least %d characters", r.Field, r.MinLength), false
+	case r.MaxLength > 0 && length > r.MaxLength:
+		return fmt.Sprintf("%s must have at most %d characters", r.Field, r.MaxLength), false
+	case r.pattern != nil && !r.pattern.MatchString(value):
+		return fmt.Sprintf("%s must match %s", r.Field, r.Pattern), false
+	case len(r.Allowed) > 0 && !containsFold(r.Allowed, value):
+		return fmt.Sprintf("%s must be one of %s", r.Field, strings.Join(r.Allowed, ", ")), false
+	case r.HTTPS && !isHTTPS(value):
+		return fmt.Sprintf("%s must be an https URL", r.Field), false
+	case len(r.Blacklist) > 0 && containsFold(r.Blacklist, value):
+		return fmt.Sprintf("%s %q is not allowed", r.Field, value), false
+	}
+	return "", true
+}
+
+func containsFold(values []string, value string) bool {
+	key := foldKey(value)
+	for _, v := range values {
+		if foldKey(v) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func isHTTPS(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
@@ -0,0 +1,104 @@
+package company
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/globalsign/mgo"
+)
+
+func writeRulesFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	file := filepath.Join(dir, "rules.yaml")
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"Load rules", "sources:\n  websites:\n    - {id: a, field: website, https: true}\n", false},
+		{"Load repository rules", "", false},
+		{"Missing id", "sources:\n  websites:\n    - {field: website}\n", true},
+		{"Duplicated id", "sources:\n  websites:\n    - {id: a, field: name}\n    - {id: a, field: name}\n", true},
+		{"Unknown field", "sources:\n  websites:\n    - {id: a, field: phone}\n", true},
+		{"Unknown check", "sources:\n  websites:\n    - {id: a, field: name, unique: true}\n", true},
+		{"Invalid pattern", "sources:\n  websites:\n    - {id: a, field: name, pattern: '('}\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := "../resource/rules.yaml"
+			if tt.content != "" {
+				file = writeRulesFile(t, tt.content)
+			}
+			if _, err := LoadRules(file); (err != nil) != tt.wantErr {
+				t.Errorf("LoadRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRules_check(t *testing.T) {
+	rules, err := LoadRules(writeRulesFile(t, `sources:
+  "*":
+    - {id: name.required, field: name, required: true}
+  websites:
+    - {id: name.length, field: name, minLength: 2, maxLength: 10}
+    - {id: zipcode.format, field: zipcode, pattern: '^[0-9]{5}$', message: Zipcode must have 5 digits}
+    - {id: website.https, field: website, https: true}
+    - {id: name.blacklist, field: name, blacklist: [Test]}
+    - {id: name.allowed, field: name, allowed: [acme, tola, tést]}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		source string
+		row    []string
+		want   string
+	}{
+		{"Valid row", SourceWebsites, []string{"tola", "78229", "https://tola.com"}, ""},
+		{"Optional website", SourceWebsites, []string{"tola", "78229"}, ""},
+		{"Required on every source", SourceCatalog, []string{" ", "78229"}, "name.required"},
+		{"Source rules not applied to other sources", SourceCatalog, []string{"x", "1"}, ""},
+		{"Too short", SourceWebsites, []string{"a", "78229"}, "name.length"},
+		{"Too long", SourceWebsites, []string{"a long company name", "78229"}, "name.length"},
+		{"Pattern", SourceWebsites, []string{"tola", "7822"}, "zipcode.format"},
+		{"Not https", SourceWebsites, []string{"tola", "78229", "http://tola.com"}, "website.https"},
+		{"Blacklisted ignoring accents", SourceWebsites, []string{"tést", "78229"}, "name.blacklist"},
+		{"Not allowed", SourceWebsites, []string{"directv", "78229"}, "name.allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rules.check(tt.source, tt.row)
+			var got string
+			if v, ok := err.(RuleViolation); ok {
+				got = v.Rule
+			}
+			if got != tt.want {
+				t.Errorf("Rules.check() = %v, want rule %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReport_reject(t *testing.T) {
+	var r Report
+	r.reject(2, RuleViolation{"zipcode.format", "Zipcode must have 5 digits"})
+	r.reject(3, mgo.ErrNotFound)
+	if r.Rejected[0].Rule != "zipcode.format" || r.Rejected[0].Reason != "Zipcode must have 5 digits" || r.Rejected[1].Rule != "" {
+		t.Errorf("Report.reject() = %+v", r.Rejected)
+	}
+}
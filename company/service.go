@@ -1,121 +1,373 @@
 package company
 
 import (
-	"encoding/csv"
-	"errors"
-	"fmt"
+	"context"
 	"io"
-	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/apex/log"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/marcospsbrito/dic/metrics"
+	"github.com/marcospsbrito/dic/tracing"
 )
 
-// Service interface define methods of service
+// Service interface define methods of service. Operations stop with the
+// error of their context once it is done or its deadline passes.
 type Service interface {
-	findAll() ([]Company, error)
-	findByNameAndZipCode(string, string) (Company, error)
-	add(Company) error
-	InitDatabase(string) error
-	loadWebsites(f io.Reader) error
+	ForTenant(tenant string) Service
+	findAll(ctx context.Context) ([]Company, error)
+	findByNameAndZipCode(ctx context.Context, name string, zipcode string) (Company, error)
+	add(ctx context.Context, c Company) error
+	InitDatabase(ctx context.Context, file string) error
+	RecoverImports(ctx context.Context) error
+	loadCatalog(ctx context.Context, file string) (Report, error)
+	loadWebsites(ctx context.Context, f io.ReadSeeker, opts ImportOptions) (Upload, bool, error)
+	copyCatalog(ctx context.Context, from string) (int, error)
+	catalogStatus() (CatalogStatus, error)
+	importStatus() ImportStatus
+	drain(ctx context.Context) error
 }
 
-type csvLineHandler func([]string)
-
 // companyService struct
 type companyService struct {
 	repository Repository
+	config     ImportConfig
+	catalog    *catalogLoad
+	workers    *workerPool
+	jobs       *jobTracker
+	timeouts   Timeouts
 }
 
-// NewService returns new Service
-func NewService(r Repository) Service {
-	return companyService{r}
+// NewService returns new Service whose operations are bounded by t
+func NewService(r Repository, ic ImportConfig, t Timeouts) Service {
+	return companyService{repository: r, config: ic, catalog: &catalogLoad{}, workers: newWorkerPool(ic.Workers), jobs: &jobTracker{}, timeouts: t}
 }
 
-func (s companyService) findAll() ([]Company, error) {
-	return s.repository.FindAll()
+// ForTenant returns a Service of the companies of tenant
+func (s companyService) ForTenant(tenant string) Service {
+	s.repository = s.repository.ForTenant(tenant)
+	return s
 }
 
-func (s companyService) add(c Company) error {
-	return s.repository.Add(c)
+// startSpan starts the span of an operation of the service, child of the
+// span of ctx
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "companyService."+name, attrs...)
 }
 
-func isSemicolonSeparated(t string) bool {
-	result := strings.Contains(t, ";") && !strings.Contains(t, ",")
-	if result {
-		log.Debug("Separated by semicolon")
-	} else {
-		log.Debug("Separated by comma")
-	}
-	return result
+// detach returns a context carrying the span of ctx but neither its
+// cancellation nor its deadline, for the writes that must happen even once
+// ctx is done
+func detach(ctx context.Context) context.Context {
+	return trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx))
+}
+
+func (s companyService) findAll(ctx context.Context) (companies []Company, err error) {
+	ctx, cancel := WithTimeout(ctx, s.timeouts.Export)
+	defer cancel()
+	ctx, span := startSpan(ctx, "findAll")
+	defer func() { tracing.End(span, err) }()
+	return s.repository.FindAll(ctx)
+}
+
+func (s companyService) add(ctx context.Context, c Company) error {
+	return s.repository.Add(ctx, c)
 }
 
-func (s companyService) loadWebsites(f io.Reader) error {
+// loadWebsites merges the file into the companies unless the same content or
+// idempotency key was already processed, in which case the original upload
+// is returned and replayed is true. The outcome of an import is saved even
+// when ctx ends: an interrupted upload records the rows it imported, which
+// its retry skips.
+func (s companyService) loadWebsites(ctx context.Context, f io.ReadSeeker, opts ImportOptions) (u Upload, replayed bool, err error) {
 	log.Debug("calls [loadWebsites] service")
-	return s.iterateFileAndCall(f, s.mergeDataByArray)
+	ctx, cancel := WithTimeout(ctx, s.timeouts.Import)
+	defer cancel()
+	ctx, span := startSpan(ctx, "loadWebsites", attribute.String("import.source", opts.Source), attribute.String("import.format", opts.Format), attribute.Bool("import.atomic", opts.Atomic))
+	defer func() {
+		span.SetAttributes(attribute.String("upload.id", u.ID.Hex()), attribute.Bool("upload.replayed", replayed))
+		tracing.End(span, err)
+	}()
+	hash, err := hashContent(f)
+	if err != nil {
+		return u, false, err
+	}
+	if u, replayed, err = s.findUpload(ctx, hash, opts); replayed || err != nil {
+		return u, replayed, err
+	}
+
+	previous := u.Status
+	if u.Status == UploadInterrupted {
+		log.WithFields(log.Fields{"upload": u.ID.Hex(), "checkpoint": u.Checkpoint}).Info("Resuming upload")
+		opts.skip = u.Checkpoint
+	}
+	u.Status, u.Checkpoint = UploadProcessing, 0
+	s.jobs.start()
+	defer s.jobs.done()
+	if err = s.repository.ClaimUpload(ctx, u, previous); err != nil {
+		if !mgo.IsDup(err) && err != mgo.ErrNotFound {
+			return u, false, err
+		}
+		// an identical upload claimed it first
+		if u, replayed, err = s.findUpload(ctx, hash, opts); replayed || err != nil {
+			return u, replayed, err
+		}
+		return u, false, ErrUploadInProgress
+	}
+	if err = s.workers.acquire(ctx); err == nil {
+		defer s.workers.release()
+		log.WithFields(log.Fields{"upload": u.ID.Hex(), "caller": opts.Caller, "source": opts.Source}).Info("Importing upload")
+		if opts.Atomic {
+			u.Report, err = s.importAtomically(ctx, u.ID, f, opts)
+		} else {
+			u.Report, err = s.importFile(ctx, f, opts, s.mergeSink())
+		}
+	}
+	switch {
+	case err == nil:
+		u.Status = UploadDone
+	case ctx.Err() != nil:
+		u.Status = UploadInterrupted
+		if !opts.Atomic {
+			u.Checkpoint = u.Report.Rows
+		}
+	default:
+		u.Status = UploadFailed
+	}
+	if serr := s.repository.SaveUpload(detach(ctx), u); serr != nil {
+		log.WithError(serr).Error("Cannot save upload")
+	}
+	s.observeImport(opts.Source, u.Status, u.Report)
+	return u, false, err
+}
+
+// findUpload returns the upload of the file of hash, a new one when it was
+// never uploaded. A processed upload is returned with replayed set, while
+// one being processed fails with ErrUploadInProgress.
+func (s companyService) findUpload(ctx context.Context, hash string, opts ImportOptions) (Upload, bool, error) {
+	u, err := s.repository.FindUpload(ctx, hash, opts.IdempotencyKey)
+	switch {
+	case err == mgo.ErrNotFound:
+		return Upload{ID: bson.NewObjectId(), Hash: hash, IdempotencyKey: opts.IdempotencyKey, CreatedBy: opts.Caller, CreatedAt: time.Now()}, false, nil
+	case err != nil:
+		return u, false, err
+	case u.Hash != hash:
+		return u, false, ErrIdempotencyKeyReused
+	case u.Status == UploadProcessing:
+		return u, false, ErrUploadInProgress
+	case u.Status == UploadDone:
+		log.WithField("hash", hash).Info("Upload already processed")
+		metrics.Imports.WithLabelValues(s.sourceLabel(opts.Source), "replayed").Inc()
+		return u, true, nil
+	}
+	return u, false, nil
+}
+
+// copyCatalog adds the companies of the tenant from that are missing
+func (s companyService) copyCatalog(ctx context.Context, from string) (copied int, err error) {
+	ctx, cancel := WithTimeout(ctx, s.timeouts.Copy)
+	defer cancel()
+	ctx, span := startSpan(ctx, "copyCatalog", attribute.String("tenant.from", from))
+	defer func() { tracing.End(span, err) }()
+	copied, err = s.repository.CopyCatalog(ctx, from)
+	log.WithFields(log.Fields{"from": from, "companies": copied}).Info("Copied catalog")
+	return copied, err
+}
+
+// InitDatabase loads the catalog of file on startup, which the readiness of
+// the catalog waits for. No catalog is loaded when file is empty.
+func (s companyService) InitDatabase(ctx context.Context, file string) error {
+	if file == "" {
+		log.Info("No catalog file to load")
+		s.catalog.finish(Report{}, nil)
+		return nil
+	}
+	report, err := s.loadCatalog(ctx, file)
+	s.catalog.finish(report, err)
+	return err
+}
+
+// commitTimeout bounds the commit of an atomic import, after which
+// RecoverImports takes it for the commit of a process that died
+const commitTimeout = 10 * time.Minute
+
+// RecoverImports discards the atomic imports of every tenant whose commit was
+// cut short by the death of its process, restoring the companies it changed.
+// Commits younger than commitTimeout are left alone, since other instances
+// may still be running them.
+func (s companyService) RecoverImports(ctx context.Context) error {
+	n, err := s.repository.RecoverStaged(ctx, time.Now().Add(-commitTimeout))
+	if n > 0 {
+		log.WithField("imports", n).Warn("Discarded interrupted commits")
+	}
+	return err
 }
 
-func (s companyService) InitDatabase(file string) error {
+// loadCatalog brings the companies of the tenant in line with the catalog of
+// file. Nothing is written when the file is the version last loaded;
+// otherwise only its companies that are missing are upserted, and the ones it
+// no longer has are removed.
+func (s companyService) loadCatalog(ctx context.Context, file string) (report Report, err error) {
 	log.Debug("Start database setup")
+	s.jobs.start()
+	defer s.jobs.done()
+	ctx, cancel := WithTimeout(ctx, s.timeouts.Import)
+	defer cancel()
+	ctx, span := startSpan(ctx, "loadCatalog", attribute.String("import.file", file))
+	defer func() { tracing.End(span, err) }()
+	report.File = filepath.Base(file)
 	f, err := os.Open(file)
 	if err != nil {
-		return err
+		return report, err
 	}
 	defer f.Close()
-	return s.iterateFileAndCall(f, s.addByArray)
-}
-
-func (s companyService) addByArray(fields []string) {
-	zipcode, _ := strconv.ParseInt(fields[1], 10, 0)
-	c := Company{Name: fields[0], Zipcode: zipcode}
-	s.add(c)
+	hash, err := hashContent(f)
+	if err != nil {
+		return report, err
+	}
+	loaded, err := s.repository.FindCatalogVersion(ctx, report.File)
+	if err != nil && err != mgo.ErrNotFound {
+		return report, err
+	}
+	if err == nil && loaded.Hash == hash {
+		log.WithField("file", report.File).Info("Catalog unchanged since it was loaded")
+		report.Rows, report.Unchanged = loaded.Rows, true
+		return report, nil
+	}
+	report, err = s.applyCatalog(ctx, f, report.File, hash)
+	s.observeImport(SourceCatalog, importStatus(err), report)
+	return report, err
 }
 
-func (s companyService) mergeDataByArray(fields []string) {
-	c, err := s.validateAndParseToEntity(fields)
+// applyCatalog upserts the companies of f, the catalog file named file, that
+// are missing, removes the ones of its previous version that it no longer
+// has, and records its version. Merged counts the upserted companies.
+func (s companyService) applyCatalog(ctx context.Context, f io.Reader, file string, hash string) (Report, error) {
+	rows := make(catalogRows)
+	report, err := s.importFile(ctx, f, ImportOptions{Source: SourceCatalog, Format: DetectFormat(file, "")}, rows.collect(file))
+	report.File = file
 	if err != nil {
-		log.WithError(err).Error("Cannot update values")
-		return
+		return report, err
 	}
-	info, err := s.repository.MergeWebsite(c)
+	existing, err := s.repository.FindCatalogCompanies(ctx, file, rows.sorted())
 	if err != nil {
-		log.WithError(err).Error("Cannot update values")
-		return
+		return report, err
+	}
+	upserts, removed := rows.delta(file, existing)
+	report.Merged = 0
+	size := s.config.batchSize()
+	for start := 0; start < len(upserts); start += size {
+		end := start + size
+		if end > len(upserts) {
+			end = len(upserts)
+		}
+		if err := s.repository.UpsertCompanies(ctx, upserts[start:end]); err != nil {
+			return report, err
+		}
+		report.Merged = end
 	}
-	log.Debug("Changed info")
-	log.Debug(fmt.Sprint(info))
+	if err := s.repository.RemoveCompanies(ctx, removed); err != nil {
+		return report, err
+	}
+	report.Removed = len(removed)
+	log.WithFields(log.Fields{"file": file, "added": report.Merged, "removed": report.Removed}).Info("Catalog changes applied")
+	return report, s.repository.SaveCatalogVersion(ctx, CatalogVersion{
+		File:     file,
+		Hash:     hash,
+		Rows:     report.Rows,
+		Added:    report.Merged,
+		Removed:  report.Removed,
+		LoadedAt: time.Now(),
+	})
+}
 
-	log.Error("No fields avaliable to update.")
+// catalogStatus returns the status of the initial load of the catalog, and
+// ErrCatalogLoading until it ends
+func (s companyService) catalogStatus() (CatalogStatus, error) {
+	return s.catalog.get()
+}
+
+func (s companyService) importStatus() ImportStatus {
+	return s.workers.status()
+}
 
+// drain waits for the running imports, until ctx is done
+func (s companyService) drain(ctx context.Context) error {
+	return s.jobs.wait(ctx)
 }
 
-func (s companyService) iterateFileAndCall(f io.Reader, c csvLineHandler) error {
-	t, err := ioutil.ReadAll(f)
-	check(err)
-	text := string(t[:])
-	reader := csv.NewReader(strings.NewReader(text))
-	if isSemicolonSeparated(text) {
-		reader.Comma = ';'
-		reader.Comment = '#'
+// importAtomically stages every row of f and commits them together, or
+// discards them all when the error rate exceeds the configured threshold.
+// Once staged, the rows are committed or discarded even when ctx ends.
+func (s companyService) importAtomically(ctx context.Context, id bson.ObjectId, f io.Reader, opts ImportOptions) (Report, error) {
+	report, err := s.importFile(ctx, f, opts, s.stageSink(id))
+	report.Atomic = true
+	if err == nil && report.errorRate() > s.config.MaxErrorRate {
+		log.WithField("rate", report.errorRate()).Info("Error rate above threshold, rolling back")
+		err = s.repository.DiscardStaged(detach(ctx), id)
+		report.rollback()
+		return report, err
+	}
+	if err == nil {
+		commitCtx, cancel := WithTimeout(detach(ctx), commitTimeout)
+		err = s.repository.CommitStaged(commitCtx, id)
+		cancel()
 	}
-	for {
-		row, err := reader.Read()
-		if err != nil {
-			if err == io.EOF {
-				return nil
+	if err != nil {
+		log.WithError(err).Error("Cannot commit staged rows, rolling back")
+		check(s.repository.DiscardStaged(detach(ctx), id))
+		report.rollback()
+	}
+	return report, err
+}
+
+// iterateFileAndCall imports each valid row of f into sink through the
+// import pipeline. It stops with the error of ctx once it is done, reporting
+// only the rows up to the last batch written so that a resumed import reads
+// the others again. The rows skipped by opts are only counted.
+func (s companyService) iterateFileAndCall(ctx context.Context, f io.Reader, opts ImportOptions, sink rowSink) (report Report, err error) {
+	reader, err := newRowReader(f, opts)
+	if err != nil {
+		return report, err
+	}
+	b := startBatch(ctx, report)
+	defer func() { b.end(report, err) }()
+	ctx, cancel := context.WithCancel(ctx)
+	p := s.newPipeline(ctx, opts, sink)
+	stages := p.start(reader, s.config.parseWorkers(), s.config.matchWorkers())
+	defer stages.Wait()
+	defer cancel()
+	err = p.run(func(written *rowBatch) {
+		for _, row := range written.rows {
+			if report.Rows-b.start.Rows == importBatchSize {
+				b.end(report, nil)
+				b = startBatch(ctx, report)
+			}
+			report.Rows++
+			switch {
+			case row.skipped:
+				report.Skipped++
+			case row.err != nil:
+				report.reject(row.line, row.err)
+			default:
+				report.Merged++
 			}
-			return err
 		}
-		c(row)
-	}
+	})
+	return report, err
 }
 
 func (s companyService) validateAndParseToEntity(fields []string) (Company, error) {
 	var c Company
 	if len(fields) < 3 {
-		return c, errors.New("Missing fields")
+		return c, RuleViolation{RuleMissingFields, "Missing fields"}
 	}
 	zipcode, err := validateZipcode(fields[1])
 	if err != nil {
@@ -124,19 +376,39 @@ func (s companyService) validateAndParseToEntity(fields []string) (Company, erro
 	return Company{Name: fields[0], Zipcode: zipcode, Website: fields[2]}, nil
 }
 
-func validateZipcode(zipcode string) (int64, error) {
+func validateZipcode(zipcode string) (Zipcode, error) {
 	if len(zipcode) != 5 {
-		return 0, errors.New("Invalid Zipcode lenght")
+		return 0, RuleViolation{RuleInvalidZipcode, "Invalid Zipcode lenght"}
 	}
-	return strconv.ParseInt(zipcode, 10, 0)
+	z, err := strconv.ParseInt(zipcode, 10, 0)
+	if err != nil {
+		return 0, RuleViolation{RuleInvalidZipcode, "Invalid Zipcode"}
+	}
+	return Zipcode(z), nil
 }
 
-func (s companyService) findByNameAndZipCode(name string, zip string) (Company, error) {
+func (s companyService) findByNameAndZipCode(ctx context.Context, name string, zip string) (c Company, err error) {
+	ctx, cancel := WithTimeout(ctx, s.timeouts.Search)
+	defer cancel()
+	ctx, span := startSpan(ctx, "findByNameAndZipCode")
+	defer func() { tracing.End(span, err) }()
 	zipcode, err := validateZipcode(zip)
 	if err != nil {
 		return Company{}, err
 	}
-	return s.repository.FindByNameAndZip(name, zipcode)
+	c, err = s.repository.FindByNameAndZip(ctx, name, zipcode)
+	if err == nil {
+		metrics.MatchScore.WithLabelValues("find").Observe(matchScore(name, c.Name))
+	}
+	return c, err
+}
+
+// importStatus returns the status of an import that ended with err
+func importStatus(err error) string {
+	if err != nil {
+		return UploadFailed
+	}
+	return UploadDone
 }
 
 func check(e error) {
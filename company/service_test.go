@@ -1,29 +1,90 @@
 package company
 
 import (
+	"context"
 	"errors"
 	"io"
 	"io/ioutil"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
 )
 
 type repoMock struct {
-	FindAllFn          func() ([]Company, error)
-	FindByNameAndZipFn func(string, int64) (Company, error)
-	AddFn              func(Company) error
-	MergeWebsiteFn     func(Company) (*mgo.ChangeInfo, error)
+	FindAllFn              func() ([]Company, error)
+	FindCatalogCompaniesFn func(string, []Company) ([]Company, error)
+	FindByNameAndZipFn     func(string, Zipcode) (Company, error)
+	AddFn                  func(Company) error
+	MatchCompaniesFn       func([]Company) ([]Company, error)
+	MergeWebsitesFn        func([]Company) ([]error, error)
+	FindUploadFn           func(string, string) (Upload, error)
+	ClaimUploadFn          func(Upload, string) error
+	SaveUploadFn           func(Upload) error
+	StageWebsitesFn        func(bson.ObjectId, []Company) ([]error, error)
+	CommitStagedFn         func(bson.ObjectId) error
+	DiscardStagedFn        func(bson.ObjectId) error
+	RecoverStagedFn        func(time.Time) (int, error)
+	CopyCatalogFn          func(string) (int, error)
+	UpsertCompaniesFn      func([]Company) error
+	RemoveCompaniesFn      func([]bson.ObjectId) error
+	FindCatalogFn          func(string) (CatalogVersion, error)
+	SaveCatalogFn          func(CatalogVersion) error
 }
 
-func (r repoMock) FindAll() ([]Company, error) { return r.FindAllFn() }
-func (r repoMock) FindByNameAndZip(a string, b int64) (Company, error) {
+func (r repoMock) ForTenant(string) Repository { return r }
+func (r repoMock) CopyCatalog(ctx context.Context, from string) (int, error) {
+	return r.CopyCatalogFn(from)
+}
+
+func (r repoMock) FindAll(ctx context.Context) ([]Company, error) { return r.FindAllFn() }
+func (r repoMock) FindCatalogCompanies(ctx context.Context, f string, c []Company) ([]Company, error) {
+	return r.FindCatalogCompaniesFn(f, c)
+}
+func (r repoMock) FindByNameAndZip(ctx context.Context, a string, b Zipcode) (Company, error) {
 	return r.FindByNameAndZipFn(a, b)
 }
-func (r repoMock) Add(c Company) error                             { return r.AddFn(c) }
-func (r repoMock) MergeWebsite(c Company) (*mgo.ChangeInfo, error) { return r.MergeWebsiteFn(c) }
+func (r repoMock) Add(ctx context.Context, c Company) error { return r.AddFn(c) }
+func (r repoMock) UpsertCompanies(ctx context.Context, c []Company) error {
+	return r.UpsertCompaniesFn(c)
+}
+func (r repoMock) RemoveCompanies(ctx context.Context, ids []bson.ObjectId) error {
+	return r.RemoveCompaniesFn(ids)
+}
+func (r repoMock) FindCatalogVersion(ctx context.Context, file string) (CatalogVersion, error) {
+	return r.FindCatalogFn(file)
+}
+func (r repoMock) SaveCatalogVersion(ctx context.Context, v CatalogVersion) error {
+	return r.SaveCatalogFn(v)
+}
+func (r repoMock) MatchCompanies(ctx context.Context, c []Company) ([]Company, error) {
+	return r.MatchCompaniesFn(c)
+}
+func (r repoMock) MergeWebsites(ctx context.Context, c []Company) ([]error, error) {
+	return r.MergeWebsitesFn(c)
+}
+func (r repoMock) FindUpload(ctx context.Context, h string, k string) (Upload, error) {
+	return r.FindUploadFn(h, k)
+}
+func (r repoMock) ClaimUpload(ctx context.Context, u Upload, p string) error {
+	return r.ClaimUploadFn(u, p)
+}
+func (r repoMock) SaveUpload(ctx context.Context, u Upload) error { return r.SaveUploadFn(u) }
+func (r repoMock) StageWebsites(ctx context.Context, i bson.ObjectId, c []Company) ([]error, error) {
+	return r.StageWebsitesFn(i, c)
+}
+func (r repoMock) CommitStaged(ctx context.Context, i bson.ObjectId) error {
+	return r.CommitStagedFn(i)
+}
+func (r repoMock) DiscardStaged(ctx context.Context, i bson.ObjectId) error {
+	return r.DiscardStagedFn(i)
+}
+func (r repoMock) RecoverStaged(ctx context.Context, before time.Time) (int, error) {
+	return r.RecoverStagedFn(before)
+}
 
 func TestNewService(t *testing.T) {
 	type args struct {
@@ -34,11 +95,11 @@ func TestNewService(t *testing.T) {
 		args args
 		want Service
 	}{
-		{"Test new Service", args{}, companyService{}},
+		{"Test new Service", args{}, companyService{catalog: &catalogLoad{}, workers: &workerPool{}, jobs: &jobTracker{}}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewService(tt.args.r); !reflect.DeepEqual(got, tt.want) {
+			if got := NewService(tt.args.r, ImportConfig{}, Timeouts{}); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewService() = %v, want %v", got, tt.want)
 			}
 		})
@@ -70,7 +131,7 @@ func Test_companyService_findAll(t *testing.T) {
 			s := companyService{
 				repository: tt.fields.repository,
 			}
-			got, err := s.findAll()
+			got, err := s.findAll(context.Background())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("companyService.findAll() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -113,75 +174,184 @@ func Test_companyService_add(t *testing.T) {
 			s := companyService{
 				repository: tt.fields.repository,
 			}
-			if err := s.add(tt.args.c); (err != nil) != tt.wantErr {
+			if err := s.add(context.Background(), tt.args.c); (err != nil) != tt.wantErr {
 				t.Errorf("companyService.add() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
 
-func Test_isSemicolonSeparated(t *testing.T) {
-	type args struct {
-		t string
-	}
-	tests := []struct {
-		name string
-		args args
-		want bool
-	}{
-		{"Is semicolon", args{"a;b;c"}, true},
-		{"Is not semicolon", args{"a,b,c"}, false},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := isSemicolonSeparated(tt.args.t); got != tt.want {
-				t.Errorf("isSemicolonSeparated() = %v, want %v", got, tt.want)
+func Test_companyService_loadWebsites(t *testing.T) {
+	notFound := func(string, string) (Upload, error) { return Upload{}, mgo.ErrNotFound }
+	found := func(status string) func(string, string) (Upload, error) {
+		return func(h string, k string) (Upload, error) {
+			return Upload{Hash: h, IdempotencyKey: k, Status: status}, nil
+		}
+	}
+	otherFile := func(string, string) (Upload, error) { return Upload{Hash: "other"}, nil }
+	// foundAfter returns no upload, then an upload of status once an
+	// identical upload claimed it
+	foundAfter := func(status string) func(string, string) (Upload, error) {
+		calls := 0
+		return func(h string, k string) (Upload, error) {
+			if calls++; calls == 1 {
+				return Upload{}, mgo.ErrNotFound
 			}
-		})
+			return Upload{Hash: h, IdempotencyKey: k, Status: status}, nil
+		}
 	}
-}
-
-func Test_companyService_loadWebsites(t *testing.T) {
+	claim := func(Upload, string) error { return nil }
+	claimed := func(Upload, string) error { return &mgo.LastError{Code: 11000, Err: "duplicate key"} }
+	save := func(Upload) error { return nil }
 	type fields struct {
 		repository Repository
 	}
 	type args struct {
-		f io.Reader
+		f    io.ReadSeeker
+		opts ImportOptions
 	}
 	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		wantErr bool
+		name         string
+		fields       fields
+		args         args
+		wantReplayed bool
+		wantStatus   string
+		wantErr      bool
 	}{
 		{"Load websites comma",
-			fields{repoMock{}},
-			args{strings.NewReader("a,b,c")},
-			false},
+			fields{repoMock{FindUploadFn: notFound, ClaimUploadFn: claim, SaveUploadFn: save}},
+			args{strings.NewReader("a,b,c"), ImportOptions{}},
+			false, UploadDone, false},
 		{"Load websites semicolon",
-			fields{repoMock{}},
-			args{strings.NewReader("a;b;c")},
-			false},
+			fields{repoMock{FindUploadFn: notFound, ClaimUploadFn: claim, SaveUploadFn: save}},
+			args{strings.NewReader("a;b;c"), ImportOptions{}},
+			false, UploadDone, false},
 		{"throws error",
-			fields{repoMock{}},
-			args{strings.NewReader("")},
-			false},
+			fields{repoMock{FindUploadFn: notFound, ClaimUploadFn: claim, SaveUploadFn: save}},
+			args{strings.NewReader(""), ImportOptions{}},
+			false, UploadDone, false},
+		{"Replay processed file",
+			fields{repoMock{FindUploadFn: found(UploadDone)}},
+			args{strings.NewReader("a,b,c"), ImportOptions{}},
+			true, UploadDone, false},
+		{"Retry failed file",
+			fields{repoMock{FindUploadFn: found(UploadFailed), ClaimUploadFn: claim, SaveUploadFn: save}},
+			args{strings.NewReader("a,b,c"), ImportOptions{IdempotencyKey: "key"}},
+			false, UploadDone, false},
+		{"File in progress",
+			fields{repoMock{FindUploadFn: found(UploadProcessing)}},
+			args{strings.NewReader("a,b,c"), ImportOptions{}},
+			false, UploadProcessing, true},
+		{"Identical upload claimed first",
+			fields{repoMock{FindUploadFn: foundAfter(UploadProcessing), ClaimUploadFn: claimed}},
+			args{strings.NewReader("a,b,c"), ImportOptions{}},
+			false, UploadProcessing, true},
+		{"Identical upload processed first",
+			fields{repoMock{FindUploadFn: foundAfter(UploadDone), ClaimUploadFn: claimed}},
+			args{strings.NewReader("a,b,c"), ImportOptions{}},
+			true, UploadDone, false},
+		{"Claim error",
+			fields{repoMock{FindUploadFn: notFound, ClaimUploadFn: func(Upload, string) error { return errors.New("mock error") }}},
+			args{strings.NewReader("a,b,c"), ImportOptions{}},
+			false, UploadProcessing, true},
+		{"Key reused with other file",
+			fields{repoMock{FindUploadFn: otherFile}},
+			args{strings.NewReader("a,b,c"), ImportOptions{IdempotencyKey: "key"}},
+			false, "", true},
+		{"Find upload error",
+			fields{repoMock{FindUploadFn: func(string, string) (Upload, error) {
+				return Upload{}, errors.New("mock error")
+			}}},
+			args{strings.NewReader("a,b,c"), ImportOptions{}},
+			false, "", true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := companyService{
 				repository: tt.fields.repository,
 			}
-			if err := s.loadWebsites(tt.args.f); (err != nil) != tt.wantErr {
+			got, replayed, err := s.loadWebsites(context.Background(), tt.args.f, tt.args.opts)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("companyService.loadWebsites() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if replayed != tt.wantReplayed {
+				t.Errorf("companyService.loadWebsites() replayed = %v, want %v", replayed, tt.wantReplayed)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("companyService.loadWebsites() status = %v, want %v", got.Status, tt.wantStatus)
+			}
 		})
 	}
 }
 
+// matchAll matches each company to a stored company of its own
+func matchAll(companies []Company) ([]Company, error) {
+	matches := make([]Company, len(companies))
+	for i, c := range companies {
+		matches[i] = Company{ID: bson.NewObjectId(), Name: c.Name, Zipcode: c.Zipcode}
+	}
+	return matches, nil
+}
+
+func Test_companyService_loadWebsites_interrupted(t *testing.T) {
+	file := "a,12345,http://a.com\nb,12346,http://b.com\nc,12347,http://c.com\n"
+	var stored Upload
+	var merged []string
+	ctx, cancel := context.WithCancel(context.Background())
+	s := companyService{repository: repoMock{
+		FindUploadFn: func(string, string) (Upload, error) {
+			if stored.ID == "" {
+				return Upload{}, mgo.ErrNotFound
+			}
+			return stored, nil
+		},
+		ClaimUploadFn: func(u Upload, previous string) error {
+			if stored.Status != previous {
+				return mgo.ErrNotFound
+			}
+			stored = u
+			return nil
+		},
+		SaveUploadFn: func(u Upload) error {
+			stored = u
+			return nil
+		},
+		MatchCompaniesFn: matchAll,
+		MergeWebsitesFn: func(companies []Company) ([]error, error) {
+			for _, c := range companies {
+				merged = append(merged, c.Name)
+				if c.Name == "b" {
+					cancel()
+				}
+			}
+			return make([]error, len(companies)), nil
+		},
+	}, config: ImportConfig{BatchSize: 1}, jobs: &jobTracker{}}
+	got, _, err := s.loadWebsites(ctx, strings.NewReader(file), ImportOptions{})
+	if err != context.Canceled {
+		t.Errorf("companyService.loadWebsites() error = %v, want %v", err, context.Canceled)
+	}
+	if got.Status != UploadInterrupted || stored.Status != UploadInterrupted || stored.Checkpoint != 2 {
+		t.Errorf("companyService.loadWebsites() saved %v at checkpoint %v, want interrupted at 2", stored.Status, stored.Checkpoint)
+	}
+	got, _, err = s.loadWebsites(context.Background(), strings.NewReader(file), ImportOptions{})
+	if err != nil || got.Status != UploadDone {
+		t.Errorf("resumed companyService.loadWebsites() = %v, %v, want done", got.Status, err)
+	}
+	if got.Report.Skipped != 2 || got.Report.Merged != 1 || !reflect.DeepEqual(merged, []string{"a", "b", "c"}) {
+		t.Errorf("resumed companyService.loadWebsites() report = %+v, merged %v, want the last row merged", got.Report, merged)
+	}
+	if err := s.drain(context.Background()); err != nil {
+		t.Errorf("companyService.drain() error = %v", err)
+	}
+}
+
 func Test_companyService_InitDatabase(t *testing.T) {
 	d1 := []byte("abc,asdf\n")
 	ioutil.WriteFile("dat1", d1, 0644)
+	hash, _ := hashContent(strings.NewReader(string(d1)))
+	loaded := CatalogVersion{File: "dat1", Hash: hash}
+	notLoaded := func(string) (CatalogVersion, error) { return CatalogVersion{}, mgo.ErrNotFound }
 	type fields struct {
 		repository Repository
 	}
@@ -194,21 +364,43 @@ func Test_companyService_InitDatabase(t *testing.T) {
 		args    args
 		wantErr bool
 	}{
-		{"Init database empty file",
+		{"Init database with new catalog",
 			fields{
-				repoMock{AddFn: func(Company) error { return nil }},
+				repoMock{
+					FindCatalogFn:          notLoaded,
+					FindCatalogCompaniesFn: func(string, []Company) ([]Company, error) { return nil, nil },
+					UpsertCompaniesFn:      func([]Company) error { return nil },
+					RemoveCompaniesFn:      func([]bson.ObjectId) error { return nil },
+					SaveCatalogFn:          func(CatalogVersion) error { return nil },
+				},
 			},
 			args{"dat1"},
 			false},
-		{"Init database with file",
+		{"Init database with unchanged catalog",
 			fields{
-				repoMock{AddFn: func(Company) error { return nil }},
+				repoMock{FindCatalogFn: func(string) (CatalogVersion, error) { return loaded, nil }},
 			},
 			args{"dat1"},
 			false},
+		{"Init database with failing upsert",
+			fields{
+				repoMock{
+					FindCatalogFn:          notLoaded,
+					FindCatalogCompaniesFn: func(string, []Company) ([]Company, error) { return nil, nil },
+					UpsertCompaniesFn:      func([]Company) error { return errors.New("mock error") },
+				},
+			},
+			args{"dat1"},
+			true},
+		{"Init database with failing version lookup",
+			fields{
+				repoMock{FindCatalogFn: func(string) (CatalogVersion, error) { return CatalogVersion{}, errors.New("mock error") }},
+			},
+			args{"dat1"},
+			true},
 		{"Init database with invalid file",
 			fields{
-				repoMock{AddFn: func(Company) error { return nil }},
+				repoMock{},
 			},
 			args{"dat2"},
 			true},
@@ -218,68 +410,67 @@ func Test_companyService_InitDatabase(t *testing.T) {
 			s := companyService{
 				repository: tt.fields.repository,
 			}
-			if err := s.InitDatabase(tt.args.file); (err != nil) != tt.wantErr {
+			if err := s.InitDatabase(context.Background(), tt.args.file); (err != nil) != tt.wantErr {
 				t.Errorf("companyService.InitDatabase() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
 
-func Test_companyService_addByArray(t *testing.T) {
-	type fields struct {
-		repository Repository
-	}
-	type args struct {
-		fields []string
-	}
-	tests := []struct {
-		name   string
-		fields fields
-		args   args
-	}{
-		{"Add by array",
-			fields{repoMock{AddFn: func(Company) error { return nil }}},
-			args{[]string{"asdf", "123"}}},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			s := companyService{
-				repository: tt.fields.repository,
-			}
-			s.addByArray(tt.args.fields)
-		})
+func Test_companyService_RecoverImports(t *testing.T) {
+	var got time.Time
+	s := NewService(repoMock{RecoverStagedFn: func(before time.Time) (int, error) {
+		got = before
+		return 1, nil
+	}}, ImportConfig{}, Timeouts{})
+	if err := s.RecoverImports(context.Background()); err != nil {
+		t.Fatalf("companyService.RecoverImports() error = %v", err)
+	}
+	if age := time.Since(got); age < commitTimeout || age > commitTimeout+time.Minute {
+		t.Errorf("companyService.RecoverImports() recovered commits older than %v, want %v", age, commitTimeout)
 	}
 }
 
-func Test_companyService_mergeDataByArray(t *testing.T) {
+func Test_companyService_importAtomically(t *testing.T) {
+	const file = "a;12345;x\nb;12346;y\nc;1;z\n"
+	stage := func(_ bson.ObjectId, companies []Company) ([]error, error) { return make([]error, len(companies)), nil }
+	ok := func(bson.ObjectId) error { return nil }
 	type fields struct {
 		repository Repository
-	}
-	type args struct {
-		fields []string
+		config     ImportConfig
 	}
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
+		name           string
+		fields         fields
+		wantMerged     int
+		wantRolledBack bool
+		wantErr        bool
 	}{
-		{"Should call mock repository",
-			fields{repoMock{MergeWebsiteFn: func(Company) (*mgo.ChangeInfo, error) {
-				return nil, nil
-			}}},
-			args{[]string{"adf", "12345", "site"}}},
-		{"Should handler error",
-			fields{repoMock{MergeWebsiteFn: func(Company) (*mgo.ChangeInfo, error) {
-				return nil, errors.New("mock error")
-			}}},
-			args{[]string{"adf", "12345", "site"}}},
+		{"Commit when error rate within threshold",
+			fields{repoMock{MatchCompaniesFn: matchAll, StageWebsitesFn: stage, CommitStagedFn: ok}, ImportConfig{MaxErrorRate: 0.5}},
+			2, false, false},
+		{"Rollback when error rate above threshold",
+			fields{repoMock{MatchCompaniesFn: matchAll, StageWebsitesFn: stage, DiscardStagedFn: ok}, ImportConfig{}},
+			0, true, false},
+		{"Rollback when commit fails",
+			fields{repoMock{MatchCompaniesFn: matchAll, StageWebsitesFn: stage, DiscardStagedFn: ok, CommitStagedFn: func(bson.ObjectId) error {
+				return errors.New("mock error")
+			}}, ImportConfig{MaxErrorRate: 1}},
+			0, true, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := companyService{
 				repository: tt.fields.repository,
+				config:     tt.fields.config,
+			}
+			got, err := s.importAtomically(context.Background(), bson.NewObjectId(), strings.NewReader(file), ImportOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("companyService.importAtomically() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Merged != tt.wantMerged || got.RolledBack != tt.wantRolledBack {
+				t.Errorf("companyService.importAtomically() = %+v, want merged %v rolledBack %v", got, tt.wantMerged, tt.wantRolledBack)
 			}
-			s.mergeDataByArray(tt.args.fields)
 		})
 	}
 }
@@ -289,8 +480,9 @@ func Test_companyService_iterateFileAndCall(t *testing.T) {
 		repository Repository
 	}
 	type args struct {
-		f io.Reader
-		c csvLineHandler
+		f    io.Reader
+		opts ImportOptions
+		c    rowHandler
 	}
 	tests := []struct {
 		name    string
@@ -298,14 +490,25 @@ func Test_companyService_iterateFileAndCall(t *testing.T) {
 		args    args
 		wantErr bool
 	}{
-		// TODO: Add test cases.
+		{"Iterate csv file",
+			fields{},
+			args{strings.NewReader("a;12345\n"), ImportOptions{}, func(context.Context, []string) error { return nil }},
+			false},
+		{"Iterate json file",
+			fields{},
+			args{strings.NewReader(`[{"name": "a", "zipcode": 12345}]`), ImportOptions{Format: FormatJSON}, func(context.Context, []string) error { return nil }},
+			false},
+		{"Unsupported format",
+			fields{},
+			args{strings.NewReader(""), ImportOptions{Format: "xml"}, func(context.Context, []string) error { return nil }},
+			true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := companyService{
 				repository: tt.fields.repository,
 			}
-			if err := s.iterateFileAndCall(tt.args.f, tt.args.c); (err != nil) != tt.wantErr {
+			if _, err := s.iterateFileAndCall(context.Background(), tt.args.f, tt.args.opts, tt.args.c); (err != nil) != tt.wantErr {
 				t.Errorf("companyService.iterateFileAndCall() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -366,7 +569,7 @@ func Test_validateZipcode(t *testing.T) {
 	tests := []struct {
 		name    string
 		args    args
-		want    int64
+		want    Zipcode
 		wantErr bool
 	}{
 		// TODO: Add test cases.
@@ -401,13 +604,13 @@ func Test_companyService_findByNameAndZipCode(t *testing.T) {
 		wantErr bool
 	}{
 		{"Call repo mock find by name and zip",
-			fields{repoMock{FindByNameAndZipFn: func(string, int64) (Company, error) {
+			fields{repoMock{FindByNameAndZipFn: func(string, Zipcode) (Company, error) {
 				return Company{}, nil
 			}}},
 			args{"name", "12345"},
 			Company{}, false},
 		{"Throw error when zipinvalid",
-			fields{repoMock{FindByNameAndZipFn: func(string, int64) (Company, error) {
+			fields{repoMock{FindByNameAndZipFn: func(string, Zipcode) (Company, error) {
 				return Company{}, nil
 			}}},
 			args{"name", "123"},
@@ -418,7 +621,7 @@ func Test_companyService_findByNameAndZipCode(t *testing.T) {
 			s := companyService{
 				repository: tt.fields.repository,
 			}
-			got, err := s.findByNameAndZipCode(tt.args.name, tt.args.zip)
+			got, err := s.findByNameAndZipCode(context.Background(), tt.args.name, tt.args.zip)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("companyService.findByNameAndZipCode() error = %v, wantErr %v", err, tt.wantErr)
 				return
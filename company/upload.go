@@ -0,0 +1,137 @@
+package company
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/marcospsbrito/dic/apierror"
+)
+
+// Upload status values
+const (
+	UploadProcessing = "processing"
+	UploadDone       = "done"
+	UploadFailed     = "failed"
+	// UploadInterrupted marks an import stopped by shutdown or by its
+	// context, which a retry resumes after its checkpoint
+	UploadInterrupted = "interrupted"
+)
+
+var (
+	// ErrUploadInProgress is returned when the same file is already being processed
+	ErrUploadInProgress = apierror.New(http.StatusConflict, "upload.in_progress", "Upload already in progress")
+	// ErrIdempotencyKeyReused is returned when an Idempotency-Key is sent with a different file
+	ErrIdempotencyKeyReused = apierror.New(http.StatusUnprocessableEntity, "upload.idempotency_key_reused", "Idempotency-Key already used with a different file")
+)
+
+// Upload entity records a processed file
+type Upload struct {
+	ID             bson.ObjectId `bson:"_id,omitempty" json:"id,omitempty" example:"5c8a1d5b0190b214360dc031"`
+	Tenant         string        `json:"-"`
+	Hash           string        `json:"hash" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	IdempotencyKey string        `bson:"idempotencyKey,omitempty" json:"idempotencyKey,omitempty" example:"3f1c6b2e"`
+	Status         string        `json:"status" example:"done"`
+	Report         Report        `json:"report"`
+	CreatedBy      string        `bson:"createdBy,omitempty" json:"createdBy,omitempty" example:"jwt:248289761001"`
+	CreatedAt      time.Time     `bson:"createdAt" json:"createdAt"`
+	// Checkpoint is the number of leading rows an interrupted import
+	// already imported
+	Checkpoint int `bson:"checkpoint,omitempty" json:"checkpoint,omitempty" example:"500"`
+}
+
+// Report summarizes the rows of an imported file
+type Report struct {
+	File       string      `json:"file,omitempty" example:"clients.csv"`
+	Rows       int         `json:"rows" example:"20"`
+	Merged     int         `json:"merged" example:"18"`
+	Rejected   []Rejection `json:"rejected,omitempty"`
+	Atomic     bool        `json:"atomic,omitempty" example:"true"`
+	RolledBack bool        `bson:"rolledBack,omitempty" json:"rolledBack,omitempty" example:"false"`
+	// Skipped counts the rows not imported again when an interrupted import
+	// is resumed
+	Skipped int `json:"skipped,omitempty" example:"0"`
+	// Removed counts the companies no longer in a catalog file that were
+	// removed
+	Removed int `json:"removed,omitempty" example:"0"`
+	// Unchanged reports a catalog file skipped as it was already loaded
+	Unchanged bool `json:"unchanged,omitempty" example:"false"`
+	// Files holds the report of each file of an archive, Rows and Merged
+	// being their totals
+	Files []Report `json:"files,omitempty"`
+}
+
+// Rejection describes a row that could not be imported
+type Rejection struct {
+	Line   int    `json:"line" example:"3"`
+	Rule   string `json:"rule,omitempty" example:"zipcode.invalid"`
+	Reason string `json:"reason" example:"Invalid Zipcode lenght"`
+}
+
+func (r *Report) reject(line int, err error) {
+	rejection := Rejection{Line: line, Reason: err.Error()}
+	if v, ok := err.(RuleViolation); ok {
+		rejection.Rule = v.Rule
+	}
+	r.Rejected = append(r.Rejected, rejection)
+}
+
+func (r *Report) add(file Report) {
+	r.Rows += file.Rows
+	r.Merged += file.Merged
+	r.Skipped += file.Skipped
+	r.Files = append(r.Files, file)
+}
+
+func (r Report) rejectedRows() int {
+	rejected := len(r.Rejected)
+	for _, file := range r.Files {
+		rejected += file.rejectedRows()
+	}
+	return rejected
+}
+
+// rejectionsByRule counts the rejected rows of r and of its files by rule
+func (r Report) rejectionsByRule() map[string]int {
+	counts := make(map[string]int)
+	for _, rejection := range r.Rejected {
+		counts[rejection.Rule]++
+	}
+	for _, file := range r.Files {
+		for rule, count := range file.rejectionsByRule() {
+			counts[rule] += count
+		}
+	}
+	return counts
+}
+
+func (r Report) errorRate() float64 {
+	if r.Rows == 0 {
+		return 0
+	}
+	return float64(r.rejectedRows()) / float64(r.Rows)
+}
+
+func (r *Report) rollback() {
+	r.Merged = 0
+	r.RolledBack = true
+	for i := range r.Files {
+		r.Files[i].rollback()
+	}
+}
+
+// hashContent returns the hex encoded sha256 of f and rewinds it
+func hashContent(f io.ReadSeeker) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,38 @@
+package company
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func Test_hashContent(t *testing.T) {
+	type args struct {
+		f io.ReadSeeker
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{"Hash empty file", args{strings.NewReader("")}, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", false},
+		{"Hash content", args{strings.NewReader("test")}, "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := hashContent(tt.args.f)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("hashContent() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("hashContent() = %v, want %v", got, tt.want)
+			}
+			if rest, _ := ioutil.ReadAll(tt.args.f); len(rest) != int(tt.args.f.(*strings.Reader).Size()) {
+				t.Error("hashContent() did not rewind the file")
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package company
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// xlsxRowReader streams the rows of a worksheet of an Excel workbook
+type xlsxRowReader struct {
+	sheet   io.ReadCloser
+	decoder *xml.Decoder
+	strings []string
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText is a rich or plain text of a shared or inline string
+type xlsxText struct {
+	T string `xml:"t"`
+	R []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.R) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.R {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxCell struct {
+	Ref   string   `xml:"r,attr"`
+	Type  string   `xml:"t,attr"`
+	Value string   `xml:"v"`
+	IS    xlsxText `xml:"is"`
+}
+
+// newXLSXRowReader reads the sheet named in opts, or the first sheet of the
+// workbook. f is buffered unless it can be read at random.
+func newXLSXRowReader(f io.Reader, opts ImportOptions) (RowReader, error) {
+	ra, size, err := readerAt(f)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	sheet, err := xlsxSheetPath(files, opts.Sheet)
+	if err != nil {
+		return nil, err
+	}
+	var shared []string
+	if file, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []xlsxText `xml:"si"`
+		}
+		if err := decodeZipXML(file, &sst); err != nil {
+			return nil, err
+		}
+		for _, item := range sst.Items {
+			shared = append(shared, item.String())
+		}
+	}
+	file, ok := files[sheet]
+	if !ok {
+		return nil, fmt.Errorf("Worksheet %s not found", sheet)
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxRowReader{sheet: rc, decoder: xml.NewDecoder(rc), strings: shared}, nil
+}
+
+func (r *xlsxRowReader) Read() ([]string, error) {
+	for {
+		t, err := r.decoder.Token()
+		if err != nil {
+			r.sheet.Close()
+			return nil, err
+		}
+		if start, ok := t.(xml.StartElement); ok && start.Name.Local == "row" {
+			return r.readRow()
+		}
+	}
+}
+
+func (r *xlsxRowReader) readRow() ([]string, error) {
+	var row []string
+	for {
+		t, err := r.decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		switch e := t.(type) {
+		case xml.StartElement:
+			if e.Name.Local != "c" {
+				continue
+			}
+			var cell xlsxCell
+			if err := r.decoder.DecodeElement(&cell, &e); err != nil {
+				return nil, err
+			}
+			i := len(row)
+			if column := xlsxColumn(cell.Ref); column >= 0 {
+				i = column
+			}
+			for len(row) <= i {
+				row = append(row, "")
+			}
+			row[i] = r.cellValue(cell)
+		case xml.EndElement:
+			if e.Name.Local == "row" {
+				return row, nil
+			}
+		}
+	}
+}
+
+func (r *xlsxRowReader) cellValue(cell xlsxCell) string {
+	switch cell.Type {
+	case "s":
+		i, err := strconv.Atoi(cell.Value)
+		if err != nil || i < 0 || i >= len(r.strings) {
+			return ""
+		}
+		return r.strings[i]
+	case "inlineStr":
+		return cell.IS.String()
+	}
+	return cell.Value
+}
+
+// xlsxSheetPath returns the archive path of the named or first worksheet
+func xlsxSheetPath(files map[string]*zip.File, name string) (string, error) {
+	var workbook xlsxWorkbook
+	var rels xlsxRelationships
+	file, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", ErrUnsupportedFormat
+	}
+	if err := decodeZipXML(file, &workbook); err != nil {
+		return "", err
+	}
+	if file, ok := files["xl/_rels/workbook.xml.rels"]; ok {
+		if err := decodeZipXML(file, &rels); err != nil {
+			return "", err
+		}
+	}
+	for i, sheet := range workbook.Sheets {
+		if (name == "" && i == 0) || sheet.Name == name {
+			for _, rel := range rels.Relationships {
+				if rel.ID == sheet.RID {
+					if strings.HasPrefix(rel.Target, "/") {
+						return strings.TrimPrefix(rel.Target, "/"), nil
+					}
+					return path.Join("xl", rel.Target), nil
+				}
+			}
+			return fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), nil
+		}
+	}
+	return "", fmt.Errorf("Sheet %q not found", name)
+}
+
+// xlsxColumn returns the zero based column of a cell reference like "AB12"
+func xlsxColumn(ref string) int {
+	column := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		column = column*26 + int(ch-'A'+1)
+	}
+	return column - 1
+}
+
+func decodeZipXML(file *zip.File, v interface{}) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// readerAt returns f as an io.ReaderAt, buffering it when it cannot seek
+func readerAt(f io.Reader) (io.ReaderAt, int64, error) {
+	if ra, ok := f.(io.ReaderAt); ok {
+		if seeker, ok := f.(io.Seeker); ok {
+			size, err := seeker.Seek(0, io.SeekEnd)
+			if err != nil {
+				return nil, 0, err
+			}
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return nil, 0, err
+			}
+			return ra, size, nil
+		}
+	}
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(b), int64(len(b)), nil
+}
@@ -0,0 +1,107 @@
+package company
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// newTestWorkbook builds an xlsx file with the sheets "Catalog" and "Clients"
+func newTestWorkbook(t *testing.T) []byte {
+	files := map[string]string{
+		"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Catalog" sheetId="1" r:id="rId1"/><sheet name="Clients" sheetId="2" r:id="rId2"/></sheets>
+</workbook>`,
+		"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/>
+</Relationships>`,
+		"xl/sharedStrings.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>tola sales group</t></si><si><r><t>http://</t></r><r><t>repsources.com</t></r></si>
+</sst>`,
+		"xl/worksheets/sheet1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1"><v>78229</v></c></row>
+</sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="inlineStr"><is><t>directv</t></is></c><c r="C1" t="s"><v>1</v></c></row>
+<row r="2"><c r="B2"><v>38006</v></c></row>
+</sheetData></worksheet>`,
+	}
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func Test_newXLSXRowReader(t *testing.T) {
+	workbook := newTestWorkbook(t)
+	tests := []struct {
+		name     string
+		sheet    string
+		buffered bool
+		want     [][]string
+		wantErr  bool
+	}{
+		{"Read first sheet", "", false, [][]string{{"tola sales group", "78229"}}, false},
+		{"Read named sheet", "Clients", false, [][]string{{"directv", "", "http://repsources.com"}, {"", "38006"}}, false},
+		{"Read from stream", "Catalog", true, [][]string{{"tola sales group", "78229"}}, false},
+		{"Missing sheet", "Other", false, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f io.Reader = bytes.NewReader(workbook)
+			if tt.buffered {
+				f = bytes.NewBuffer(workbook)
+			}
+			r, err := newXLSXRowReader(f, ImportOptions{Sheet: tt.sheet})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newXLSXRowReader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got, err := readAllRows(r)
+			if err != nil {
+				t.Fatalf("xlsxRowReader.Read() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("xlsxRowReader.Read() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_xlsxColumn(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want int
+	}{
+		{"A1", 0},
+		{"C12", 2},
+		{"AB3", 27},
+		{"", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			if got := xlsxColumn(tt.ref); got != tt.want {
+				t.Errorf("xlsxColumn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
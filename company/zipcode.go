@@ -0,0 +1,48 @@
+package company
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// Zipcode is a five digit zipcode. It is answered as a number but stored as
+// a zero-padded string, so zipcodes starting with 0 keep their digits;
+// zipcodes stored as numbers before migration 7 are still read.
+type Zipcode int64
+
+// String returns z zero-padded to five digits
+func (z Zipcode) String() string {
+	return fmt.Sprintf("%05d", int64(z))
+}
+
+// GetBSON stores z as a zero-padded string
+func (z Zipcode) GetBSON() (interface{}, error) {
+	return z.String(), nil
+}
+
+// SetBSON reads a zipcode stored as a string or as a number
+func (z *Zipcode) SetBSON(raw bson.Raw) error {
+	var v interface{}
+	if err := raw.Unmarshal(&v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid zipcode %q: %v", v, err)
+		}
+		*z = Zipcode(n)
+	case int:
+		*z = Zipcode(v)
+	case int64:
+		*z = Zipcode(v)
+	case float64:
+		*z = Zipcode(v)
+	default:
+		return fmt.Errorf("Invalid zipcode %v", v)
+	}
+	return nil
+}
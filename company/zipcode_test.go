@@ -0,0 +1,50 @@
+package company
+
+import (
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+func TestZipcode_BSON(t *testing.T) {
+	type document struct {
+		Zipcode Zipcode `bson:"zipcode"`
+	}
+	tests := []struct {
+		name    string
+		stored  interface{}
+		want    Zipcode
+		wantErr bool
+	}{
+		{"Zero-padded string", "01234", 1234, false},
+		{"Legacy int32", int32(38006), 38006, false},
+		{"Legacy int64", int64(78229), 78229, false},
+		{"Legacy double", float64(1234), 1234, false},
+		{"Invalid string", "abc", 0, true},
+		{"Other type", true, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := bson.Marshal(bson.M{"zipcode": tt.stored})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got document
+			err = bson.Unmarshal(b, &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Zipcode.SetBSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Zipcode != tt.want {
+				t.Errorf("Zipcode.SetBSON() = %v, want %v", got.Zipcode, tt.want)
+			}
+		})
+	}
+	b, err := bson.Marshal(document{1234})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored bson.M
+	if err := bson.Unmarshal(b, &stored); err != nil || stored["zipcode"] != "01234" {
+		t.Errorf("Zipcode.GetBSON() stored %v, want 01234", stored["zipcode"])
+	}
+}
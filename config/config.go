@@ -1,33 +1,222 @@
 package config
 
 import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/apex/log"
-	"github.com/caarlos0/env"
 )
 
+// Config holds the settings of the application. Each setting is named by its
+// env tag: the environment variable sets it, the lower cased name is its key
+// in configuration files and the lower cased name with dashes is its flag.
+// Settings tagged reload are applied again when the configuration is reloaded.
 type Config struct {
-	MongoURL    string `env:"MONGO_URL" envDefault:"localhost"`
-	MongoDBName string `env:"MONGO_DB_NAME" envDefault:"dic"`
-	LogLevel    string `env:"LOG_LEVEL" envDefault:"debug"`
-	Adress      string `env:"adress" envDefault:"localhost:8091"`
-	InitFile    string `env:"INIT_FILE" envDefault:"resource/q1_catalog.csv"`
+	Storage               string        `env:"STORAGE" envDefault:"mongo"`
+	MongoURL              string        `env:"MONGO_URL" envDefault:"localhost" secret:"url"`
+	MongoDBName           string        `env:"MONGO_DB_NAME" envDefault:"dic"`
+	MongoConnectAttempts  int           `env:"MONGO_CONNECT_ATTEMPTS" envDefault:"10"`
+	MongoBackoffInitial   time.Duration `env:"MONGO_BACKOFF_INITIAL" envDefault:"200ms"`
+	MongoBackoffMax       time.Duration `env:"MONGO_BACKOFF_MAX" envDefault:"5s"`
+	MongoReadAttempts     int           `env:"MONGO_READ_ATTEMPTS" envDefault:"3"`
+	MongoBreakerThreshold int           `env:"MONGO_BREAKER_THRESHOLD" envDefault:"5"`
+	MongoBreakerCooldown  time.Duration `env:"MONGO_BREAKER_COOLDOWN" envDefault:"10s"`
+	MigrateOnStartup      bool          `env:"MIGRATE_ON_STARTUP" envDefault:"true"`
+	MigrationTimeout      time.Duration `env:"MIGRATION_TIMEOUT" envDefault:"10m"`
+	LogLevel              string        `env:"LOG_LEVEL" envDefault:"debug" reload:"true"`
+	Address               string        `env:"ADDRESS" envDefault:"localhost:8091"`
+	InitFile              string        `env:"INIT_FILE" envDefault:"resource/q1_catalog.csv"`
+	CatalogRequired       bool          `env:"CATALOG_REQUIRED" envDefault:"false"`
+	ImportMaxErrorRate    float64       `env:"IMPORT_MAX_ERROR_RATE" envDefault:"0"`
+	RulesFile             string        `env:"RULES_FILE" envDefault:"resource/rules.yaml"`
+	AuthEnabled           bool          `env:"AUTH_ENABLED" envDefault:"true"`
+	AdminAPIKey           string        `env:"ADMIN_API_KEY" secret:"true"`
+	JWKS                  string        `env:"JWKS"`
+	JWTIssuer             string        `env:"JWT_ISSUER"`
+	JWTAudience           string        `env:"JWT_AUDIENCE"`
+	JWTTenantClaim        string        `env:"JWT_TENANT_CLAIM" envDefault:"tenant"`
+	RateLimitEnabled      bool          `env:"RATE_LIMIT_ENABLED" envDefault:"true"`
+	RateLimit             string        `env:"RATE_LIMIT" envDefault:"20/s:40"`
+	RateLimitRoutes       string        `env:"RATE_LIMIT_ROUTES" envDefault:"GET /companies=5/s:10;POST /companies/websites=10/m"`
+	RateLimitStore        string        `env:"RATE_LIMIT_STORE" envDefault:"memory"`
+	ExportDailyQuota      int           `env:"EXPORT_DAILY_QUOTA" envDefault:"1000"`
+	ImportDailyQuota      int           `env:"IMPORT_DAILY_QUOTA" envDefault:"200"`
+	UploadMaxBytes        int64         `env:"UPLOAD_MAX_BYTES" envDefault:"33554432"`
+	ImportWorkers         int           `env:"IMPORT_WORKERS" envDefault:"4"`
+	ImportBatchSize       int           `env:"IMPORT_BATCH_SIZE" envDefault:"500"`
+	ImportParseWorkers    int           `env:"IMPORT_PARSE_WORKERS" envDefault:"4"`
+	ImportMatchWorkers    int           `env:"IMPORT_MATCH_WORKERS" envDefault:"2"`
+	TraceExporter         string        `env:"TRACE_EXPORTER" envDefault:"none"`
+	TraceEndpoint         string        `env:"TRACE_ENDPOINT"`
+	TraceSampleRatio      float64       `env:"TRACE_SAMPLE_RATIO" envDefault:"1"`
+	SearchTimeout         time.Duration `env:"SEARCH_TIMEOUT" envDefault:"5s"`
+	ExportTimeout         time.Duration `env:"EXPORT_TIMEOUT" envDefault:"30s"`
+	ImportTimeout         time.Duration `env:"IMPORT_TIMEOUT" envDefault:"10m"`
+	CopyTimeout           time.Duration `env:"COPY_TIMEOUT" envDefault:"5m"`
+	ShutdownTimeout       time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"30s"`
+}
+
+// FileEnv is the environment variable naming the configuration file when the
+// --config flag is not given
+const FileEnv = "CONFIG_FILE"
+
+// deprecatedEnv maps settings to the former names of their environment
+// variables, still read when the new one is not set
+var deprecatedEnv = map[string]string{
+	"ADDRESS": "adress",
+}
+
+// Load returns the configuration layered from the defaults, the configuration
+// file, the environment and the flags, each overriding the previous ones.
+// The configuration is validated before it is returned.
+func Load(f Flags) (Config, error) {
+	var cfg Config
+	if err := apply(&cfg, defaults()); err != nil {
+		return Config{}, err
+	}
+	path := f.File
+	if path == "" {
+		path = os.Getenv(FileEnv)
+	}
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+		if err := apply(&cfg, values); err != nil {
+			return Config{}, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	if err := apply(&cfg, environ()); err != nil {
+		return Config{}, fmt.Errorf("environment: %v", err)
+	}
+	if err := apply(&cfg, f.values()); err != nil {
+		return Config{}, fmt.Errorf("flags: %v", err)
+	}
+	return cfg, cfg.Validate()
+}
+
+// Reload returns current with the reloadable settings of next, and the names
+// of the other settings that changed, which only apply after a restart
+func Reload(current, next Config) (Config, []string) {
+	cur := reflect.ValueOf(&current).Elem()
+	nxt := reflect.ValueOf(next)
+	var pending []string
+	for i := 0; i < cur.NumField(); i++ {
+		field := cur.Type().Field(i)
+		if cur.Field(i).Interface() == nxt.Field(i).Interface() {
+			continue
+		}
+		if field.Tag.Get("reload") == "true" {
+			cur.Field(i).Set(nxt.Field(i))
+			continue
+		}
+		pending = append(pending, field.Tag.Get("env"))
+	}
+	return current, pending
 }
 
-var cfg Config
+// defaults returns the envDefault values of the settings
+func defaults() map[string]string {
+	values := make(map[string]string)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if v, ok := t.Field(i).Tag.Lookup("envDefault"); ok {
+			values[t.Field(i).Tag.Get("env")] = v
+		}
+	}
+	return values
+}
 
-// Get returns a Config instance
-func Get() Config {
-	if cfg == (Config{}) {
-		MustReadFromEnv()
+// environ returns the settings set by environment variables, warning about
+// deprecated names
+func environ() map[string]string {
+	values := make(map[string]string)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("env")
+		if v, ok := os.LookupEnv(name); ok {
+			values[name] = v
+			continue
+		}
+		if old, ok := deprecatedEnv[name]; ok {
+			if v, ok := os.LookupEnv(old); ok {
+				log.WithFields(log.Fields{"variable": old, "replacement": name}).Warn("Deprecated environment variable")
+				values[name] = v
+			}
+		}
 	}
-	return cfg
+	return values
 }
 
-// MustReadFromEnv loads config values from environment vars and terminates
-// program execution if it fails for any reason
-func MustReadFromEnv() {
-	err := env.Parse(&cfg)
-	if err != nil {
-		log.WithError(err).Fatal("reading config")
+// apply sets the settings of cfg named in values, which must all be known
+func apply(cfg *Config, values map[string]string) error {
+	v := reflect.ValueOf(cfg).Elem()
+	fields := make(map[string]int, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		fields[v.Type().Field(i).Tag.Get("env")] = i
+	}
+	for name, s := range values {
+		i, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("unknown setting %s", keyOf(name))
+		}
+		if err := set(v.Field(i), s); err != nil {
+			return fmt.Errorf("invalid %s %q: %v", name, s, err)
+		}
 	}
+	return nil
+}
+
+// durationType is the type of the time.Duration settings
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses s into v according to its type
+func set(v reflect.Value, s string) error {
+	s = strings.TrimSpace(s)
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	var err error
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(s); err == nil {
+			v.SetBool(b)
+		}
+	case reflect.Int, reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(s, 10, 64); err == nil {
+			v.SetInt(n)
+		}
+	case reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(s, 64); err == nil {
+			v.SetFloat(f)
+		}
+	default:
+		err = fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return err
+}
+
+// keyOf returns the file key of the setting name
+func keyOf(name string) string {
+	return strings.ToLower(name)
+}
+
+// flagOf returns the flag of the setting name
+func flagOf(name string) string {
+	return strings.Replace(keyOf(name), "_", "-", -1)
 }
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes content to name in a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testFlags returns the flags of args pointing the data files at existing ones
+func testFlags(t *testing.T, args ...string) Flags {
+	t.Helper()
+	data := writeFile(t, "data", "")
+	f, err := parseFlags("dic", append([]string{"--init-file", data, "--rules-file", data}, args...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestLoad(t *testing.T) {
+	yamlFile := writeFile(t, "dic.yaml", "log_level: info\naddress: 0.0.0.0:9000\nimport_workers: 8\nsearch_timeout: 2s\n")
+	tomlFile := writeFile(t, "dic.toml", "log_level = \"warn\"\nauth_enabled = false\ntrace_sample_ratio = 0.5\n")
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		check   func(Config) bool
+		wantErr bool
+	}{
+		{"Defaults", nil, nil, func(c Config) bool {
+			return c.Address == "localhost:8091" && c.LogLevel == "debug" && c.SearchTimeout == 5*time.Second
+		}, false},
+		{"YAML file", nil, []string{"--config", yamlFile}, func(c Config) bool {
+			return c.LogLevel == "info" && c.Address == "0.0.0.0:9000" && c.ImportWorkers == 8 && c.SearchTimeout == 2*time.Second
+		}, false},
+		{"TOML file", map[string]string{FileEnv: tomlFile}, nil, func(c Config) bool {
+			return c.LogLevel == "warn" && !c.AuthEnabled && c.TraceSampleRatio == 0.5
+		}, false},
+		{"Environment overrides file", map[string]string{"LOG_LEVEL": "error"}, []string{"--config", yamlFile}, func(c Config) bool {
+			return c.LogLevel == "error" && c.ImportWorkers == 8
+		}, false},
+		{"Flags override environment", map[string]string{"LOG_LEVEL": "error"}, []string{"--config", yamlFile, "--log-level", "fatal"}, func(c Config) bool {
+			return c.LogLevel == "fatal"
+		}, false},
+		{"Deprecated address variable", map[string]string{"adress": ":7000"}, nil, func(c Config) bool {
+			return c.Address == ":7000"
+		}, false},
+		{"Address variable over deprecated one", map[string]string{"adress": ":7000", "ADDRESS": ":7001"}, nil, func(c Config) bool {
+			return c.Address == ":7001"
+		}, false},
+		{"Unknown file key", nil, []string{"--config", writeFile(t, "bad.yaml", "log_levle: info\n")}, nil, true},
+		{"Unsupported file format", nil, []string{"--config", writeFile(t, "dic.json", "{}")}, nil, true},
+		{"Missing file", nil, []string{"--config", "missing.yaml"}, nil, true},
+		{"Invalid value", map[string]string{"IMPORT_WORKERS": "many"}, nil, nil, true},
+		{"Invalid configuration", nil, []string{"--address", "localhost"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			got, err := Load(testFlags(t, tt.args...))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !tt.check(got) {
+				t.Errorf("Load() = %+v", got)
+			}
+		})
+	}
+}
+
+func Test_parseFlags(t *testing.T) {
+	if _, err := parseFlags("dic", []string{"--unknown"}); err == nil {
+		t.Errorf("parseFlags() accepted an unknown flag")
+	}
+	f, err := parseFlags("dic", []string{"--print-config", "--config", "dic.yaml", "--mongo-url", "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.File != "dic.yaml" || !f.PrintConfig {
+		t.Errorf("parseFlags() = %+v, want dic.yaml printed", f)
+	}
+	if got, want := f.values(), map[string]string{"MONGO_URL": "db"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseFlags() settings = %v, want %v", got, want)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid, err := Load(testFlags(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		change  func(*Config)
+		wantErr string
+	}{
+		{"Valid", func(*Config) {}, ""},
+		{"Address without port", func(c *Config) { c.Address = "localhost" }, "ADDRESS"},
+		{"Address with named port", func(c *Config) { c.Address = "localhost:http" }, "ADDRESS"},
+		{"Unknown log level", func(c *Config) { c.LogLevel = "verbose" }, "LOG_LEVEL"},
+		{"Missing init file", func(c *Config) { c.InitFile = "missing.csv" }, "INIT_FILE"},
+		{"Directory rules file", func(c *Config) { c.RulesFile = t.TempDir() }, "RULES_FILE"},
+		{"Unknown storage", func(c *Config) { c.Storage = "redis" }, "STORAGE"},
+		{"Error rate above 1", func(c *Config) { c.ImportMaxErrorRate = 1.5 }, "IMPORT_MAX_ERROR_RATE"},
+		{"Negative timeout", func(c *Config) { c.SearchTimeout = -time.Second }, "SEARCH_TIMEOUT"},
+		{"Negative workers", func(c *Config) { c.ImportWorkers = -1 }, "IMPORT_WORKERS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.change(&c)
+			err := c.Validate()
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	current := Config{LogLevel: "debug", Address: ":8091", ImportWorkers: 4}
+	next := Config{LogLevel: "info", Address: ":9000", ImportWorkers: 4}
+	got, pending := Reload(current, next)
+	want := Config{LogLevel: "info", Address: ":8091", ImportWorkers: 4}
+	if got != want {
+		t.Errorf("Reload() = %+v, want %+v", got, want)
+	}
+	if !reflect.DeepEqual(pending, []string{"ADDRESS"}) {
+		t.Errorf("Reload() pending = %v, want [ADDRESS]", pending)
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want Config
+	}{
+		{"No secrets", Config{MongoURL: "localhost"}, Config{MongoURL: "localhost"}},
+		{"API key", Config{AdminAPIKey: "secret"}, Config{AdminAPIKey: redacted}},
+		{"URL password", Config{MongoURL: "mongodb://dic:secret@db:27017/dic"}, Config{MongoURL: "mongodb://dic:REDACTED@db:27017/dic"}},
+		{"URL without scheme", Config{MongoURL: "dic:p@ss@db"}, Config{MongoURL: "dic:REDACTED@db"}},
+		{"URL without password", Config{MongoURL: "mongodb://dic@db"}, Config{MongoURL: "mongodb://dic@db"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.Redacted(); got != tt.want {
+				t.Errorf("Redacted() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_Print(t *testing.T) {
+	cfg, err := Load(testFlags(t, "--admin-api-key", "secret", "--import-timeout", "90s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := cfg.Print(&out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "secret") || !strings.Contains(out.String(), "import_timeout: 1m30s") {
+		t.Fatalf("Print() = %s", out.String())
+	}
+	f, err := parseFlags("dic", []string{"--config", writeFile(t, "printed.yaml", out.String())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed, err := Load(f)
+	if err != nil {
+		t.Fatalf("Load() of printed configuration error = %v", err)
+	}
+	if want := cfg.Redacted(); printed.Redacted() != want {
+		t.Errorf("Load() of printed configuration = %+v, want %+v", printed, want)
+	}
+}
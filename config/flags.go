@@ -0,0 +1,60 @@
+package config
+
+import (
+	"flag"
+	"reflect"
+)
+
+// Flags are the command line flags: the configuration file, whether to print
+// the configuration instead of running, and the settings they override
+type Flags struct {
+	// File is the configuration file, FileEnv when empty
+	File string
+	// PrintConfig prints the redacted configuration and exits
+	PrintConfig bool
+	// set holds the flags of the settings
+	set *flag.FlagSet
+}
+
+// Bind registers --config, --print-config and the flags of the settings on
+// fs. Every setting has a flag named after its environment variable, like
+// --log-level for LOG_LEVEL. The returned Flags hold their values once fs is
+// parsed.
+func Bind(fs *flag.FlagSet) *Flags {
+	f := &Flags{set: fs}
+	fs.StringVar(&f.File, "config", "", "YAML or TOML configuration `file`, "+FileEnv+" when empty")
+	fs.BoolVar(&f.PrintConfig, "print-config", false, "print the configuration with secrets redacted and exit")
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag
+		fs.String(flagOf(tag.Get("env")), tag.Get("envDefault"), "overrides "+tag.Get("env"))
+	}
+	return f
+}
+
+// parseFlags parses the command line arguments with the flags of Bind
+func parseFlags(name string, args []string) (Flags, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	f := Bind(fs)
+	if err := fs.Parse(args); err != nil {
+		return Flags{}, err
+	}
+	return *f, nil
+}
+
+// values returns the settings given as flags, by env name
+func (f Flags) values() map[string]string {
+	values := make(map[string]string)
+	if f.set == nil {
+		return values
+	}
+	t := reflect.TypeOf(Config{})
+	f.set.Visit(func(fl *flag.Flag) {
+		for i := 0; i < t.NumField(); i++ {
+			if name := t.Field(i).Tag.Get("env"); flagOf(name) == fl.Name {
+				values[name] = fl.Value.String()
+			}
+		}
+	})
+	return values
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// redacted replaces secrets in printed configurations
+const redacted = "REDACTED"
+
+// Redacted returns cfg with its secrets replaced: settings tagged secret are
+// redacted when set, and the password of those tagged secret:"url"
+func (cfg Config) Redacted() Config {
+	v := reflect.ValueOf(&cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch v.Type().Field(i).Tag.Get("secret") {
+		case "true":
+			if field.String() != "" {
+				field.SetString(redacted)
+			}
+		case "url":
+			field.SetString(redactPassword(field.String()))
+		}
+	}
+	return cfg
+}
+
+// Print writes cfg with its secrets redacted to w as a YAML configuration
+// file, which Load reads back
+func (cfg Config) Print(w io.Writer) error {
+	v := reflect.ValueOf(cfg.Redacted())
+	out := make(yaml.MapSlice, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		var value interface{} = v.Field(i).Interface()
+		if v.Field(i).Type() == durationType {
+			value = time.Duration(v.Field(i).Int()).String()
+		}
+		out = append(out, yaml.MapItem{Key: keyOf(v.Type().Field(i).Tag.Get("env")), Value: value})
+	}
+	data, err := yaml.Marshal(out)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// redactPassword replaces the password of the user info of a MongoDB url,
+// with or without scheme
+func redactPassword(url string) string {
+	start := 0
+	if i := strings.Index(url, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	at := strings.LastIndex(url, "@")
+	if at < start {
+		return url
+	}
+	colon := strings.Index(url[start:at], ":")
+	if colon < 0 {
+		return url
+	}
+	return url[:start+colon+1] + redacted + url[at:]
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/apex/log"
+)
+
+// Validate returns an error listing every invalid setting of cfg
+func (cfg Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	check(validAddress(cfg.Address), "ADDRESS %q is not a host:port address", cfg.Address)
+	_, err := log.ParseLevel(cfg.LogLevel)
+	check(err == nil, "LOG_LEVEL %q is not debug, info, warn, error or fatal", cfg.LogLevel)
+	check(oneOf(cfg.Storage, "mongo", "memory"), "STORAGE %q is not mongo or memory", cfg.Storage)
+	check(oneOf(cfg.RateLimitStore, "memory", "mongo"), "RATE_LIMIT_STORE %q is not memory or mongo", cfg.RateLimitStore)
+	check(oneOf(cfg.TraceExporter, "none", "stdout", "otlp"), "TRACE_EXPORTER %q is not none, stdout or otlp", cfg.TraceExporter)
+	check(cfg.ImportMaxErrorRate >= 0 && cfg.ImportMaxErrorRate <= 1, "IMPORT_MAX_ERROR_RATE %v is not between 0 and 1", cfg.ImportMaxErrorRate)
+	check(cfg.TraceSampleRatio >= 0 && cfg.TraceSampleRatio <= 1, "TRACE_SAMPLE_RATIO %v is not between 0 and 1", cfg.TraceSampleRatio)
+	for _, f := range []struct{ name, path string }{{"INIT_FILE", cfg.InitFile}, {"RULES_FILE", cfg.RulesFile}} {
+		if f.path == "" {
+			continue
+		}
+		info, err := os.Stat(f.path)
+		check(err == nil && !info.IsDir(), "%s %q is not a readable file", f.name, f.path)
+	}
+	v := reflect.ValueOf(cfg)
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.Int, reflect.Int64:
+			check(field.Int() >= 0, "%s %v is negative", v.Type().Field(i).Tag.Get("env"), field.Interface())
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validAddress tells whether address is a host:port pair with a numeric port
+func validAddress(address string) bool {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	n, err := strconv.Atoi(port)
+	return err == nil && n >= 0 && n <= 65535
+}
+
+func oneOf(s string, values ...string) bool {
+	for _, v := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
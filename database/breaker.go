@@ -0,0 +1,100 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// States of the circuit breaker
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// ErrCircuitOpen is returned without reaching the database while the circuit
+// breaker is open
+var ErrCircuitOpen = errors.New("Database circuit open, failing fast")
+
+// breaker opens after threshold consecutive operations find the database
+// unavailable, failing the following ones fast. Once cooldown passes a single
+// operation probes the database, closing the circuit when it succeeds.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	now       func() time.Time
+}
+
+// newBreaker returns a closed breaker, which never opens when threshold is
+// not positive
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+func (b *breaker) open() bool {
+	return b.threshold > 0 && b.failures >= b.threshold
+}
+
+// allow reports whether an operation may reach the database
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open() {
+		return true
+	}
+	if b.probing || b.now().Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record records the outcome of an operation that was allowed. Errors other
+// than unavailability mean the database answered.
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if IsUnavailable(err) {
+		b.failures++
+		if b.open() {
+			if b.failures == b.threshold {
+				log.WithError(err).Warn("Database circuit opened")
+			}
+			b.openedAt = b.now()
+		}
+		return
+	}
+	if b.open() {
+		log.Info("Database circuit closed")
+	}
+	b.failures = 0
+}
+
+// release ends an operation that was allowed without recording its outcome,
+// like one interrupted by its context
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// state returns the state of the circuit
+func (b *breaker) state() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case !b.open():
+		return CircuitClosed
+	case b.probing || b.now().Sub(b.openedAt) >= b.cooldown:
+		return CircuitHalfOpen
+	}
+	return CircuitOpen
+}
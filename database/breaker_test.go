@@ -0,0 +1,68 @@
+package database
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo"
+)
+
+func Test_breaker(t *testing.T) {
+	now := time.Now()
+	b := newBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+	steps := []struct {
+		name      string
+		advance   time.Duration
+		outcome   error
+		wantAllow bool
+		wantState string
+	}{
+		{"Closed", 0, io.EOF, true, CircuitClosed},
+		{"Opens at threshold", 0, io.EOF, true, CircuitOpen},
+		{"Fails fast while open", 30 * time.Second, nil, false, CircuitOpen},
+		{"Probe after cooldown fails", 31 * time.Second, io.EOF, true, CircuitOpen},
+		{"Probe after cooldown succeeds", time.Minute, mgo.ErrNotFound, true, CircuitClosed},
+		{"Closed again", 0, nil, true, CircuitClosed},
+	}
+	for _, st := range steps {
+		now = now.Add(st.advance)
+		allowed := b.allow()
+		if allowed != st.wantAllow {
+			t.Fatalf("%s: breaker.allow() = %v, want %v", st.name, allowed, st.wantAllow)
+		}
+		if allowed {
+			b.record(st.outcome)
+		}
+		if got := b.state(); got != st.wantState {
+			t.Errorf("%s: breaker.state() = %v, want %v", st.name, got, st.wantState)
+		}
+	}
+}
+
+func Test_breaker_singleProbe(t *testing.T) {
+	b := newBreaker(1, 0)
+	b.allow()
+	b.record(io.EOF)
+	if !b.allow() {
+		t.Fatal("breaker.allow() = false, want a probe once cooled down")
+	}
+	if b.allow() {
+		t.Error("breaker.allow() = true while probing, want a single probe")
+	}
+	b.release()
+	if !b.allow() {
+		t.Error("breaker.allow() = false after the probe was released")
+	}
+}
+
+func Test_breaker_disabled(t *testing.T) {
+	b := newBreaker(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		b.record(io.EOF)
+	}
+	if !b.allow() || b.state() != CircuitClosed {
+		t.Errorf("breaker without threshold is %v, want closed", b.state())
+	}
+}
@@ -1,6 +1,9 @@
 package database
 
 import (
+	"context"
+	"time"
+
 	"github.com/apex/log"
 	"github.com/globalsign/mgo"
 	"github.com/marcospsbrito/dic/config"
@@ -16,7 +19,16 @@ func connect(dbURL string, dbName string) (*mgo.Database, error) {
 	return session.DB(dbName), nil
 }
 
-// New calls the DB initialization
+// Retry policy and circuit breaker of the operations, configured by New
+var (
+	backoff      = Backoff{Initial: 200 * time.Millisecond, Max: 5 * time.Second}
+	readAttempts = 3
+	circuit      = newBreaker(5, 10*time.Second)
+)
+
+// New calls the DB initialization, retrying with backoff while the servers
+// cannot be reached, and configures the retries and the circuit breaker of
+// the operations
 func New(config config.Config) (*mgo.Database, error) {
 	log.WithFields(
 		log.Fields{
@@ -24,5 +36,82 @@ func New(config config.Config) (*mgo.Database, error) {
 			"database": config.MongoDBName,
 		}).Info("opening database connection")
 
-	return connect(config.MongoURL, config.MongoDBName)
+	backoff = Backoff{Initial: config.MongoBackoffInitial, Max: config.MongoBackoffMax}
+	readAttempts = config.MongoReadAttempts
+	circuit = newBreaker(config.MongoBreakerThreshold, config.MongoBreakerCooldown)
+	var db *mgo.Database
+	attempt := 0
+	err := retry(context.Background(), config.MongoConnectAttempts, backoff, func(error) bool { return true }, func() (err error) {
+		attempt++
+		db, err = connect(config.MongoURL, config.MongoDBName)
+		if err != nil {
+			log.WithError(err).WithField("attempt", attempt).Warn("Cannot connect to database")
+		}
+		return err
+	})
+	return db, err
+}
+
+// Ping checks that the servers of db answer within timeout, on a copy of its
+// session so that a dead socket is not reused. Pings bypass the circuit
+// breaker, so a successful one closes it.
+func Ping(db *mgo.Database, timeout time.Duration) error {
+	s := db.Session.Copy()
+	defer s.Close()
+	s.SetSyncTimeout(timeout)
+	s.SetSocketTimeout(timeout)
+	err := s.Ping()
+	circuit.record(err)
+	return err
+}
+
+// CircuitState returns the state of the circuit breaker of the operations
+func CircuitState() string {
+	return circuit.state()
+}
+
+// Run runs op on a copy of session whose operations time out at the deadline
+// of ctx. It fails with the error of ctx when ctx is done before op starts or
+// once it failed, rather than with the error of the interrupted operation,
+// and with ErrCircuitOpen while the database is found unavailable. session is
+// refreshed when op loses its connection.
+func Run(ctx context.Context, session *mgo.Session, op func(*mgo.Session) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !circuit.allow() {
+		return ErrCircuitOpen
+	}
+	s := session.Copy()
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := time.Until(deadline)
+		s.SetSyncTimeout(timeout)
+		s.SetSocketTimeout(timeout)
+	}
+	err := op(s)
+	if err != nil && ctx.Err() != nil {
+		circuit.release()
+		return ctx.Err()
+	}
+	circuit.record(err)
+	if IsUnavailable(err) {
+		session.Refresh()
+	}
+	return err
+}
+
+// Retry runs op like Run, running it again with backoff while the database
+// is unavailable, unless the circuit is open. op must be idempotent, like
+// reads are.
+func Retry(ctx context.Context, session *mgo.Session, op func(*mgo.Session) error) error {
+	return retry(ctx, readAttempts, backoff, isRetryable, func() error {
+		return Run(ctx, session, op)
+	})
+}
+
+// isRetryable reports whether an operation failing with err may succeed when
+// run again
+func isRetryable(err error) bool {
+	return err != ErrCircuitOpen && IsUnavailable(err)
 }
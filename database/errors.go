@@ -0,0 +1,35 @@
+package database
+
+import (
+	"io"
+	"net"
+	"strings"
+)
+
+// unavailableMessages are the mgo errors of a session that lost its servers
+var unavailableMessages = []string{
+	"no reachable servers",
+	"Closed explicitly",
+	"connection reset",
+	"i/o timeout",
+}
+
+// IsUnavailable reports whether err means the database cannot be reached,
+// as opposed to rejecting an operation
+func IsUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if err == io.EOF || err == ErrCircuitOpen {
+		return true
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	for _, m := range unavailableMessages {
+		if strings.Contains(err.Error(), m) {
+			return true
+		}
+	}
+	return false
+}
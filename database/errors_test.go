@@ -0,0 +1,33 @@
+package database
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/globalsign/mgo"
+)
+
+func TestIsUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"No error", nil, false},
+		{"Not found", mgo.ErrNotFound, false},
+		{"Duplicate key", &mgo.LastError{Code: 11000, Err: "E11000 duplicate key"}, false},
+		{"No reachable servers", errors.New("no reachable servers"), true},
+		{"Closed session", errors.New("Closed explicitly"), true},
+		{"Connection lost", io.EOF, true},
+		{"Network error", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsUnavailable(tt.err); got != tt.want {
+				t.Errorf("IsUnavailable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
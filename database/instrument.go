@@ -0,0 +1,31 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+
+	"github.com/marcospsbrito/dic/metrics"
+	"github.com/marcospsbrito/dic/tracing"
+)
+
+// Instrument starts the span of an operation of repository, child of the
+// span of ctx. The returned function ends it and records the latency of the
+// operation, which failed with err. Records not found are not failures.
+func Instrument(ctx context.Context, repository string, operation string) func(err error) {
+	start := time.Now()
+	_, span := tracing.Start(ctx, repository+"."+operation,
+		semconv.DBSystemMongoDB,
+		semconv.DBOperation(operation),
+		attribute.String("repository", repository))
+	return func(err error) {
+		metrics.ObserveRepository(repository, operation, start, err)
+		if err == mgo.ErrNotFound {
+			err = nil
+		}
+		tracing.End(span, err)
+	}
+}
@@ -0,0 +1,325 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// Collections of the migrations and of their lock
+const (
+	migrationCollection = "Migration"
+	lockCollection      = "MigrationLock"
+	lockID              = "migrations"
+)
+
+// lockLease is how long a lock is held before other runners may take it
+// over, in case its runner died. Runners renew it every lockRenew while
+// they migrate.
+const lockLease = 5 * time.Minute
+
+// lockRenew is how often a runner renews the lease of its lock
+const lockRenew = lockLease / 5
+
+// lockPoll is how often a runner checks a lock held by another runner
+const lockPoll = time.Second
+
+var (
+	// ErrIrreversible is returned when a migration without Down would be
+	// reverted
+	ErrIrreversible = errors.New("migration cannot be reverted")
+	// ErrUnknownVersion is returned when the target of a migration or an
+	// applied migration is not one of the migrations
+	ErrUnknownVersion = errors.New("unknown migration version")
+)
+
+// Migration is a versioned change of the database. Up applies it and Down
+// reverts it; a migration without Down cannot be reverted. Both run on db
+// bound to a session honouring the deadline of the migration and must be
+// safe to run again after a partial failure.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(db *mgo.Database) error
+	Down        func(db *mgo.Database) error
+}
+
+// MigrationStatus is a migration and when it was applied
+type MigrationStatus struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedAt,omitempty"`
+}
+
+// Applied reports whether the migration is applied
+func (s MigrationStatus) Applied() bool {
+	return !s.AppliedAt.IsZero()
+}
+
+// step is a migration applied, or reverted when down is true
+type step struct {
+	Migration
+	down bool
+}
+
+// Migrator applies and reverts the migrations of a database, recording the
+// applied ones. A lock keeps concurrent runners, like several instances
+// starting at once, from migrating together: they wait for it instead.
+type Migrator struct {
+	db         *mgo.Database
+	migrations []Migration
+	owner      string
+}
+
+// NewMigrator returns the Migrator of migrations, which must have distinct
+// positive versions
+func NewMigrator(db *mgo.Database, migrations ...Migration) (*Migrator, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		if m.Version <= 0 || m.Up == nil {
+			return nil, fmt.Errorf("migration %d: version must be positive and Up set", m.Version)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("migration %d: duplicate version", m.Version)
+		}
+	}
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d:%s", host, os.Getpid(), bson.NewObjectId().Hex())
+	return &Migrator{db: db, migrations: sorted, owner: owner}, nil
+}
+
+// Latest returns the version of the last migration, 0 when there are none
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status returns every migration and whether it is applied, ordered by
+// version
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, mig := range m.migrations {
+		statuses[i] = MigrationStatus{Version: mig.Version, Description: mig.Description, AppliedAt: applied[mig.Version]}
+	}
+	return statuses, nil
+}
+
+// Migrate applies or reverts migrations until the database is at version
+// target, waiting for the lock while another runner holds it. It returns the
+// migrations it ran, the reverted ones not being applied, and stops at the
+// first failure.
+func (m *Migrator) Migrate(ctx context.Context, target int) (ran []MigrationStatus, err error) {
+	if err := m.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer m.unlock()
+	leased, release := m.holdLease(ctx)
+	defer release()
+	applied, err := m.applied(leased)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := plan(m.migrations, applied, target)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range steps {
+		if err := m.run(leased, s); err != nil {
+			if leased.Err() != nil {
+				err = context.Cause(leased)
+			}
+			return ran, fmt.Errorf("migration %d (%s): %v", s.Version, s.Description, err)
+		}
+		status := MigrationStatus{Version: s.Version, Description: s.Description}
+		if !s.down {
+			status.AppliedAt = time.Now()
+		}
+		ran = append(ran, status)
+	}
+	return ran, nil
+}
+
+// plan returns the steps bringing the database from its applied migrations
+// to version target: the missing migrations up to target, in order, then the
+// applied ones above target, in reverse order
+func plan(migrations []Migration, applied map[int]time.Time, target int) ([]step, error) {
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+	}
+	if target != 0 && !known[target] {
+		return nil, ErrUnknownVersion
+	}
+	for v := range applied {
+		if !known[v] {
+			return nil, fmt.Errorf("applied migration %d: %v", v, ErrUnknownVersion)
+		}
+	}
+	var steps []step
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; !ok && m.Version <= target {
+			steps = append(steps, step{Migration: m})
+		}
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; ok && m.Version > target {
+			if m.Down == nil {
+				return nil, fmt.Errorf("migration %d (%s): %v", m.Version, m.Description, ErrIrreversible)
+			}
+			steps = append(steps, step{Migration: m, down: true})
+		}
+	}
+	return steps, nil
+}
+
+// run applies or reverts s and records it, unless ctx ends meanwhile. A
+// migration cannot be stopped midway, so when the lease of the lock is lost
+// while it runs it is left unrecorded for the next runner to run again.
+func (m *Migrator) run(ctx context.Context, s step) error {
+	direction := "up"
+	if s.down {
+		direction = "down"
+	}
+	log.WithFields(log.Fields{"version": s.Version, "migration": s.Description, "direction": direction}).Info("Running migration")
+	return Run(ctx, m.db.Session, func(session *mgo.Session) error {
+		db := m.db.With(session)
+		records := db.C(migrationCollection)
+		if s.down {
+			if err := s.Down(db); err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return ignoreNotFound(records.RemoveId(s.Version))
+		}
+		if err := s.Up(db); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		_, err := records.UpsertId(s.Version, MigrationStatus{Version: s.Version, Description: s.Description, AppliedAt: time.Now()})
+		return err
+	})
+}
+
+// applied returns when each applied migration was applied, by version
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	var records []MigrationStatus
+	err := Retry(ctx, m.db.Session, func(s *mgo.Session) error {
+		return m.db.With(s).C(migrationCollection).Find(nil).All(&records)
+	})
+	applied := make(map[int]time.Time, len(records))
+	for _, r := range records {
+		applied[r.Version] = r.AppliedAt
+	}
+	return applied, err
+}
+
+// lock takes the lock of the migrations, polling until ctx is done while
+// another runner holds a lease that has not expired
+func (m *Migrator) lock(ctx context.Context) error {
+	for {
+		err := Run(ctx, m.db.Session, func(s *mgo.Session) error {
+			locks := m.db.With(s).C(lockCollection)
+			_, err := locks.Upsert(
+				bson.M{"_id": lockID, "$or": []bson.M{{"owner": m.owner}, {"expiresAt": bson.M{"$lt": time.Now()}}}},
+				bson.M{"$set": bson.M{"owner": m.owner, "expiresAt": time.Now().Add(lockLease)}},
+			)
+			return err
+		})
+		if !mgo.IsDup(err) {
+			return err
+		}
+		log.Info("Waiting for the migrations of another runner")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPoll):
+		}
+	}
+}
+
+// renew extends the lease of the lock held by m
+func (m *Migrator) renew(ctx context.Context) error {
+	return Run(ctx, m.db.Session, func(s *mgo.Session) error {
+		return m.db.With(s).C(lockCollection).Update(
+			bson.M{"_id": lockID, "owner": m.owner},
+			bson.M{"$set": bson.M{"expiresAt": time.Now().Add(lockLease)}},
+		)
+	})
+}
+
+// holdLease renews the lease of the lock held by m every lockRenew until
+// release is called. The returned context of ctx is canceled, with the error
+// of the renewal as its cause, once the lease cannot be renewed.
+func (m *Migrator) holdLease(ctx context.Context) (leased context.Context, release func()) {
+	leased, cancel := context.WithCancelCause(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lockRenew)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-leased.Done():
+				return
+			case <-ticker.C:
+				if err := m.renew(leased); err != nil {
+					log.WithError(err).Error("Cannot renew the migration lock")
+					cancel(fmt.Errorf("migration lock lost: %v", err))
+					return
+				}
+			}
+		}
+	}()
+	return leased, func() {
+		cancel(nil)
+		<-stopped
+	}
+}
+
+// unlock releases the lock held by m, even once the context of the
+// migrations is done
+func (m *Migrator) unlock() {
+	err := Run(context.Background(), m.db.Session, func(s *mgo.Session) error {
+		return m.db.With(s).C(lockCollection).Remove(bson.M{"_id": lockID, "owner": m.owner})
+	})
+	if err != nil {
+		log.WithError(err).Warn("Cannot release the migration lock")
+	}
+}
+
+// DropIndex drops the index of c with key, for the Down of migrations. It
+// succeeds when the index does not exist.
+func DropIndex(c *mgo.Collection, key ...string) error {
+	err := c.DropIndex(key...)
+	if err != nil && (strings.Contains(err.Error(), "index not found") || strings.Contains(err.Error(), "ns not found")) {
+		return nil
+	}
+	return err
+}
+
+func ignoreNotFound(err error) error {
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
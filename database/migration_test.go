@@ -0,0 +1,99 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo"
+)
+
+// testMigrations returns migrations of versions, those in irreversible having
+// no Down
+func testMigrations(versions []int, irreversible ...int) []Migration {
+	noop := func(*mgo.Database) error { return nil }
+	var migrations []Migration
+	for _, v := range versions {
+		m := Migration{Version: v, Up: noop, Down: noop}
+		for _, i := range irreversible {
+			if i == v {
+				m.Down = nil
+			}
+		}
+		migrations = append(migrations, m)
+	}
+	return migrations
+}
+
+func Test_plan(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name         string
+		irreversible []int
+		applied      []int
+		target       int
+		want         []int
+		wantErr      bool
+	}{
+		{"Fresh database", nil, nil, 3, []int{1, 2, 3}, false},
+		{"Pending migrations", nil, []int{1}, 3, []int{2, 3}, false},
+		{"Missing migration applied", nil, []int{1, 3}, 3, []int{2}, false},
+		{"Up to date", nil, []int{1, 2, 3}, 3, nil, false},
+		{"Partial target", nil, nil, 2, []int{1, 2}, false},
+		{"Rollback", nil, []int{1, 2, 3}, 1, []int{-3, -2}, false},
+		{"Rollback everything", nil, []int{1, 2, 3}, 0, []int{-3, -2, -1}, false},
+		{"Irreversible migration", []int{2}, []int{1, 2, 3}, 1, nil, true},
+		{"Irreversible migration kept", []int{1}, []int{1, 2, 3}, 1, []int{-3, -2}, false},
+		{"Unknown target", nil, nil, 4, nil, true},
+		{"Unknown applied migration", nil, []int{1, 7}, 3, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied := make(map[int]time.Time)
+			for _, v := range tt.applied {
+				applied[v] = now
+			}
+			steps, err := plan(testMigrations([]int{1, 2, 3}, tt.irreversible...), applied, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("plan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []int
+			for _, s := range steps {
+				if s.down {
+					got = append(got, -s.Version)
+				} else {
+					got = append(got, s.Version)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("plan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMigrator(t *testing.T) {
+	tests := []struct {
+		name       string
+		migrations []Migration
+		wantLatest int
+		wantErr    bool
+	}{
+		{"No migrations", nil, 0, false},
+		{"Unordered migrations", testMigrations([]int{3, 1, 2}), 3, false},
+		{"Duplicate version", testMigrations([]int{1, 2, 2}), 0, true},
+		{"Zero version", testMigrations([]int{0, 1}), 0, true},
+		{"Missing Up", []Migration{{Version: 1}}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMigrator(nil, tt.migrations...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewMigrator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && m.Latest() != tt.wantLatest {
+				t.Errorf("NewMigrator() latest = %v, want %v", m.Latest(), tt.wantLatest)
+			}
+		})
+	}
+}
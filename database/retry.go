@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// Backoff spaces the attempts of an operation: the delay after attempt n is
+// Initial doubled n times, up to Max
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// Delay returns the delay after the attempt numbered from 0
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := b.Initial
+	for i := 0; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+	if d > b.Max {
+		return b.Max
+	}
+	return d
+}
+
+// retry runs op up to attempts times, waiting the delay of backoff between
+// attempts, while it fails with an error retryable accepts. It fails with
+// the error of ctx when ctx ends while waiting.
+func retry(ctx context.Context, attempts int, backoff Backoff, retryable func(error) bool, op func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || !retryable(err) || attempt+1 >= attempts {
+			return err
+		}
+		t := time.NewTimer(backoff.Delay(attempt))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
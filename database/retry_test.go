@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second}
+	tests := []struct {
+		name    string
+		attempt int
+		want    time.Duration
+	}{
+		{"First attempt", 0, 100 * time.Millisecond},
+		{"Doubled", 2, 400 * time.Millisecond},
+		{"Capped", 4, time.Second},
+		{"Capped far away", 100, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := b.Delay(tt.attempt); got != tt.want {
+				t.Errorf("Backoff.Delay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_retry(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		errs         []error
+		wantErr      error
+		wantAttempts int
+	}{
+		{"Success", context.Background(), []error{nil}, nil, 1},
+		{"Recovers", context.Background(), []error{io.EOF, io.EOF, nil}, nil, 3},
+		{"Gives up", context.Background(), []error{io.EOF, io.EOF, io.EOF, nil}, io.EOF, 3},
+		{"Not retryable", context.Background(), []error{errMock, nil}, errMock, 1},
+		{"Circuit open", context.Background(), []error{ErrCircuitOpen, nil}, ErrCircuitOpen, 1},
+		{"Context ends while waiting", canceled, []error{io.EOF, nil}, context.Canceled, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := retry(tt.ctx, 3, Backoff{Initial: time.Millisecond, Max: time.Millisecond}, isRetryable, func() error {
+				attempts++
+				return tt.errs[attempts-1]
+			})
+			if err != tt.wantErr || attempts != tt.wantAttempts {
+				t.Errorf("retry() = %v after %v attempts, want %v after %v", err, attempts, tt.wantErr, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+var errMock = errors.New("mock error")
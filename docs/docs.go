@@ -57,43 +57,645 @@ var doc = `{
                         "description": "Bad Request",
                         "schema": {
                             "type": "object",
-                            "$ref": "#/definitions/httputil.HTTPError"
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "OAuth2Application": [
+                            "read"
+                        ]
+                    }
+                ]
+            }
+        },
+        "/companies/websites": {
+            "post": {
+                "description": "post website file to merge with companies. Uploads with the same content or Idempotency-Key are processed only once.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Load a csv file with websites to merge with companies data",
+                "operationId": "post-load-websites",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "File to import, optionally gzip, bzip2 or zstd compressed, or a zip archive of files",
+                        "name": "data",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the upload",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Commit all rows or none of them",
+                        "name": "atomic",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "websites",
+                        "description": "Source of the file selecting its validation rules",
+                        "name": "source",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "File format (csv, json, ndjson or xlsx), detected from the file when empty",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Worksheet of xlsx files, the first one when empty",
+                        "name": "sheet",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Charset of text files (e.g. utf-8, iso-8859-1, windows-1252), detected when empty",
+                        "name": "charset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Delimiter of csv files: a character or comma, semicolon, tab or pipe, detected when empty",
+                        "name": "delimiter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comment character of csv files or none, detected when empty",
+                        "name": "comment",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Whether the first row of csv files is a header, detected when empty",
+                        "name": "header",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/company.Upload"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "OAuth2Application": [
+                            "write"
+                        ]
+                    }
+                ]
+            }
+        },
+        "/keys": {
+            "get": {
+                "description": "get every API key of the tenant of the caller, without their secrets",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List API keys",
+                "operationId": "get-keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/auth.Key"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "OAuth2Application": [
+                            "admin"
+                        ]
+                    }
+                ]
+            },
+            "post": {
+                "description": "create an API key of the tenant of the caller with a role. The secret is only returned in this response.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create an API key",
+                "operationId": "post-keys",
+                "parameters": [
+                    {
+                        "description": "Key",
+                        "name": "key",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/auth.CreateKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/auth.CreatedKey"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "OAuth2Application": [
+                            "admin"
+                        ]
+                    }
+                ]
+            }
+        },
+        "/keys/{id}": {
+            "delete": {
+                "description": "revoke an API key of the tenant of the caller, which is rejected from then on",
+                "summary": "Revoke an API key",
+                "operationId": "delete-key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
                             "type": "object",
-                            "$ref": "#/definitions/httputil.HTTPError"
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "OAuth2Application": [
+                            "admin"
+                        ]
+                    }
+                ]
+            }
+        },
+        "/tenants": {
+            "get": {
+                "description": "get every tenant. Only admins of the default tenant manage tenants.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List tenants",
+                "operationId": "get-tenants",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/auth.Tenant"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "OAuth2Application": [
+                            "admin"
+                        ]
+                    }
+                ]
+            },
+            "post": {
+                "description": "create a tenant and its first admin key, whose secret is only returned in this response. Only admins of the default tenant manage tenants.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create a tenant",
+                "operationId": "post-tenants",
+                "parameters": [
+                    {
+                        "description": "Tenant",
+                        "name": "tenant",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/auth.CreateTenantRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/auth.CreatedTenant"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
                             "type": "object",
-                            "$ref": "#/definitions/httputil.HTTPError"
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
                         }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "OAuth2Application": [
+                            "admin"
+                        ]
+                    }
+                ]
             }
         },
-        "/companies/websites": {
+        "/tenants/{id}/catalog": {
             "post": {
-                "description": "post website file to merge with companies",
-                "consumes": [
-                    "multipart/form-data"
-                ],
+                "description": "add the companies of tenant from that are missing in tenant id. Only admins of the default tenant manage tenants.",
                 "produces": [
-                    "text/plain"
+                    "application/json"
                 ],
-                "summary": "Load a csv file with websites to merge with companies data",
-                "operationId": "post-load-websites",
-								"parameters": [
+                "summary": "Copy a catalog between tenants",
+                "operationId": "post-tenant-catalog",
+                "parameters": [
                     {
-                        "type": "file",
-                        "description": "CSV File",
-                        "name": "data",
-                        "in": "formData",
+                        "type": "string",
+                        "description": "Target tenant",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Source tenant",
+                        "name": "from",
+                        "in": "query",
                         "required": true
                     }
                 ],
@@ -101,35 +703,239 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "type": "object",
+                            "$ref": "#/definitions/company.CopyResult"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
                             "type": "object",
-                            "$ref": "#/definitions/httputil.HTTPError"
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
                             "type": "object",
-                            "$ref": "#/definitions/httputil.HTTPError"
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
                             "type": "object",
-                            "$ref": "#/definitions/httputil.HTTPError"
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/apierror.Error"
                         }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "OAuth2Application": [
+                            "admin"
+                        ]
+                    }
+                ]
             }
         }
     },
     "definitions": {
+        "apierror.Error": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "company.not_found"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Company not found"
+                },
+                "requestId": {
+                    "type": "string",
+                    "example": "4f6c2a1be0d94c3e"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 404
+                }
+            }
+        },
+        "auth.CreateKeyRequest": {
+            "type": "object",
+            "required": [
+                "name",
+                "role"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "pipeline"
+                },
+                "role": {
+                    "type": "string",
+                    "example": "importer"
+                }
+            }
+        },
+        "auth.CreateTenantRequest": {
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "example": "sales"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Sales"
+                }
+            }
+        },
+        "auth.CreatedKey": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "5c8a1d5b0190b214360dc031"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "pipeline"
+                },
+                "prefix": {
+                    "type": "string",
+                    "example": "dic_Xb3k"
+                },
+                "revoked": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "role": {
+                    "type": "string",
+                    "example": "importer"
+                },
+                "secret": {
+                    "type": "string",
+                    "example": "dic_Xb3kVtq2yH0S4Yz8T9cI3m5o7n1p6r2u8w0e4a6d8f0"
+                },
+                "tenant": {
+                    "type": "string",
+                    "example": "sales"
+                }
+            }
+        },
+        "auth.CreatedTenant": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "sales"
+                },
+                "key": {
+                    "type": "object",
+                    "$ref": "#/definitions/auth.CreatedKey"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Sales"
+                }
+            }
+        },
+        "auth.Key": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "5c8a1d5b0190b214360dc031"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "pipeline"
+                },
+                "prefix": {
+                    "type": "string",
+                    "example": "dic_Xb3k"
+                },
+                "revoked": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "role": {
+                    "type": "string",
+                    "example": "importer"
+                },
+                "tenant": {
+                    "type": "string",
+                    "example": "sales"
+                }
+            }
+        },
+        "auth.Tenant": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "sales"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Sales"
+                }
+            }
+        },
         "company.Company": {
             "type": "object",
             "properties": {
@@ -151,19 +957,142 @@ var doc = `{
                 }
             }
         },
-        "httputil.HTTPError": {
+        "company.CopyResult": {
             "type": "object",
             "properties": {
-                "code": {
+                "copied": {
                     "type": "integer",
-                    "example": 400
+                    "example": 42
                 },
-                "message": {
+                "from": {
+                    "type": "string",
+                    "example": "default"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "sales"
+                }
+            }
+        },
+        "company.Rejection": {
+            "type": "object",
+            "properties": {
+                "line": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "Invalid Zipcode lenght"
+                },
+                "rule": {
+                    "type": "string",
+                    "example": "zipcode.invalid"
+                }
+            }
+        },
+        "company.Report": {
+            "type": "object",
+            "properties": {
+                "merged": {
+                    "type": "integer",
+                    "example": 18
+                },
+                "rejected": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/company.Rejection"
+                    }
+                },
+                "rows": {
+                    "type": "integer",
+                    "example": 20
+                },
+                "atomic": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "rolledBack": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "file": {
+                    "type": "string",
+                    "example": "clients.csv"
+                },
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/company.Report"
+                    }
+                },
+                "skipped": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "removed": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "unchanged": {
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "company.Upload": {
+            "type": "object",
+            "properties": {
+                "checkpoint": {
+                    "type": "integer",
+                    "example": 500
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "createdBy": {
+                    "type": "string",
+                    "example": "jwt:248289761001"
+                },
+                "hash": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "5c8a1d5b0190b214360dc031"
+                },
+                "idempotencyKey": {
+                    "type": "string",
+                    "example": "3f1c6b2e"
+                },
+                "report": {
+                    "type": "object",
+                    "$ref": "#/definitions/company.Report"
+                },
+                "status": {
                     "type": "string",
-                    "example": "status bad request"
+                    "example": "done"
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "type": "apiKey",
+            "in": "header",
+            "name": "Authorization"
+        },
+        "OAuth2Application": {
+            "type": "oauth2",
+            "flow": "application",
+            "tokenUrl": "https://example.com/oauth/token",
+            "scopes": {
+                "admin": "Grants read and write access to administrative information",
+                "read": "Grants read access",
+                "write": "Grants write access"
+            }
+        }
     }
 }`
 
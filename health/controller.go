@@ -0,0 +1,46 @@
+package health
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultTimeout bounds the checks of a readiness probe
+const DefaultTimeout = 2 * time.Second
+
+// Controller defines methods to a Controller
+type Controller interface {
+	Liveness(ctx *gin.Context)
+	Readiness(ctx *gin.Context)
+}
+
+type healthController struct {
+	checks  map[string]Checker
+	timeout time.Duration
+}
+
+// NewController returns a Controller whose readiness probe runs checks
+func NewController(checks map[string]Checker, timeout time.Duration) Controller {
+	return healthController{checks, timeout}
+}
+
+// Liveness answers 200 while the process serves requests, without checking
+// its dependencies
+func (c healthController) Liveness(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, Report{Status: StatusUp})
+}
+
+// Readiness answers 200 when every component is up and 503 otherwise, with
+// the result of the check of each component
+func (c healthController) Readiness(ctx *gin.Context) {
+	report := Run(c.checks, c.timeout)
+	if report.Status != StatusUp {
+		log.WithField("components", report.Components).Warn("Not ready")
+		ctx.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
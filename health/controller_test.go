@@ -0,0 +1,39 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Test_healthController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	up := func() (interface{}, error) { return nil, nil }
+	down := func() (interface{}, error) { return nil, errors.New("Catalog load in progress") }
+	tests := []struct {
+		name   string
+		path   string
+		checks map[string]Checker
+		want   int
+	}{
+		{"Alive with a component down", "/healthz", map[string]Checker{"catalog": down}, http.StatusOK},
+		{"Ready", "/readyz", map[string]Checker{"catalog": up}, http.StatusOK},
+		{"Not ready", "/readyz", map[string]Checker{"catalog": down, "database": up}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewController(tt.checks, DefaultTimeout)
+			r := gin.New()
+			r.GET("/healthz", c.Liveness)
+			r.GET("/readyz", c.Readiness)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.want {
+				t.Errorf("%v status = %v, want %v", tt.path, w.Code, tt.want)
+			}
+		})
+	}
+}
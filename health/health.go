@@ -0,0 +1,96 @@
+package health
+
+import (
+	"errors"
+	"time"
+)
+
+// Statuses of the application and of its components
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// ErrTimeout is reported by checks that do not complete within the timeout
+var ErrTimeout = errors.New("Check timed out")
+
+// Checker checks a component, returning details about its state and an
+// error when it cannot serve requests
+type Checker func() (interface{}, error)
+
+// Component is the result of the check of a component
+type Component struct {
+	Status     string      `json:"status" example:"up"`
+	DurationMs float64     `json:"durationMs" example:"1.25"`
+	Error      string      `json:"error,omitempty" example:"no reachable servers"`
+	Details    interface{} `json:"details,omitempty"`
+}
+
+// Report is the health of the application, which is up when all of its
+// components are
+type Report struct {
+	Status     string               `json:"status" example:"up"`
+	Components map[string]Component `json:"components,omitempty"`
+}
+
+// Run checks every component at once, giving up on the ones that take longer
+// than timeout
+func Run(checks map[string]Checker, timeout time.Duration) Report {
+	type result struct {
+		name      string
+		component Component
+	}
+	results := make(chan result, len(checks))
+	for name, check := range checks {
+		go func(name string, check Checker) {
+			results <- result{name, run(check)}
+		}(name, check)
+	}
+	report := Report{Status: StatusUp, Components: make(map[string]Component, len(checks))}
+	deadline := time.After(timeout)
+	for range checks {
+		select {
+		case r := <-results:
+			report.Components[r.name] = r.component
+		case <-deadline:
+			for _, name := range pending(checks, report.Components) {
+				report.Components[name] = Component{Status: StatusDown, DurationMs: milliseconds(timeout), Error: ErrTimeout.Error()}
+			}
+		}
+		if len(report.Components) == len(checks) {
+			break
+		}
+	}
+	for _, c := range report.Components {
+		if c.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func run(check Checker) Component {
+	start := time.Now()
+	details, err := check()
+	c := Component{Status: StatusUp, DurationMs: milliseconds(time.Since(start)), Details: details}
+	if err != nil {
+		c.Status = StatusDown
+		c.Error = err.Error()
+	}
+	return c
+}
+
+// pending returns the names of the checks without a result
+func pending(checks map[string]Checker, done map[string]Component) []string {
+	var names []string
+	for name := range checks {
+		if _, ok := done[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,43 @@
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	up := func() (interface{}, error) { return "details", nil }
+	down := func() (interface{}, error) { return nil, errors.New("no reachable servers") }
+	slow := func() (interface{}, error) {
+		time.Sleep(time.Second)
+		return nil, nil
+	}
+	tests := []struct {
+		name   string
+		checks map[string]Checker
+		want   string
+		errors map[string]string
+	}{
+		{"No components", nil, StatusUp, nil},
+		{"Every component up", map[string]Checker{"database": up, "catalog": up}, StatusUp, map[string]string{"database": "", "catalog": ""}},
+		{"A component down", map[string]Checker{"database": down, "catalog": up}, StatusDown, map[string]string{"database": "no reachable servers", "catalog": ""}},
+		{"A component timed out", map[string]Checker{"database": slow, "catalog": up}, StatusDown, map[string]string{"database": ErrTimeout.Error(), "catalog": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Run(tt.checks, 50*time.Millisecond)
+			if got.Status != tt.want {
+				t.Errorf("Run() status = %v, want %v", got.Status, tt.want)
+			}
+			if len(got.Components) != len(tt.errors) {
+				t.Errorf("Run() components = %v, want %v", got.Components, tt.errors)
+			}
+			for name, want := range tt.errors {
+				if c := got.Components[name]; c.Error != want {
+					t.Errorf("Run() component %v error = %q, want %q", name, c.Error, want)
+				}
+			}
+		})
+	}
+}
@@ -1,17 +1,32 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/gin-gonic/gin"
+	"github.com/globalsign/mgo"
 
 	ginSwagger "github.com/swaggo/gin-swagger"
 	swaggerFiles "github.com/swaggo/gin-swagger/swaggerFiles"
 
+	"github.com/marcospsbrito/dic/apierror"
+	"github.com/marcospsbrito/dic/auth"
 	"github.com/marcospsbrito/dic/company"
 	"github.com/marcospsbrito/dic/config"
 	"github.com/marcospsbrito/dic/database"
+	"github.com/marcospsbrito/dic/health"
+	"github.com/marcospsbrito/dic/metrics"
+	"github.com/marcospsbrito/dic/ratelimit"
+	"github.com/marcospsbrito/dic/tracing"
 
 	"github.com/marcospsbrito/dic/docs"
 )
@@ -39,6 +54,7 @@ import (
 
 // @securitydefinitions.oauth2.application OAuth2Application
 // @tokenUrl https://example.com/oauth/token
+// @scope.read Grants read access
 // @scope.write Grants write access
 // @scope.admin Grants read and write access to administrative information
 
@@ -59,41 +75,328 @@ import (
 // @scope.admin Grants read and write access to administrative information
 
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
 
+// serve runs the API until SIGINT or SIGTERM, then shuts it down gracefully
+func serve(inv invocation) error {
+	cfg := inv.cfg
 	docs.SwaggerInfo.Title = "Swagger Company API"
 	docs.SwaggerInfo.Version = "1.0"
 	docs.SwaggerInfo.BasePath = "/api/v1"
 
-	cfg := config.Get()
-	log.SetLevelFromString(cfg.LogLevel)
-	db, err := database.New(cfg)
+	go reloadOnHangup(inv.flags, cfg)
+	shutdownTracing, err := tracing.Setup(tracing.Config{
+		Exporter:    cfg.TraceExporter,
+		Endpoint:    cfg.TraceEndpoint,
+		ServiceName: "dic",
+		SampleRatio: cfg.TraceSampleRatio,
+	})
 	if err != nil {
-		log.Error("Failed to start application")
-		return
+		return fmt.Errorf("configuring tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return fmt.Errorf("opening database: %v", err)
+	}
+	defer closeDatabase(db)
+	if err := migrateOnStartup(cfg, db); err != nil {
+		return fmt.Errorf("migrating database: %v", err)
+	}
+	repo, keyRepo := newRepositories(db)
+	keys := auth.NewService(keyRepo)
+	if err := keys.EnsureTenant(context.Background(), auth.DefaultTenant, "Default"); err != nil {
+		return fmt.Errorf("creating default tenant: %v", err)
+	}
+	if cfg.AdminAPIKey != "" {
+		if err := keys.EnsureKey(context.Background(), "admin", cfg.AdminAPIKey, auth.RoleAdmin); err != nil {
+			return fmt.Errorf("creating admin API key: %v", err)
+		}
+	}
+	s, err := newService(cfg, repo)
+	if err != nil {
+		return err
+	}
+	if err := s.RecoverImports(context.Background()); err != nil {
+		return fmt.Errorf("recovering imports: %v", err)
 	}
-	repo := company.NewRepository(db)
-	s := company.NewService(repo)
 	c := company.NewController(s)
 
-	docs.SwaggerInfo.Title = "Swagger Company API"
-	c.InitDatabase(cfg.InitFile)
+	// jobs is the context of the requests and the catalog load, canceled
+	// when shutdown cannot wait for them any longer
+	jobs, interrupt := context.WithCancel(context.Background())
+	defer interrupt()
+	if cfg.CatalogRequired {
+		if err := c.InitDatabase(jobs, cfg.InitFile); err != nil {
+			return fmt.Errorf("loading catalog: %v", err)
+		}
+	} else {
+		go c.InitDatabase(jobs, cfg.InitFile)
+	}
 	r := gin.Default()
+	r.Use(tracing.Middleware(metrics.Route(r)), apierror.RequestID(), metrics.Middleware(r))
+	g, err := newGuards(cfg, keys)
+	if err != nil {
+		return fmt.Errorf("loading JSON Web Key Set: %v", err)
+	}
+	l, err := newLimiter(cfg, db)
+	if err != nil {
+		return fmt.Errorf("configuring rate limits: %v", err)
+	}
+	probes := health.NewController(newChecks(db, c), health.DefaultTimeout)
+	r.GET("/healthz", probes.Liveness)
+	r.GET("/readyz", probes.Readiness)
+	r.GET("/metrics", metrics.Handler())
 	v1 := r.Group(docs.SwaggerInfo.BasePath)
 	{
-		companies := v1.Group("/companies")
+		companies := v1.Group("/companies", l.LimitIP("authenticate"), g.authenticate)
 		{
-			companies.GET("", c.Find)
-			companies.POST("/websites", c.LoadWebsites)
+			companies.GET("", g.require(auth.RoleReader), l.Limit("GET /companies"),
+				l.Quota("export", cfg.ExportDailyQuota, company.IsExport), c.Find)
+			companies.POST("/websites", g.require(auth.RoleImporter), l.Limit("POST /companies/websites"),
+				l.Quota("import", cfg.ImportDailyQuota, nil), company.LimitUploadSize(cfg.UploadMaxBytes), c.LoadWebsites)
 		}
-		health := v1.Group("/healthcheck")
+		k := auth.NewController(keys)
+		admin := v1.Group("/keys", l.LimitIP("authenticate"), g.authenticate, g.require(auth.RoleAdmin), l.Limit("/keys"))
 		{
-			health.GET("/", healthcheck)
+			admin.POST("", k.CreateKey)
+			admin.GET("", k.ListKeys)
+			admin.DELETE("/:id", k.RevokeKey)
 		}
+		tenants := v1.Group("/tenants", l.LimitIP("authenticate"), g.authenticate, g.require(auth.RoleAdmin), g.requireTenant(auth.DefaultTenant), l.Limit("/tenants"))
+		{
+			tenants.POST("", k.CreateTenant)
+			tenants.GET("", k.ListTenants)
+			tenants.POST("/:id/catalog", auth.TenantExists(keys), c.CopyCatalog)
+		}
+		v1.GET("/healthcheck/", probes.Liveness)
 	}
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-	r.Run(cfg.Adress)
+	srv := &http.Server{
+		Addr:        cfg.Address,
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return jobs },
+	}
+	served := make(chan error, 1)
+	go func() {
+		log.WithField("address", cfg.Address).Info("Listening")
+		served <- srv.ListenAndServe()
+	}()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-served:
+		return err
+	case sig := <-signals:
+		log.WithField("signal", sig.String()).Info("Shutting down")
+		shutdown(srv, c, interrupt, cfg.ShutdownTimeout)
+	}
+	return nil
+}
+
+// reloadOnHangup loads the configuration again on SIGHUP and applies its
+// reloadable settings, warning about the changed ones that need a restart
+func reloadOnHangup(flags config.Flags, cfg config.Config) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	for range hangups {
+		next, err := config.Load(flags)
+		if err != nil {
+			log.WithError(err).Error("Failed to reload configuration")
+			continue
+		}
+		var pending []string
+		cfg, pending = config.Reload(cfg, next)
+		log.SetLevelFromString(cfg.LogLevel)
+		if len(pending) > 0 {
+			log.WithField("settings", strings.Join(pending, ",")).Warn("Changed settings apply after a restart")
+		}
+		log.WithField("log_level", cfg.LogLevel).Info("Reloaded configuration")
+	}
+}
+
+// checkpointTimeout is how long interrupted imports are given to save their
+// checkpoint
+const checkpointTimeout = 5 * time.Second
+
+// shutdown stops accepting requests and waits up to timeout for the ones in
+// flight and the running imports. Those still running then are interrupted,
+// which makes imports save their checkpoint so their retry resumes them.
+func shutdown(srv *http.Server, c company.Controller, interrupt context.CancelFunc, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := srv.Shutdown(ctx)
+	if err == nil {
+		err = c.Drain(ctx)
+	}
+	if err == nil {
+		log.Info("Drained requests and imports")
+		return
+	}
+	log.WithError(err).Warn("Interrupting requests and imports")
+	interrupt()
+	ctx, cancel = context.WithTimeout(context.Background(), checkpointTimeout)
+	defer cancel()
+	if err := c.Drain(ctx); err != nil {
+		log.WithError(err).Error("Imports did not save their checkpoint")
+	}
+	srv.Close()
+}
+
+// newService returns the company Service of cfg over repo
+func newService(cfg config.Config, repo company.Repository) (company.Service, error) {
+	rules, err := company.LoadRules(cfg.RulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading validation rules: %v", err)
+	}
+	return company.NewService(repo, company.ImportConfig{
+		MaxErrorRate: cfg.ImportMaxErrorRate,
+		Rules:        rules,
+		Workers:      cfg.ImportWorkers,
+		BatchSize:    cfg.ImportBatchSize,
+		ParseWorkers: cfg.ImportParseWorkers,
+		MatchWorkers: cfg.ImportMatchWorkers,
+	}, company.Timeouts{
+		Search: cfg.SearchTimeout,
+		Export: cfg.ExportTimeout,
+		Import: cfg.ImportTimeout,
+		Copy:   cfg.CopyTimeout,
+	}), nil
+}
+
+// openDatabase returns the database of the configured storage, or nil when
+// the embedded memory storage is used
+func openDatabase(cfg config.Config) (*mgo.Database, error) {
+	if cfg.Storage == "memory" {
+		log.Info("using embedded memory storage")
+		return nil, nil
+	}
+	return database.New(cfg)
+}
+
+// migrations returns the migrations of the database, of every package
+func migrations() []database.Migration {
+	ms := append([]database.Migration(nil), company.Migrations...)
+	ms = append(ms, auth.Migrations...)
+	return append(ms, ratelimit.Migrations...)
+}
+
+// migrateOnStartup applies the pending migrations of db, when one is used,
+// unless cfg disables it, in which case it only warns about them
+func migrateOnStartup(cfg config.Config, db *mgo.Database) error {
+	if db == nil {
+		return nil
+	}
+	m, err := database.NewMigrator(db, migrations()...)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := company.WithTimeout(context.Background(), cfg.MigrationTimeout)
+	defer cancel()
+	if !cfg.MigrateOnStartup {
+		statuses, err := m.Status(ctx)
+		for _, s := range statuses {
+			if !s.Applied() {
+				log.WithFields(log.Fields{"version": s.Version, "migration": s.Description}).Warn("Pending migration, run dic migrate")
+			}
+		}
+		return err
+	}
+	ran, err := m.Migrate(ctx, m.Latest())
+	if len(ran) > 0 {
+		log.WithField("migrations", len(ran)).Info("Migrated database")
+	}
+	return err
+}
+
+// closeDatabase closes the session of db, when one is used
+func closeDatabase(db *mgo.Database) {
+	if db != nil {
+		db.Session.Close()
+		log.Info("Closed database connection")
+	}
+}
+
+// newRepositories returns the company and API key Repositories of db, or
+// embedded ones when db is nil, recording the latency of their operations
+func newRepositories(db *mgo.Database) (company.Repository, auth.Repository) {
+	if db == nil {
+		return company.NewInstrumentedRepository(company.NewMemoryRepository()), auth.NewInstrumentedRepository(auth.NewMemoryRepository())
+	}
+	return company.NewInstrumentedRepository(company.NewRepository(db)), auth.NewInstrumentedRepository(auth.NewRepository(db))
+}
+
+// newLimiter returns the Limiter of cfg, sharing its state through db when
+// the mongo store is configured, or nil when rate limiting is disabled
+func newLimiter(cfg config.Config, db *mgo.Database) (*ratelimit.Limiter, error) {
+	if !cfg.RateLimitEnabled {
+		log.Warn("Rate limiting is disabled")
+		return nil, nil
+	}
+	limit, err := ratelimit.ParseLimit(cfg.RateLimit)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := ratelimit.ParseRoutes(cfg.RateLimitRoutes)
+	if err != nil {
+		return nil, err
+	}
+	store := ratelimit.NewMemoryStore()
+	if cfg.RateLimitStore == "mongo" && db != nil {
+		store = ratelimit.NewMongoStore(db)
+	}
+	return ratelimit.NewLimiter(store, limit, routes), nil
 }
 
-func healthcheck(ctx *gin.Context) {
-	ctx.JSON(http.StatusOK, "OK")
+// guards are the authentication and authorization middlewares of the routes
+type guards struct {
+	authenticate  gin.HandlerFunc
+	require       func(auth.Role) gin.HandlerFunc
+	requireTenant func(string) gin.HandlerFunc
+}
+
+// newGuards returns the guards of cfg, which let every request through when
+// auth is disabled. Bearer tokens are accepted when a JWKS is configured.
+func newGuards(cfg config.Config, keys auth.Service) (guards, error) {
+	if !cfg.AuthEnabled {
+		log.Warn("API authentication is disabled")
+		pass := func(ctx *gin.Context) { ctx.Next() }
+		return guards{
+			authenticate:  pass,
+			require:       func(auth.Role) gin.HandlerFunc { return pass },
+			requireTenant: func(string) gin.HandlerFunc { return pass },
+		}, nil
+	}
+	var verifier auth.TokenVerifier
+	if cfg.JWKS != "" {
+		var err error
+		verifier, err = auth.NewTokenVerifier(auth.JWTConfig{
+			JWKS:        cfg.JWKS,
+			Issuer:      cfg.JWTIssuer,
+			Audience:    cfg.JWTAudience,
+			TenantClaim: cfg.JWTTenantClaim,
+		})
+		if err != nil {
+			return guards{}, err
+		}
+	}
+	return guards{auth.Authenticate(keys, verifier), auth.Require, auth.RequireTenant}, nil
+}
+
+// newChecks returns the checks of the readiness probe: the database and its
+// circuit breaker, when one is used, the initial load of the catalog and the
+// import workers
+func newChecks(db *mgo.Database, c company.Controller) map[string]health.Checker {
+	checks := map[string]health.Checker{
+		"catalog": c.CheckCatalog,
+		"imports": c.CheckImports,
+	}
+	if db != nil {
+		checks["database"] = func() (interface{}, error) {
+			err := database.Ping(db, health.DefaultTimeout)
+			return map[string]string{"circuit": database.CircuitState()}, err
+		}
+	}
+	return checks
 }
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "dic"
+
+var (
+	// HTTPRequests counts the requests answered, by route and status
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests answered, by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPDuration observes the time taken to answer requests
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to answer HTTP requests, by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// Imports counts the import jobs, by source and final status
+	Imports = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "imports_total",
+		Help:      "Import jobs, by source and status (done, failed or replayed).",
+	}, []string{"source", "status"})
+
+	// ImportRows counts the rows of imports, by source and outcome
+	ImportRows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "import_rows_total",
+		Help:      "Rows of imports, by source and outcome (read, merged or rejected).",
+	}, []string{"source", "outcome"})
+
+	// ImportRejections counts the rejected rows of imports, by the rule
+	// that rejected them
+	ImportRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "import_rejections_total",
+		Help:      "Rejected rows of imports, by source and rule, none for unreadable rows.",
+	}, []string{"source", "rule"})
+
+	// MatchScore observes how closely matched companies resemble the
+	// searched name, from 0 to 1
+	MatchScore = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "match_score",
+		Help:      "Share of the words of searched names found in the matched company.",
+		Buckets:   prometheus.LinearBuckets(0.1, 0.1, 10),
+	}, []string{"operation"})
+
+	// RepositoryDuration observes the latency of repository operations
+	RepositoryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repository_operation_duration_seconds",
+		Help:      "Latency of repository operations, by repository, operation and result (ok, not_found or error).",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"repository", "operation", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(HTTPRequests, HTTPDuration, Imports, ImportRows, ImportRejections, MatchScore, RepositoryDuration)
+}
+
+// ObserveRepository records the latency of the operation of repository
+// started at start, which failed with err
+func ObserveRepository(repository string, operation string, start time.Time, err error) {
+	RepositoryDuration.WithLabelValues(repository, operation, result(err)).Observe(time.Since(start).Seconds())
+}
+
+func result(err error) string {
+	switch err {
+	case nil:
+		return "ok"
+	case mgo.ErrNotFound:
+		return "not_found"
+	}
+	return "error"
+}
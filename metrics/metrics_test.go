@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/globalsign/mgo"
+)
+
+// exposed returns the value of series in the body of the metrics handler of
+// r, 0 when it is not exposed yet. Tests compare it before and after, since
+// the metrics are registered once for the whole process.
+func exposed(t *testing.T, r http.Handler, series string) float64 {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, series+" ") {
+			v, err := strconv.ParseFloat(strings.TrimPrefix(line, series+" "), 64)
+			if err != nil {
+				t.Fatalf("Handler() exposes %v", line)
+			}
+			return v
+		}
+	}
+	return 0
+}
+
+func TestObserveRepository(t *testing.T) {
+	r := gin.New()
+	r.GET("/metrics", Handler())
+	tests := []struct {
+		name   string
+		err    error
+		result string
+	}{
+		{"Operation succeeded", nil, "ok"},
+		{"Record not found", mgo.ErrNotFound, "not_found"},
+		{"Operation failed", errors.New("mock error"), "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series := `dic_repository_operation_duration_seconds_count{operation="FindAll",repository="test",result="` + tt.result + `"}`
+			before := exposed(t, r, series)
+			ObserveRepository("test", "FindAll", time.Now(), tt.err)
+			if got := exposed(t, r, series) - before; got != 1 {
+				t.Errorf("ObserveRepository() recorded %v operations of %v, want 1", got, series)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute labels the requests that match no route
+const unmatchedRoute = "unmatched"
+
+// Middleware records the requests to the routes of r. Routes are labelled by
+// their path pattern rather than the requested path, to keep the number of
+// series bounded.
+func Middleware(r *gin.Engine) gin.HandlerFunc {
+	route := Route(r)
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+		name, status := route(ctx), strconv.Itoa(ctx.Writer.Status())
+		HTTPRequests.WithLabelValues(ctx.Request.Method, name, status).Inc()
+		HTTPDuration.WithLabelValues(ctx.Request.Method, name, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Route returns a function naming the route of the requests to r by its path
+// pattern, or unmatched. Routes are read on the first request, once every
+// route is registered.
+func Route(r *gin.Engine) func(ctx *gin.Context) string {
+	var once sync.Once
+	var routes map[string]string
+	return func(ctx *gin.Context) string {
+		once.Do(func() { routes = routePatterns(r) })
+		if route, ok := routes[ctx.Request.Method+" "+ctx.HandlerName()]; ok {
+			return route
+		}
+		return unmatchedRoute
+	}
+}
+
+// routePatterns maps the method and the handler of each route of r to its
+// path. A handler serving several routes is labelled with the first one.
+func routePatterns(r *gin.Engine) map[string]string {
+	routes := make(map[string]string)
+	for _, route := range r.Routes() {
+		key := route.Method + " " + route.Handler
+		if _, ok := routes[key]; !ok {
+			routes[key] = route.Path
+		}
+	}
+	return routes
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() gin.HandlerFunc {
+	h := promhttp.Handler()
+	return func(ctx *gin.Context) {
+		h.ServeHTTP(ctx.Writer, ctx.Request)
+	}
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware(r))
+	r.GET("/keys/:id", func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+	r.GET("/metrics", Handler())
+	tests := []struct {
+		name   string
+		path   string
+		route  string
+		status string
+	}{
+		{"Route with a parameter", "/keys/5c8a", "/keys/:id", "204"},
+		{"Other value of the parameter", "/keys/5c8b", "/keys/:id", "204"},
+		{"Unknown route", "/unknown", unmatchedRoute, "404"},
+	}
+	series := `dic_http_requests_total{method="GET",route="/keys/:id",status="204"}`
+	before := exposed(t, r, series)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := HTTPRequests.WithLabelValues(http.MethodGet, tt.route, tt.status)
+			before := testutil.ToFloat64(counter)
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Errorf("Middleware() counted %v requests of route %v, want 1", got, tt.route)
+			}
+		})
+	}
+	if got := exposed(t, r, series) - before; got != 2 {
+		t.Errorf("Handler() exposes %v new requests of /keys/:id, want 2", got)
+	}
+}
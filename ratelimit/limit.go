@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket refilled at Rate tokens per second that holds at
+// most Burst tokens
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+var limitUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+}
+
+// ParseLimit parses limits like "10/s", "100/m:20" or "1000/h". The burst
+// after the colon defaults to the number of requests.
+func ParseLimit(s string) (Limit, error) {
+	spec, burst := s, ""
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		spec, burst = s[:i], s[i+1:]
+	}
+	parts := strings.SplitN(spec, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("Invalid limit %q, use <requests>/<s|m|h|d>[:burst]", s)
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	unit, ok := limitUnits[strings.TrimSpace(parts[1])]
+	if err != nil || !ok || requests <= 0 {
+		return Limit{}, fmt.Errorf("Invalid limit %q, use <requests>/<s|m|h|d>[:burst]", s)
+	}
+	l := Limit{Rate: float64(requests) / unit.Seconds(), Burst: requests}
+	if burst != "" {
+		if l.Burst, err = strconv.Atoi(strings.TrimSpace(burst)); err != nil || l.Burst <= 0 {
+			return Limit{}, fmt.Errorf("Invalid burst of limit %q", s)
+		}
+	}
+	return l, nil
+}
+
+// ParseRoutes parses the limits of routes separated by semicolons, like
+// "GET /companies=5/s:10;POST /companies/websites=10/m"
+func ParseRoutes(s string) (map[string]Limit, error) {
+	routes := make(map[string]Limit)
+	for _, entry := range strings.Split(s, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		i := strings.LastIndexByte(entry, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("Invalid route limit %q, use <METHOD> <path>=<limit>", entry)
+		}
+		l, err := ParseLimit(entry[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		routes[strings.Join(strings.Fields(entry[:i]), " ")] = l
+	}
+	return routes, nil
+}
+
+// fill returns the tokens of a bucket that had tokens elapsed ago
+func (l Limit) fill(tokens float64, elapsed time.Duration) float64 {
+	return math.Min(float64(l.Burst), tokens+elapsed.Seconds()*l.Rate)
+}
+
+// wait returns the time until a bucket with tokens holds want tokens
+func (l Limit) wait(tokens float64, want float64) time.Duration {
+	if tokens >= want {
+		return 0
+	}
+	return time.Duration((want - tokens) / l.Rate * float64(time.Second))
+}
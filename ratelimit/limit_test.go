@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Limit
+		wantErr bool
+	}{
+		{"Per second", "10/s", Limit{Rate: 10, Burst: 10}, false},
+		{"Per minute with burst", "120/m:5", Limit{Rate: 2, Burst: 5}, false},
+		{"Per hour", "3600/h", Limit{Rate: 1, Burst: 3600}, false},
+		{"Unknown unit", "10/w", Limit{}, true},
+		{"Missing unit", "10", Limit{}, true},
+		{"Zero requests", "0/s", Limit{}, true},
+		{"Invalid burst", "10/s:x", Limit{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLimit(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseLimit() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLimit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    map[string]Limit
+		wantErr bool
+	}{
+		{"Two routes", "GET  /companies=5/s:10; POST /companies/websites=60/m",
+			map[string]Limit{"GET /companies": {5, 10}, "POST /companies/websites": {1, 60}}, false},
+		{"Empty", "", map[string]Limit{}, false},
+		{"Missing limit", "GET /companies", nil, true},
+		{"Invalid limit", "GET /companies=fast", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRoutes(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRoutes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRoutes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/marcospsbrito/dic/apierror"
+	"github.com/marcospsbrito/dic/auth"
+)
+
+var (
+	// ErrRateLimited is returned when a client calls a route too often
+	ErrRateLimited = apierror.New(http.StatusTooManyRequests, "rate_limit.exceeded", "Too many requests, retry later")
+	// ErrQuotaExceeded is returned when a client used its daily quota
+	ErrQuotaExceeded = apierror.New(http.StatusTooManyRequests, "quota.exceeded", "Daily quota exceeded, retry tomorrow")
+)
+
+// Limiter applies the rate limits of routes and the daily quotas of clients.
+// Clients are told apart by their API key or token subject, or by their IP
+// when anonymous. When the Store fails requests are let through.
+type Limiter struct {
+	store  Store
+	limit  Limit
+	routes map[string]Limit
+	now    func() time.Time
+}
+
+// NewLimiter returns a Limiter applying the limit of routes, keyed by
+// "<METHOD> <path>", or the default limit to the other routes
+func NewLimiter(s Store, limit Limit, routes map[string]Limit) *Limiter {
+	return &Limiter{store: s, limit: limit, routes: routes, now: time.Now}
+}
+
+// Limit middleware rejects the requests of clients that exhausted the bucket
+// of route with 429. Every response has the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers. A nil Limiter lets every
+// request through.
+func (l *Limiter) Limit(route string) gin.HandlerFunc {
+	return l.limitBy(route, clientKey)
+}
+
+// LimitIP middleware is like Limit but tells clients apart by their IP only,
+// so it bounds the requests made before authentication, such as key guesses.
+func (l *Limiter) LimitIP(route string) gin.HandlerFunc {
+	return l.limitBy(route, ipKey)
+}
+
+// limitBy applies the limit of route to the clients identified by key
+func (l *Limiter) limitBy(route string, key func(*gin.Context) string) gin.HandlerFunc {
+	if l == nil {
+		return pass
+	}
+	limit, ok := l.routes[route]
+	if !ok {
+		limit = l.limit
+	}
+	return func(ctx *gin.Context) {
+		client := key(ctx)
+		r, err := l.store.Take(ctx.Request.Context(), route+"|"+client, limit, l.now())
+		if err != nil {
+			log.WithError(err).Error("Cannot apply rate limit")
+			ctx.Next()
+			return
+		}
+		ctx.Header("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		ctx.Header("RateLimit-Remaining", strconv.Itoa(r.Remaining))
+		ctx.Header("RateLimit-Reset", seconds(r.Reset))
+		if !r.Allowed {
+			log.WithFields(log.Fields{"client": client, "route": route}).Info("Rate limited")
+			tooManyRequests(ctx, r.RetryAfter, ErrRateLimited)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// Quota middleware rejects the requests of clients that made daily requests
+// of the named quota today, in UTC, with 429. Requests for which applies
+// returns false are not counted; a nil applies counts every request.
+func (l *Limiter) Quota(name string, daily int, applies func(*gin.Context) bool) gin.HandlerFunc {
+	if l == nil {
+		return pass
+	}
+	return func(ctx *gin.Context) {
+		if daily <= 0 || (applies != nil && !applies(ctx)) {
+			ctx.Next()
+			return
+		}
+		now := l.now().UTC()
+		tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		client := clientKey(ctx)
+		count, err := l.store.Incr(ctx.Request.Context(), name+"|"+client+"|"+now.Format("2006-01-02"), now, tomorrow)
+		if err != nil {
+			log.WithError(err).Error("Cannot apply quota")
+			ctx.Next()
+			return
+		}
+		ctx.Header("X-Quota-Limit", strconv.Itoa(daily))
+		ctx.Header("X-Quota-Remaining", strconv.Itoa(int(math.Max(0, float64(daily-count)))))
+		if count > daily {
+			log.WithFields(log.Fields{"client": client, "quota": name}).Info("Quota exceeded")
+			tooManyRequests(ctx, tomorrow.Sub(now), ErrQuotaExceeded)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+func pass(ctx *gin.Context) {
+	ctx.Next()
+}
+
+// clientKey identifies the caller by its principal, or by its IP
+func clientKey(ctx *gin.Context) string {
+	if p, ok := auth.PrincipalFrom(ctx); ok {
+		return p.Method + ":" + p.Subject
+	}
+	return ipKey(ctx)
+}
+
+// ipKey identifies the caller by its IP
+func ipKey(ctx *gin.Context) string {
+	return "ip:" + ctx.ClientIP()
+}
+
+func tooManyRequests(ctx *gin.Context, retryAfter time.Duration, err error) {
+	ctx.Header("Retry-After", seconds(retryAfter))
+	apierror.Abort(ctx, err)
+}
+
+// seconds rounds d up to whole seconds
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
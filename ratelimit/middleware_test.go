@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/marcospsbrito/dic/auth"
+)
+
+func newTestRouter(l *Limiter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+	r.GET("/companies", l.Limit("GET /companies"), ok)
+	r.POST("/companies/websites", l.Quota("import", 2, nil), ok)
+	return r
+}
+
+func TestLimiter_Limit(t *testing.T) {
+	now := time.Date(2019, 3, 14, 10, 0, 0, 0, time.UTC)
+	l := NewLimiter(NewMemoryStore(), Limit{Rate: 100, Burst: 100}, map[string]Limit{"GET /companies": {Rate: 0.5, Burst: 1}})
+	l.now = func() time.Time { return now }
+	r := newTestRouter(l)
+	tests := []struct {
+		name       string
+		ip         string
+		want       int
+		remaining  string
+		retryAfter string
+	}{
+		{"First request", "10.0.0.1:1234", http.StatusOK, "0", ""},
+		{"Limited", "10.0.0.1:1234", http.StatusTooManyRequests, "0", "2"},
+		{"Other client", "10.0.0.2:1234", http.StatusOK, "0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/companies", nil)
+			req.RemoteAddr = tt.ip
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("Limiter.Limit() status = %v, want %v", w.Code, tt.want)
+			}
+			if got := w.Header().Get("RateLimit-Limit"); got != "1" {
+				t.Errorf("RateLimit-Limit = %v, want 1", got)
+			}
+			if got := w.Header().Get("RateLimit-Remaining"); got != tt.remaining {
+				t.Errorf("RateLimit-Remaining = %v, want %v", got, tt.remaining)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %v, want %v", got, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestLimiter_Quota(t *testing.T) {
+	now := time.Date(2019, 3, 14, 22, 0, 0, 0, time.UTC)
+	l := NewLimiter(NewMemoryStore(), Limit{Rate: 100, Burst: 100}, nil)
+	l.now = func() time.Time { return now }
+	r := newTestRouter(l)
+	tests := []struct {
+		name       string
+		at         time.Duration
+		want       int
+		retryAfter string
+	}{
+		{"First import", 0, http.StatusOK, ""},
+		{"Second import", 0, http.StatusOK, ""},
+		{"Quota exceeded", time.Hour, http.StatusTooManyRequests, "3600"},
+		{"Next day", 3 * time.Hour, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l.now = func() time.Time { return now.Add(tt.at) }
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/companies/websites", nil))
+			if w.Code != tt.want {
+				t.Errorf("Limiter.Quota() status = %v, want %v", w.Code, tt.want)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %v, want %v", got, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestLimiter_nil(t *testing.T) {
+	var l *Limiter
+	w := httptest.NewRecorder()
+	newTestRouter(l).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/companies", nil))
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("nil Limiter status = %v, headers %v", w.Code, w.Header())
+	}
+}
+
+func TestLimiter_LimitIP(t *testing.T) {
+	now := time.Date(2019, 3, 14, 10, 0, 0, 0, time.UTC)
+	l := NewLimiter(NewMemoryStore(), Limit{Rate: 100, Burst: 100}, map[string]Limit{"authenticate": {Rate: 0.5, Burst: 3}})
+	l.now = func() time.Time { return now }
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	keys := auth.NewService(auth.NewMemoryRepository())
+	r.GET("/companies", l.LimitIP("authenticate"), auth.Authenticate(keys, nil), l.Limit("GET /companies"),
+		func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	want := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}
+	for i, code := range want {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/companies", nil)
+		req.Header.Set("X-API-Key", "guess")
+		r.ServeHTTP(w, req)
+		if w.Code != code {
+			t.Errorf("request %d status = %v, want %v", i+1, w.Code, code)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+
+	"github.com/marcospsbrito/dic/database"
+)
+
+// Migrations of the collections of the mongo Store. Their versions are shared
+// with the migrations of the other packages.
+var Migrations = []database.Migration{
+	{
+		Version:     8,
+		Description: "Expire the rate limit buckets and quota counters",
+		Up: func(db *mgo.Database) error {
+			ttl := mgo.Index{Key: []string{"expireAt"}, ExpireAfter: time.Second}
+			for _, name := range []string{"RateLimit", "Quota"} {
+				if err := db.C(name).EnsureIndex(ttl); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(db *mgo.Database) error {
+			for _, name := range []string{"RateLimit", "Quota"} {
+				if err := database.DropIndex(db.C(name), "expireAt"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
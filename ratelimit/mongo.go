@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/marcospsbrito/dic/database"
+)
+
+// maxAttempts bounds the retries of a bucket update that raced another one
+const maxAttempts = 5
+
+// errContention is returned when a bucket keeps changing under an update
+var errContention = errors.New("Rate limit bucket is updated concurrently")
+
+type mongoBucket struct {
+	Key      string    `bson:"_id"`
+	Tokens   float64   `bson:"tokens"`
+	Updated  time.Time `bson:"updated"`
+	ExpireAt time.Time `bson:"expireAt"`
+}
+
+// mongoStore is a Store shared by every instance using the same database
+type mongoStore struct {
+	buckets  *mgo.Collection
+	counters *mgo.Collection
+}
+
+// NewMongoStore returns a Store keeping its state in db. Buckets and
+// counters are removed by the TTL indexes of Migrations once they are full or
+// expired.
+func NewMongoStore(db *mgo.Database) Store {
+	if db == nil {
+		return nil
+	}
+	return mongoStore{db.C("RateLimit"), db.C("Quota")}
+}
+
+// run runs op on s bound to a copy of its session, as database.Run does
+func (s mongoStore) run(ctx context.Context, op func(s mongoStore) error) error {
+	return database.Run(ctx, s.buckets.Database.Session, func(session *mgo.Session) error {
+		return op(mongoStore{s.buckets.With(session), s.counters.With(session)})
+	})
+}
+
+// Take updates the bucket only if no other instance changed it since it was
+// read, retrying otherwise
+func (s mongoStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	var r Result
+	err := s.run(ctx, func(s mongoStore) error {
+		var err error
+		r, err = s.take(key, limit, now)
+		return err
+	})
+	return r, err
+}
+
+func (s mongoStore) take(key string, limit Limit, now time.Time) (Result, error) {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		var b mongoBucket
+		err := s.buckets.FindId(key).One(&b)
+		if err != nil && err != mgo.ErrNotFound {
+			return Result{}, err
+		}
+		found := err == nil
+		if !found {
+			b = mongoBucket{Key: key, Tokens: float64(limit.Burst), Updated: now}
+		}
+		previous := b.Updated
+		b.Tokens, b.Updated = limit.fill(b.Tokens, now.Sub(b.Updated)), now
+		r := take(&b.Tokens, limit)
+		b.ExpireAt = now.Add(r.Reset)
+		if found {
+			err = s.buckets.Update(bson.M{"_id": key, "updated": previous}, b)
+		} else {
+			err = s.buckets.Insert(b)
+		}
+		if err == nil {
+			return r, nil
+		}
+		if err != mgo.ErrNotFound && !mgo.IsDup(err) {
+			return Result{}, err
+		}
+	}
+	return Result{}, errContention
+}
+
+func (s mongoStore) Incr(ctx context.Context, key string, now time.Time, expireAt time.Time) (int, error) {
+	var c struct {
+		Count int `bson:"count"`
+	}
+	err := s.run(ctx, func(s mongoStore) error {
+		_, err := s.counters.FindId(key).Apply(mgo.Change{
+			Update:    bson.M{"$inc": bson.M{"count": 1}, "$setOnInsert": bson.M{"expireAt": expireAt}},
+			Upsert:    true,
+			ReturnNew: true,
+		}, &c)
+		return err
+	})
+	return c.Count, err
+}
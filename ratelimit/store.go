@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery is the number of calls between removals of stale state
+const sweepEvery = 1024
+
+// Result is the state of a bucket after taking a token
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is the time until a token is available, when not Allowed
+	RetryAfter time.Duration
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+}
+
+// Store keeps the buckets and quota counters of the clients
+type Store interface {
+	// Take removes a token from the bucket of key, refilled as limit says
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+	// Incr adds one to the counter of key, which expires at expireAt, and
+	// returns the new count
+	Incr(ctx context.Context, key string, now time.Time, expireAt time.Time) (int, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+type counter struct {
+	count    int
+	expireAt time.Time
+}
+
+// memoryStore is a Store of a single instance
+type memoryStore struct {
+	mu       sync.Mutex
+	calls    int
+	buckets  map[string]*bucket
+	counters map[string]*counter
+}
+
+// NewMemoryStore returns a Store keeping its state in memory
+func NewMemoryStore() Store {
+	return &memoryStore{buckets: make(map[string]*bucket), counters: make(map[string]*counter)}
+}
+
+func (s *memoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens, b.updated = limit.fill(b.tokens, now.Sub(b.updated)), now
+	r := take(&b.tokens, limit)
+	b.full = now.Add(r.Reset)
+	return r, nil
+}
+
+func (s *memoryStore) Incr(ctx context.Context, key string, now time.Time, expireAt time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.expireAt) {
+		c = &counter{expireAt: expireAt}
+		s.counters[key] = c
+	}
+	c.count++
+	return c.count, nil
+}
+
+// sweep drops the full buckets and expired counters once in a while, so
+// clients that stopped calling do not hold memory
+func (s *memoryStore) sweep(now time.Time) {
+	if s.calls++; s.calls%sweepEvery != 0 {
+		return
+	}
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+	for key, c := range s.counters {
+		if !now.Before(c.expireAt) {
+			delete(s.counters, key)
+		}
+	}
+}
+
+// take removes a token from a bucket holding tokens, already refilled
+func take(tokens *float64, limit Limit) Result {
+	if *tokens < 1 {
+		return Result{
+			RetryAfter: limit.wait(*tokens, 1),
+			Reset:      limit.wait(*tokens, float64(limit.Burst)),
+		}
+	}
+	*tokens--
+	return Result{
+		Allowed:   true,
+		Remaining: int(*tokens),
+		Reset:     limit.wait(*tokens, float64(limit.Burst)),
+	}
+}
@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_memoryStore_Take(t *testing.T) {
+	start := time.Date(2019, 3, 14, 10, 0, 0, 0, time.UTC)
+	limit := Limit{Rate: 1, Burst: 2}
+	tests := []struct {
+		name       string
+		at         time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{"First request", 0, true, 1, 0},
+		{"Burst", 0, true, 0, 0},
+		{"Bucket empty", 500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+		{"Refilled token", time.Second, true, 0, 0},
+		{"Full after idle", time.Hour, true, 1, 0},
+	}
+	s := NewMemoryStore()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Take(context.Background(), "client", limit, start.Add(tt.at))
+			if err != nil {
+				t.Fatalf("memoryStore.Take() error = %v", err)
+			}
+			if got.Allowed != tt.allowed || got.Remaining != tt.remaining || got.RetryAfter != tt.retryAfter {
+				t.Errorf("memoryStore.Take() = %+v, want allowed %v remaining %v retry after %v", got, tt.allowed, tt.remaining, tt.retryAfter)
+			}
+		})
+	}
+	if got, _ := s.Take(context.Background(), "other", limit, start.Add(time.Second)); !got.Allowed || got.Remaining != 1 {
+		t.Errorf("memoryStore.Take() of another key = %+v, want a full bucket", got)
+	}
+}
+
+func Test_memoryStore_Incr(t *testing.T) {
+	now := time.Date(2019, 3, 14, 10, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	for want := 1; want <= 3; want++ {
+		if got, _ := s.Incr(context.Background(), "import|key:1", now, now.Add(time.Hour)); got != want {
+			t.Errorf("memoryStore.Incr() = %v, want %v", got, want)
+		}
+	}
+	if got, _ := s.Incr(context.Background(), "import|key:1", now.Add(time.Hour), now.Add(2*time.Hour)); got != 1 {
+		t.Errorf("memoryStore.Incr() of an expired counter = %v, want 1", got)
+	}
+}
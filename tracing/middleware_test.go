@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestRecorder() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return recorder
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		traceparent string
+		wantTrace   string
+		wantStatus  int
+	}{
+		{"New trace", "", "", http.StatusOK},
+		{"Trace of the caller", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736", http.StatusOK},
+		{"Invalid traceparent", "00-zz-00f067aa0ba902b7-01", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := newTestRecorder()
+			var handlerSpan trace.SpanContext
+			r := gin.New()
+			r.Use(Middleware(func(*gin.Context) string { return "/companies" }))
+			r.GET("/companies", func(ctx *gin.Context) {
+				handlerSpan = trace.SpanContextFromContext(ctx.Request.Context())
+				ctx.Status(tt.wantStatus)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/companies", nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("Middleware() ended %v spans, want 1", len(spans))
+			}
+			if got := spans[0].Name(); got != "GET /companies" {
+				t.Errorf("Middleware() span name = %v, want GET /companies", got)
+			}
+			if spans[0].SpanContext().SpanID() != handlerSpan.SpanID() {
+				t.Errorf("Middleware() did not pass its span to the handler")
+			}
+			if tt.wantTrace != "" && spans[0].SpanContext().TraceID().String() != tt.wantTrace {
+				t.Errorf("Middleware() trace = %v, want %v", spans[0].SpanContext().TraceID(), tt.wantTrace)
+			}
+			if tt.wantTrace == "" && spans[0].Parent().IsValid() {
+				t.Errorf("Middleware() span has parent %v, want a new trace", spans[0].Parent())
+			}
+		})
+	}
+}
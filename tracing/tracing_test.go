@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		wantErr  bool
+	}{
+		{"Tracing disabled", ExporterNone, false},
+		{"Default exporter", "", false},
+		{"Stdout exporter", ExporterStdout, false},
+		{"Unknown exporter", "jaeger", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := Setup(Config{Exporter: tt.exporter, ServiceName: "dic", SampleRatio: 1})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if err := shutdown(context.Background()); err != nil {
+					t.Errorf("Setup() shutdown error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestEnd(t *testing.T) {
+	recorder := newTestRecorder()
+	ctx, parent := Start(nil, "parent")
+	_, child := Start(ctx, "child")
+	End(child, errors.New("mock error"))
+	End(parent, nil)
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Start() ended %v spans, want 2", len(spans))
+	}
+	if spans[0].Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Errorf("Start() child is not a span of its parent")
+	}
+	if spans[0].Status().Code != codes.Error || spans[1].Status().Code == codes.Error {
+		t.Errorf("End() statuses = %v, %v, want the error of the child only", spans[0].Status(), spans[1].Status())
+	}
+}